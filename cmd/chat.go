@@ -1,15 +1,27 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/KooQix/term-ai/internal/chat"
 	"github.com/KooQix/term-ai/internal/config"
+	ctxmanager "github.com/KooQix/term-ai/internal/context"
+	"github.com/KooQix/term-ai/internal/export"
 	"github.com/KooQix/term-ai/internal/fileprocessor"
+	"github.com/KooQix/term-ai/internal/importer"
 	"github.com/KooQix/term-ai/internal/provider"
+	"github.com/KooQix/term-ai/internal/rag"
+	"github.com/KooQix/term-ai/internal/search"
+	"github.com/KooQix/term-ai/internal/tools"
 	"github.com/KooQix/term-ai/internal/ui"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/viewport"
@@ -21,15 +33,29 @@ const availableCommands = `Available commands:
   /exit or /quit - Exit chat
   /clear - Clear conversation context
   /profile - Show current profile info
-  /attach <file> [...] - Attach one or more files
+  /attach <file> [...] - Attach one or more files (file.go:40-120, file.go#Func or file.pdf#pages=3-7 for part of one)
   /files - Show currently attached files
   /clear-files - Clear all attached files
   /context - Show context files from directory
-  /context-add <file> [...] - Add files to context
+  /context-add [--pin] <file> [...] - Add files to context (--pin keeps them with the saved conversation)
   /context-remove <file> - Remove file from context
   /add-message <text> - Add a user message to context without sending
-  /save <name> -d <optional-directory> - Save conversation
+  /title [text] - Show or set the conversation title
+  /tags [tag ...] - Show or set the conversation tags ("/tags -" clears them)
+  /save [name] -d <optional-directory> - Save conversation (name defaults to the conversation title)
   /load <path> - Load conversation from file
+  /search <query> - Search saved chats (quotes for phrases, role:/tag:/since:/until: filters)
+  /retry [profile] - Regenerate the last answer, optionally with another profile
+  /edit [n] - Edit one of your messages (the last one by default), dropping everything after it
+  /undo - Remove the last exchange
+  /pin [n|file] - Pin message n (the last one by default) or a context file, so it is never trimmed
+  /unpin [n|file] - Unpin a message or a context file
+  /pins - List pinned messages and files
+  /compact [n] - Summarize older turns, keeping the last n (compact_keep_turns) verbatim
+  /fork [n] [name] - Start a new branch after message n (user and assistant messages counted from 1, the last one by default)
+  /branches - List the branches of the conversation
+  /switch <n|name> - Switch to another branch
+  /export [html|md|json|pdf] [path] - Export the conversation (HTML by default, named after the title)
   /cp   - Copy the last assistant response to clipboard
   /pager - Dump the chat into the terminal so you can scroll back and select/copy spans longer than the viewport (press Enter to return)
   /help - Show this help`
@@ -39,6 +65,13 @@ var (
 	contextDir    string
 	chatPath      string
 
+	scanInclude  []string
+	scanExclude  []string
+	scanDepth    int
+	scanNoIgnore bool
+	dirMode      string
+	ragTopK      int
+
 	chatCmd = &cobra.Command{
 		Use:   "chat",
 		Short: "Start interactive chat session",
@@ -47,12 +80,12 @@ var (
 	}
 
 	// Available chat commands for auto-completion
-	chatCommands = []string{"/help", "/exit", "/quit", "/clear", "/profile", "/attach", "/files", "/clear-files", "/context", "/context-add", "/context-remove", "/add-message", "/save", "/load", "/cp", "/pager"}
+	chatCommands = []string{"/help", "/exit", "/quit", "/clear", "/profile", "/attach", "/files", "/clear-files", "/context", "/context-add", "/context-remove", "/add-message", "/title", "/tags", "/save", "/load", "/search", "/retry", "/edit", "/undo", "/pin", "/unpin", "/pins", "/compact", "/fork", "/branches", "/switch", "/export", "/cp", "/pager"}
 )
 
 var chatListCmd = &cobra.Command{
 	Use:   "list [project_name]",
-	Short: "List all saved chats",
+	Short: "List all saved chats with their title, profile, tags and dates",
 	Args:  cobra.MaximumNArgs(1),
 	RunE:  runChatList,
 }
@@ -64,13 +97,146 @@ var chatDeleteCmd = &cobra.Command{
 	RunE:  runChatDelete,
 }
 
+var chatMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Convert chats saved in the legacy " + config.LegacyChatFileExt + " format to " + config.ChatFileExt,
+	Args:  cobra.NoArgs,
+	RunE:  runChatMigrate,
+}
+
+var chatEncryptAllCmd = &cobra.Command{
+	Use:   "encrypt-all",
+	Short: "Encrypt every saved chat, autosave and transcript (or decrypt them with --decrypt)",
+	Long: `Rewrite every saved chat (the conversations directory and the directories
+registered by /save -d), autosaved session and compaction transcript encrypted
+with the configured key, so chats saved before chat.encryption was enabled are
+protected too. With --decrypt, they are rewritten in plaintext instead.
+
+The key is derived from the file set in chat.encryption.keyfile, or else from
+the passphrase in $` + ctxmanager.PassphraseEnv + `:
+  TERMAI_PASSPHRASE='correct horse battery staple' termai chat encrypt-all`,
+	Args: cobra.NoArgs,
+	RunE: runChatEncryptAll,
+}
+
+var chatResumeCmd = &cobra.Command{
+	Use:   "resume [last|session_id]",
+	Short: "Resume an autosaved chat session (the most recent one by default)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runChatResume,
+}
+
+var chatSearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search saved chats",
+	Long: `Search every saved chat (the conversations directory and the directories
+registered by /save -d) for messages containing all the query words.
+
+Use quotes for exact phrases, and filters inline or as flags:
+  termai chat search parser "race condition"
+  termai chat search retry role:assistant tag:backend since:2026-01-01
+  termai chat search deadlock --role user --until 2026-06-30`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runChatSearch,
+}
+
+var chatExportCmd = &cobra.Command{
+	Use:   "export <chat_id>",
+	Short: "Export a saved chat to HTML, Markdown, JSON or PDF",
+	Long: `Export a saved chat as a single file: a self-contained HTML page (highlighted
+code, collapsible tool calls and thinking, embedded images), Markdown, JSON or PDF.
+
+The file is named after the chat title in the current directory unless -o is
+given ("-o -" writes to stdout):
+  termai chat export my-chat --format html
+  termai chat export work/retro --format pdf -o retro.pdf`,
+	Args: cobra.ExactArgs(1),
+	RunE: runChatExport,
+}
+
+var chatImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import conversations from a ChatGPT or Claude data export, or OpenAI chat JSONL",
+	Long: `Import conversations from another service into saved chats.
+
+<file> is the export zip or its conversations.json (ChatGPT, Claude), or a JSONL
+file with one {"messages": [...]} object per line (openai-jsonl). The format is
+detected when --from is omitted.
+
+Conversations are saved under a project folder of the conversations directory
+(named after the source by default). Only the active branch of a conversation
+is imported, unless --all-branches is given: every branch is then saved as its
+own chat. Conversations imported before are skipped.
+  termai chat import ~/Downloads/chatgpt-export.zip
+  termai chat import conversations.json --from claude --project claude-history
+  termai chat import dataset.jsonl --from openai-jsonl`,
+	Args: cobra.ExactArgs(1),
+	RunE: runChatImport,
+}
+
+var (
+	migrateKeepLegacy bool
+	encryptAllDecrypt bool
+
+	importFrom        string
+	importProject     string
+	importAllBranches bool
+
+	exportFormat string
+	exportOutput string
+
+	searchRole  string
+	searchTags  []string
+	searchSince string
+	searchUntil string
+	searchLimit int
+	searchIndex bool
+
+	chatResume     bool
+	chatResumeID   string
+	chatResumeList bool
+
+	chatListSort    string
+	chatListReverse bool
+)
+
 func init() {
+	chatListCmd.Flags().StringVarP(&chatListSort, "sort", "s", "updated", "Sort by: updated, created, title, id or messages")
+	chatListCmd.Flags().BoolVarP(&chatListReverse, "reverse", "r", false, "Reverse the sort order")
+	chatMigrateCmd.Flags().BoolVar(&migrateKeepLegacy, "keep", false, "Keep the legacy files after conversion")
+	chatEncryptAllCmd.Flags().BoolVar(&encryptAllDecrypt, "decrypt", false, "Rewrite the encrypted chats in plaintext instead")
+
 	chatCmd.Flags().StringArrayVarP(&chatFilePaths, "file", "f", []string{}, "File(s) to attach (can be used multiple times)")
 	chatCmd.Flags().StringVarP(&contextDir, "dir", "d", "", "Directory to use as context (scans for supported files)")
+	chatCmd.Flags().StringVar(&dirMode, "dir-mode", "", "What --dir sends: files (their content), outline (tree and declarations, files read on demand) or rag (excerpts retrieved per question) (default: files.dir_mode)")
+	chatCmd.Flags().IntVar(&ragTopK, "top-k", 0, "Excerpts retrieved per question with --dir-mode rag (default: files.rag_top_k)")
+	chatCmd.Flags().StringArrayVar(&scanInclude, "include", []string{}, "Only keep the --dir files matching this glob, e.g. '**/*.go' (can be used multiple times)")
+	chatCmd.Flags().StringArrayVar(&scanExclude, "exclude", []string{}, "Leave out the --dir files and directories matching this glob (can be used multiple times)")
+	chatCmd.Flags().IntVar(&scanDepth, "depth", 0, "Levels of subdirectories scanned by --dir (default: files.scan_max_depth, -1 = unlimited)")
+	chatCmd.Flags().BoolVar(&scanNoIgnore, "no-ignore", false, "Don't follow .gitignore and .termaiignore files when scanning --dir")
 	chatCmd.Flags().StringVarP(&chatPath, "load-chat", "c", "", "Load a saved chat conversation from file")
+	chatCmd.Flags().BoolVar(&chatResume, "resume", false, "Resume the most recent autosaved session")
+	chatSearchCmd.Flags().StringVar(&searchRole, "role", "", "Only match messages of this role (user, assistant, system, tool)")
+	chatSearchCmd.Flags().StringArrayVarP(&searchTags, "tag", "t", []string{}, "Only match chats with this tag (can be used multiple times)")
+	chatSearchCmd.Flags().StringVar(&searchSince, "since", "", "Only match messages from this date on (YYYY-MM-DD)")
+	chatSearchCmd.Flags().StringVar(&searchUntil, "until", "", "Only match messages up to this date, included (YYYY-MM-DD)")
+	chatSearchCmd.Flags().IntVarP(&searchLimit, "limit", "n", 50, "Maximum number of matches (0 = unlimited)")
+	chatSearchCmd.Flags().BoolVar(&searchIndex, "index", false, "Maintain and use an on-disk index to skip chats that can't match")
+	chatExportCmd.Flags().StringVarP(&exportFormat, "format", "F", "html", "Export format: html, md, json or pdf")
+	chatExportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Output file (\"-\" for stdout)")
+	chatImportCmd.Flags().StringVar(&importFrom, "from", "auto", "Export format: chatgpt, claude or openai-jsonl (detected by default)")
+	chatImportCmd.Flags().StringVar(&importProject, "project", "", "Project folder to import into (defaults to the source name)")
+	chatImportCmd.Flags().BoolVar(&importAllBranches, "all-branches", false, "Import every branch of a conversation as its own chat")
+	chatResumeCmd.Flags().BoolVarP(&chatResumeList, "list", "l", false, "List autosaved sessions instead of resuming one")
 
 	chatCmd.AddCommand(chatListCmd)
 	chatCmd.AddCommand(chatDeleteCmd)
+	chatCmd.AddCommand(chatMigrateCmd)
+	chatCmd.AddCommand(chatEncryptAllCmd)
+	chatCmd.AddCommand(chatResumeCmd)
+	chatCmd.AddCommand(chatSearchCmd)
+	chatCmd.AddCommand(chatExportCmd)
+	chatCmd.AddCommand(chatImportCmd)
 }
 
 func runChat(cmd *cobra.Command, args []string) error {
@@ -96,15 +262,13 @@ func runChat(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("please set a valid API key for profile '%s' in your config file\nEdit config with: termai config edit", profile.Name)
 	}
 
+	// Nothing could be saved (autosaves included) without the key
+	if cfg.Chat.Encryption.Enabled && !ctxmanager.HasKey() {
+		return fmt.Errorf("chat encryption is enabled but no key is available: set $%s or chat.encryption.keyfile", ctxmanager.PassphraseEnv)
+	}
+
 	// Create provider
-	prov := provider.NewOpenAICompatible(
-		profile.Endpoint,
-		profile.APIKey,
-		profile.Model,
-		profile.Temperature,
-		profile.MaxTokens,
-		profile.TopP,
-	)
+	prov := provider.NewFromProfile(profile)
 
 	// Create chat model
 	ta := textarea.New()
@@ -121,6 +285,11 @@ func runChat(cmd *cobra.Command, args []string) error {
 		Available: availableCommands,
 	})
 
+	// An explicit --profile wins over the one recorded in a loaded chat
+	if profileName != "" {
+		m.LockProfile()
+	}
+
 	// Add welcome message
 	welcome := "Type /help to see available commands.\n\n"
 
@@ -140,22 +309,143 @@ func runChat(cmd *cobra.Command, args []string) error {
 	// Process directory context if provided
 	if contextDir != "" {
 		fmt.Print("Scanning directory context... ")
-		contextFiles, err := fileprocessor.ScanDirectory(contextDir)
-		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-		} else {
-			m.AddContextFiles(contextFiles)
+		opts := fileprocessor.ScanOptions{
+			MaxDepth:     cfg.Files.ScanMaxDepth,
+			Include:      scanInclude,
+			Exclude:      append(slices.Clone(cfg.Files.ScanExclude), scanExclude...),
+			NoIgnore:     scanNoIgnore,
+			MaxFileSize:  cfg.Files.MaxFileSize,
+			MaxTotalSize: cfg.Files.ScanMaxTotalSize,
+		}
+		if cmd.Flags().Changed("depth") {
+			opts.MaxDepth = scanDepth
+		}
+
+		mode := cfg.Files.DirMode
+		if dirMode != "" {
+			mode = dirMode
+		}
+
+		switch mode {
+		case config.DirModeOutline:
+			cache := fileprocessor.LoadOutlineCache()
+			outline, err := fileprocessor.BuildOutline(contextDir, opts, cache)
+			if err == nil {
+				err = tools.RegisterReadFile(contextDir, func(rel string) string {
+					reason, detail := fileprocessor.PathSkipReason(contextDir, rel, opts)
+					if detail != "" {
+						reason += ": " + detail
+					}
+					return reason
+				})
+			}
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				break
+			}
+			if err := cache.Save(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+
+			m.SetOutline(outline, opts, cache)
+			m.SetContextDir(contextDir)
+			fmt.Printf("✓ outline of %d file(s)\n", outline.Files)
+			welcome += fmt.Sprintf("📁 Context: %s (outline of %d files, %d outlined, read on demand)\n", contextDir, outline.Files, outline.Outlined)
+			if report := outline.Report(3); report != "" {
+				welcome += ui.InfoStyle.Render(report) + "\n"
+			}
+
+		case config.DirModeRAG:
+			index, err := rag.Open(contextDir)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				break
+			}
+			stats, err := index.Update(opts)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				break
+			}
+			// Embedded now rather than on the first question, while the UI isn't up yet
+			if pending := index.Unembedded(profile.EmbeddingModel); profile.EmbeddingModel != "" && pending > 0 {
+				embedder, _ := provider.NewEmbedder(profile)
+				ui.ShowSpinner(fmt.Sprintf("Embedding %d chunk(s) of the index with %s", pending, profile.EmbeddingModel))
+				embedded, err := index.Embed(context.Background(), embedder, profile.EmbeddingModel)
+				ui.ClearSpinner()
+				if err != nil {
+					fmt.Printf("Warning: failed to embed the index, ranking by terms only: %v\n", err)
+				} else {
+					fmt.Printf("✓ %d chunk(s) embedded with %s\n", embedded, profile.EmbeddingModel)
+				}
+			}
+			if err := index.Save(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+
+			topK := cfg.Files.RAGTopK
+			if cmd.Flags().Changed("top-k") {
+				topK = ragTopK
+			}
+			m.SetRetrieval(index, opts, topK)
 			m.SetContextDir(contextDir)
-			fmt.Printf("✓ %d file(s) in context\n", len(contextFiles))
-			welcome += fmt.Sprintf("📁 Context: %s (%d files)\n", contextDir, len(contextFiles))
+			fmt.Printf("✓ %d file(s) indexed (%d updated)\n", stats.Files, stats.Indexed)
+			welcome += fmt.Sprintf("📁 Context: %s (index of %d files, %d chunks, top %d per question)\n", contextDir, stats.Files, stats.Chunks, topK)
+			if report := (&fileprocessor.ScanResult{Skipped: stats.Skipped}).Report(3); report != "" {
+				welcome += ui.InfoStyle.Render(report) + "\n"
+			}
+
+		case config.DirModeFiles, "":
+			scan, err := fileprocessor.ScanDirectory(contextDir, opts)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+			} else {
+				m.AddContextFiles(scan.Files)
+				m.SetContextDir(contextDir)
+				fmt.Printf("✓ %d file(s) in context\n", len(scan.Files))
+				welcome += fmt.Sprintf("📁 Context: %s (%d files, %s)\n", contextDir, len(scan.Files), fileprocessor.FormatSize(scan.TotalSize))
+			}
+			if scan != nil {
+				if report := scan.Report(3); report != "" {
+					welcome += ui.InfoStyle.Render(report) + "\n"
+				}
+			}
+
+		default:
+			return fmt.Errorf("unknown --dir-mode %q (use %s, %s or %s)", mode, config.DirModeFiles, config.DirModeOutline, config.DirModeRAG)
+		}
+	}
+
+	// Look for sessions that did not exit cleanly (unless resuming one right away)
+	resuming := chatResume || chatResumeID != ""
+	if !resuming {
+		welcome += unfinishedSessionNotice()
+	}
+	if cfg.Chat.Autosave {
+		maxAge := time.Duration(cfg.Chat.AutosaveMaxAge) * 24 * time.Hour
+		if err := ctxmanager.PruneAutosaves(maxAge, cfg.Chat.AutosaveMaxCount); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 		}
 	}
 
 	welcome += ui.FormatSeparator()
 	m.AddMessage(welcome)
 
-	// Check if loading a chat (and will load its context as well)
-	if chatPath != "" {
+	// Check if resuming an autosave or loading a chat (and will load its context as well)
+	var autosaver *ctxmanager.Autosaver
+	if resuming {
+		entry, err := ctxmanager.FindAutosave(chatResumeID)
+		if err != nil {
+			return err
+		}
+		if err := m.ResumeAutosave(entry.Path); err != nil {
+			return fmt.Errorf("failed to resume session: %w", err)
+		}
+		if cfg.Chat.Autosave {
+			if autosaver, err = ctxmanager.ResumeAutosaver(entry.Path); err != nil {
+				return err
+			}
+		}
+	} else if chatPath != "" {
 		if err := m.LoadChatHandler(chatPath); err != nil {
 			return fmt.Errorf("failed to load conversation: %w", err)
 		}
@@ -166,6 +456,16 @@ func runChat(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if cfg.Chat.Autosave && autosaver == nil {
+		if autosaver, err = ctxmanager.NewAutosaver(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: autosave disabled: %v\n", err)
+		}
+	}
+	if autosaver != nil {
+		m.SetAutosaver(autosaver)
+		defer autosaver.Close()
+	}
+
 	// Start Bubble Tea program
 	p := tea.NewProgram(m, tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
@@ -175,6 +475,70 @@ func runChat(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// unfinishedSessionNotice returns a welcome notice about the most recent
+// session that did not exit cleanly, if any. Every unfinished session is only
+// reported once.
+func unfinishedSessionNotice() string {
+	entries, err := ctxmanager.ListAutosaves()
+	if err != nil {
+		return ""
+	}
+
+	notice := ""
+	for _, entry := range entries {
+		if !entry.Unfinished {
+			continue
+		}
+		if notice == "" {
+			name := entry.ID
+			if summary, err := ctxmanager.ReadSummary(entry.Path); err == nil && summary.Metadata.Title != "" {
+				name = summary.Metadata.Title
+			}
+			notice = fmt.Sprintf("⚠️  Unfinished session '%s' from %s found, resume it with: termai chat resume %s\n",
+				name, formatChatTime(entry.ModTime), entry.ID)
+		}
+		ctxmanager.AcknowledgeUnfinished(entry)
+	}
+
+	return notice
+}
+
+func runChatResume(cmd *cobra.Command, args []string) error {
+	if chatResumeList {
+		entries, err := ctxmanager.ListAutosaves()
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Println("No autosaved sessions.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTITLE\tMSGS\tSAVED\tSTATE")
+		for _, entry := range entries {
+			title, count := "-", 0
+			if summary, err := ctxmanager.ReadSummary(entry.Path); err == nil {
+				title, count = orDash(summary.Metadata.Title), summary.Messages
+			}
+			state := "closed"
+			if entry.Running {
+				state = "running"
+			} else if entry.Unfinished {
+				state = "unfinished"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", entry.ID, title, count, formatChatTime(entry.ModTime), state)
+		}
+		return w.Flush()
+	}
+
+	chatResumeID = "last"
+	if len(args) > 0 {
+		chatResumeID = args[0]
+	}
+	return runChat(cmd, nil)
+}
+
 //////////////////// Chat CLI commands \\\\\\\\\\\\\\\\\\\\
 
 func runChatList(cmd *cobra.Command, args []string) error {
@@ -184,13 +548,13 @@ func runChatList(cmd *cobra.Command, args []string) error {
 	}
 
 	// If project name is provided, list contents of that project
+	root := chatPath
 	if len(args) > 0 {
-		projectPath := filepath.Join(chatPath, args[0])
-		if _, err := os.Stat(projectPath); os.IsNotExist(err) {
+		root = filepath.Join(chatPath, args[0])
+		info, err := os.Stat(root)
+		if os.IsNotExist(err) {
 			return fmt.Errorf("project '%s' not found", args[0])
 		}
-
-		info, err := os.Stat(projectPath)
 		if err != nil {
 			return err
 		}
@@ -198,58 +562,143 @@ func runChatList(cmd *cobra.Command, args []string) error {
 		if !info.IsDir() {
 			return fmt.Errorf("'%s' is not a project/folder", args[0])
 		}
-
-		fmt.Printf("Chats in project '%s':\n\n", args[0])
-		return listProjectContents(projectPath, "")
 	}
 
-	// List top-level chats and folders
-	entries, err := os.ReadDir(chatPath)
+	summaries, err := listChats(chatPath, root)
 	if err != nil {
-		return fmt.Errorf("failed to read chats directory: %w", err)
+		return err
 	}
 
-	if len(entries) == 0 {
+	if len(summaries) == 0 {
 		fmt.Println("No chats found.")
 		return nil
 	}
 
-	fmt.Println("Chats:")
-	for _, entry := range entries {
-		if entry.IsDir() {
-			fmt.Printf("  📁 %s/ (project)\n", entry.Name())
-		} else {
-			displayName := config.GetDisplayPath(entry.Name())
-			fmt.Printf("  📄 %s\n", displayName)
+	if err := sortChats(summaries, chatListSort, chatListReverse); err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		fmt.Printf("Chats in project '%s':\n\n", args[0])
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTITLE\tMSGS\tBRANCHES\tPROFILE\tTAGS\tUPDATED\tCREATED")
+	for _, s := range summaries {
+		profile := s.Metadata.Profile
+		if s.Metadata.Model != "" {
+			if profile == "" {
+				profile = s.Metadata.Model
+			} else {
+				profile += " (" + s.Metadata.Model + ")"
+			}
+		}
+		title := orDash(s.Metadata.Title)
+		if s.Encrypted {
+			title = "🔒 " + title
+		}
+		branches := "-"
+		if s.Branches > 1 {
+			branches = strconv.Itoa(s.Branches)
 		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			s.ID,
+			title,
+			s.Messages,
+			branches,
+			orDash(profile),
+			orDash(strings.Join(s.Metadata.Tags, ",")),
+			formatChatTime(s.Metadata.UpdatedAt),
+			formatChatTime(s.Metadata.CreatedAt),
+		)
 	}
 
-	return nil
+	return w.Flush()
+}
+
+// chatEntry is a saved chat found under the conversations directory
+type chatEntry struct {
+	ID string // path relative to the conversations directory, without extension (usable with --load-chat)
+	*ctxmanager.Summary
 }
 
-func listProjectContents(projectPath string, indent string) error {
-	entries, err := os.ReadDir(projectPath)
+// listChats collects the summaries of all chats under root, with IDs relative to chatsRoot
+func listChats(chatsRoot, root string) ([]chatEntry, error) {
+	var entries []chatEntry
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !config.IsChatFile(path) {
+			return nil
+		}
+
+		summary, err := ctxmanager.ReadSummary(path)
+		if errors.Is(err, ctxmanager.ErrNoKey) {
+			summary.Metadata.Title = "(encrypted, no key)"
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping '%s': %v\n", path, err)
+			return nil
+		}
+
+		id, err := filepath.Rel(chatsRoot, config.TrimChatExt(path))
+		if err != nil {
+			id = config.TrimChatExt(path)
+		}
+
+		entries = append(entries, chatEntry{ID: id, Summary: summary})
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to read project directory: %w", err)
+		return nil, fmt.Errorf("failed to read chats directory: %w", err)
 	}
 
-	for _, entry := range entries {
-		fullPath := filepath.Join(projectPath, entry.Name())
+	return entries, nil
+}
 
-		if entry.IsDir() {
-			fmt.Printf("%s  📁 %s/\n", indent, entry.Name())
-			if err := listProjectContents(fullPath, indent+"    "); err != nil {
-				return err
-			}
-		} else {
-			displayName := config.GetDisplayPath(entry.Name())
-			fmt.Printf("%s  📄 %s\n", indent, displayName)
+// sortChats orders chats by the given key; dates sort newest first
+func sortChats(entries []chatEntry, key string, reverse bool) error {
+	var less func(a, b chatEntry) int
+	switch key {
+	case "updated", "":
+		less = func(a, b chatEntry) int { return b.Metadata.UpdatedAt.Compare(a.Metadata.UpdatedAt) }
+	case "created":
+		less = func(a, b chatEntry) int { return b.Metadata.CreatedAt.Compare(a.Metadata.CreatedAt) }
+	case "title":
+		less = func(a, b chatEntry) int {
+			return strings.Compare(strings.ToLower(a.Metadata.Title), strings.ToLower(b.Metadata.Title))
 		}
+	case "id", "name":
+		less = func(a, b chatEntry) int { return strings.Compare(a.ID, b.ID) }
+	case "messages":
+		less = func(a, b chatEntry) int { return b.Messages - a.Messages }
+	default:
+		return fmt.Errorf("unknown sort key '%s' (use updated, created, title, id or messages)", key)
 	}
 
+	slices.SortStableFunc(entries, func(a, b chatEntry) int {
+		if reverse {
+			return less(b, a)
+		}
+		return less(a, b)
+	})
 	return nil
 }
 
+func formatChatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
 func runChatDelete(cmd *cobra.Command, args []string) error {
 	chatPath, err := config.GetDefaultChatsPath()
 	if err != nil {
@@ -282,6 +731,285 @@ func runChatDelete(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runChatMigrate(cmd *cobra.Command, args []string) error {
+	chatPath, err := config.GetDefaultChatsPath()
+	if err != nil {
+		return err
+	}
+
+	converted, skipped := 0, 0
+	err = filepath.Walk(chatPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, config.LegacyChatFileExt) {
+			return nil
+		}
+
+		target := ctxmanager.ChatFilePath(path)
+		if _, err := os.Stat(target); err == nil {
+			fmt.Printf("  ⏭  %s (already exists as %s)\n", config.GetDisplayPath(path), filepath.Base(target))
+			skipped++
+			return nil
+		}
+
+		m := ctxmanager.NewManager()
+		if err := m.Load(path); err != nil {
+			return fmt.Errorf("failed to load '%s': %w", path, err)
+		}
+		if err := m.Save(target); err != nil {
+			return fmt.Errorf("failed to save '%s': %w", target, err)
+		}
+		if !migrateKeepLegacy {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("failed to remove '%s': %w", path, err)
+			}
+		}
+
+		fmt.Printf("  ✓ %s\n", config.GetDisplayPath(path))
+		converted++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
+	fmt.Printf("Converted %d chat(s), skipped %d.\n", converted, skipped)
+	return nil
+}
+
+func runChatEncryptAll(cmd *cobra.Command, args []string) error {
+	if _, err := config.Load(); err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if !ctxmanager.HasKey() {
+		return ctxmanager.ErrNoKey
+	}
+
+	dirs, err := config.GetChatDirs()
+	if err != nil {
+		return err
+	}
+	for _, get := range []func() (string, error){config.GetAutosavePath, config.GetTranscriptsPath} {
+		dir, err := get()
+		if err != nil {
+			return err
+		}
+		dirs = append(dirs, dir)
+	}
+
+	seen := map[string]bool{}
+	changed, failed := 0, 0
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				// A registered directory may have been removed since
+				if path == dir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() || seen[path] || !strings.HasSuffix(path, config.ChatFileExt) {
+				return nil
+			}
+			seen[path] = true
+
+			done, err := ctxmanager.SetEncrypted(path, !encryptAllDecrypt)
+			if err != nil {
+				fmt.Printf("  ✗ %s: %v\n", config.GetDisplayPath(path), err)
+				failed++
+				return nil
+			}
+			if done {
+				fmt.Printf("  ✓ %s\n", config.GetDisplayPath(path))
+				changed++
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scan '%s': %w", dir, err)
+		}
+	}
+
+	verb := "Encrypted"
+	if encryptAllDecrypt {
+		verb = "Decrypted"
+	}
+	fmt.Printf("%s %d chat(s), %d failed.\n", verb, changed, failed)
+	if !encryptAllDecrypt {
+		// The index holds the words of the chats in plaintext
+		dropped, err := search.DropIndex()
+		if err != nil {
+			return err
+		}
+		if dropped {
+			fmt.Println("Removed the search index, which held the words of the chats in plaintext (chat search --index rebuilds it without the encrypted ones).")
+		}
+	}
+	if legacy := countLegacyChats(dirs); legacy > 0 {
+		fmt.Printf("%d chat(s) in the legacy %s format were left as is, convert them first with: termai chat migrate\n", legacy, config.LegacyChatFileExt)
+	}
+	return nil
+}
+
+// countLegacyChats counts the chats saved in the legacy format under dirs
+func countLegacyChats(dirs []string) int {
+	count := 0
+	for _, dir := range dirs {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && strings.HasSuffix(path, config.LegacyChatFileExt) {
+				count++
+			}
+			return nil
+		})
+	}
+	return count
+}
+
+func runChatSearch(cmd *cobra.Command, args []string) error {
+	q, err := search.ParseQuery(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+
+	// Flags add to the inline filters
+	if searchRole != "" {
+		q.Role = provider.ContextRole(strings.ToLower(searchRole))
+	}
+	q.Tags = append(q.Tags, searchTags...)
+	if searchSince != "" {
+		if q.Since, err = search.ParseDate(searchSince); err != nil {
+			return err
+		}
+	}
+	if searchUntil != "" {
+		until, err := search.ParseDate(searchUntil)
+		if err != nil {
+			return err
+		}
+		q.Until = until.AddDate(0, 0, 1)
+	}
+	q.Limit = searchLimit
+
+	hits, locked, err := search.Search(q, searchIndex)
+	if err != nil {
+		return err
+	}
+	if locked > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %d encrypted chat(s) not searched, set $%s or chat.encryption.keyfile to include them\n", locked, ctxmanager.PassphraseEnv)
+	}
+
+	fmt.Println(search.Format(hits, q, func(s string) string { return ui.MatchStyle.Render(s) }))
+	if len(hits) > 0 {
+		fmt.Println()
+		fmt.Println(ui.InfoStyle.Render("Open a chat with: termai chat --load-chat <id>"))
+	}
+	return nil
+}
+
+func runChatExport(cmd *cobra.Command, args []string) error {
+	format, err := export.ParseFormat(exportFormat)
+	if err != nil {
+		return err
+	}
+
+	chatsPath, err := config.GetDefaultChatsPath()
+	if err != nil {
+		return err
+	}
+
+	// Chats outside the conversations directory are given by path (as listed by search)
+	path, err := findChat(chatsPath, args[0])
+	if err != nil {
+		var resolveErr error
+		if path, resolveErr = ctxmanager.ResolveChatFile(args[0]); resolveErr != nil {
+			return err
+		}
+	}
+
+	conv, err := ctxmanager.ReadConversation(path)
+	if err != nil {
+		return err
+	}
+
+	if exportOutput == "-" {
+		return export.Export(os.Stdout, conv, format)
+	}
+
+	output := exportOutput
+	if output == "" {
+		output = export.DefaultFileName(conv, path, format)
+	}
+	if err := export.WriteFile(output, conv, format); err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported '%s' to %s\n", config.GetDisplayPath(args[0]), output)
+	return nil
+}
+
+func runChatImport(cmd *cobra.Command, args []string) error {
+	source, err := importer.ParseSource(importFrom)
+	if err != nil {
+		return err
+	}
+
+	convs, source, err := importer.Read(args[0], importer.Options{Source: source, AllBranches: importAllBranches})
+	if err != nil {
+		return err
+	}
+
+	chatsPath, err := config.GetDefaultChatsPath()
+	if err != nil {
+		return err
+	}
+	project := importProject
+	if project == "" {
+		project = string(source)
+	}
+	dir := filepath.Join(chatsPath, project)
+
+	// Conversations already in the project are recognized by their source ID
+	imported := map[string]bool{}
+	if entries, err := listChats(dir, dir); err == nil {
+		for _, entry := range entries {
+			if entry.Metadata.Source != "" {
+				imported[entry.Metadata.Source] = true
+			}
+		}
+	}
+
+	now := time.Now()
+	added, skipped := 0, 0
+	for _, conv := range convs {
+		if imported[conv.Metadata.Source] {
+			skipped++
+			continue
+		}
+		if conv.Metadata.CreatedAt.IsZero() {
+			conv.Metadata.CreatedAt = now
+		}
+		if conv.Metadata.UpdatedAt.IsZero() {
+			conv.Metadata.UpdatedAt = conv.Metadata.CreatedAt
+		}
+
+		if err := ctxmanager.WriteConversation(ctxmanager.UniqueChatPath(dir, conv.Metadata.Title), conv); err != nil {
+			return err
+		}
+		added++
+	}
+
+	fmt.Printf("Imported %d conversation(s) into '%s'", added, project)
+	if skipped > 0 {
+		fmt.Printf(", skipped %d already imported", skipped)
+	}
+	fmt.Println(".")
+	if added > 0 {
+		fmt.Printf("List them with: termai chat list %s\n", project)
+	}
+	return nil
+}
+
 // findChat searches for a chat file in the conversations directory
 // It checks both the root level and subdirectories
 func findChat(chatPath, chatID string) (string, error) {
@@ -291,8 +1019,8 @@ func findChat(chatPath, chatID string) (string, error) {
 		return directPath, nil
 	}
 
-	// Try with common extensions if not found
-	extensions := []string{".json", ".txt", ".md"}
+	// Try with conversation extensions if not found
+	extensions := []string{config.ChatFileExt, config.LegacyChatFileExt}
 	for _, ext := range extensions {
 		if !strings.HasSuffix(chatID, ext) {
 			testPath := filepath.Join(chatPath, chatID+ext)
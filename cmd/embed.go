@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/KooQix/term-ai/internal/config"
+	"github.com/KooQix/term-ai/internal/provider"
+	"github.com/spf13/cobra"
+)
+
+var (
+	embedWithText bool
+	embedRaw      bool
+)
+
+var embedCmd = &cobra.Command{
+	Use:   "embed [text...]",
+	Short: "Print embedding vectors as JSON",
+	Long: `Turn texts into embedding vectors with the embedding_model of a profile, and
+print them as JSON. Texts are the arguments, or the lines of stdin when there
+are none. Texts are sent in batches; each one is cut to 16KB.
+
+Examples:
+  termai embed "hello world"
+  cat sentences.txt | termai embed --profile ollama --text
+  termai embed --raw "a query" | jq length`,
+	RunE: runEmbed,
+}
+
+func init() {
+	embedCmd.Flags().BoolVar(&embedWithText, "text", false, "Include each text next to its vector")
+	embedCmd.Flags().BoolVar(&embedRaw, "raw", false, "Print only an array of vectors")
+}
+
+// embedding is one vector of the output of termai embed
+type embedding struct {
+	Index     int       `json:"index"`
+	Text      string    `json:"text,omitempty"`
+	Embedding []float32 `json:"embedding"`
+}
+
+func runEmbed(cmd *cobra.Command, args []string) error {
+	texts := args
+	if len(texts) == 0 {
+		var err error
+		if texts, err = readLines(os.Stdin); err != nil {
+			return fmt.Errorf("failed to read stdin: %w", err)
+		}
+	}
+	if len(texts) == 0 {
+		return fmt.Errorf("no text to embed: pass texts as arguments or lines on stdin")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	var profile *config.Profile
+	if profileName != "" {
+		profile, err = cfg.GetProfile(profileName)
+	} else {
+		profile, err = cfg.GetDefaultProfile()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get profile: %w", err)
+	}
+
+	embedder, err := provider.NewEmbedder(profile)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	vectors, err := embedder.Embed(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("failed to embed: %w", err)
+	}
+
+	var out any = vectors
+	if !embedRaw {
+		embeddings := make([]embedding, len(vectors))
+		for i, vector := range vectors {
+			embeddings[i] = embedding{Index: i, Embedding: vector}
+			if embedWithText {
+				embeddings[i].Text = texts[i]
+			}
+		}
+		out = embeddings
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	return encoder.Encode(out)
+}
+
+// readLines returns the non-blank lines of r
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
@@ -48,6 +48,7 @@ func init() {
 	rootCmd.AddCommand(chatCmd)
 	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(profilesCmd)
+	rootCmd.AddCommand(embedCmd)
 }
 
 func Execute() error {
@@ -85,14 +86,7 @@ func runPrompt(cmd *cobra.Command, args []string) error {
 	}
 
 	// Create provider
-	prov := provider.NewOpenAICompatible(
-		profile.Endpoint,
-		profile.APIKey,
-		profile.Model,
-		profile.Temperature,
-		profile.MaxTokens,
-		profile.TopP,
-	)
+	prov := provider.NewFromProfile(profile)
 
 	// Setup context cancellation
 	ctx, cancel := context.WithCancel(context.Background())
@@ -121,33 +115,15 @@ func runPrompt(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Build message content
-	messageContent := prompt
-	var images []string
-
-	// Add file contents to the message
+	// Build the message, with the files attached
+	message := provider.Message{Role: provider.RoleUser, Content: prompt}
 	for _, attachment := range attachments {
-		switch attachment.Type {
-		case "image":
-			// Add image as base64 data URL
-			images = append(images, attachment.Content)
-			fmt.Printf("  • Image: %s\n", attachment.Name)
-		case "pdf", "text", "code":
-			// Append text content to the prompt
-			messageContent += fmt.Sprintf("\n\n--- Content from %s ---\n%s\n--- End of %s ---",
-				attachment.Name, attachment.Content, attachment.Name)
-			fmt.Printf("  • %s: %s\n", strings.Title(attachment.Type), attachment.Name)
-		}
+		fmt.Printf("  • %s: %s\n", strings.Title(attachment.Type), attachment.Name)
+		message.Attachments = append(message.Attachments, attachment.Attachment())
 	}
 
 	// Create messages
-	messages := []provider.Message{
-		{
-			Role:    "user",
-			Content: messageContent,
-			Images:  images,
-		},
-	}
+	messages := []provider.Message{message}
 
 	// Add system context if defined in profile
 	// If no context is defined for the given profile, will take the default one from config
@@ -0,0 +1,121 @@
+package chat
+
+import (
+	"slices"
+	"strings"
+
+	ctxmanager "github.com/KooQix/term-ai/internal/context"
+	"github.com/KooQix/term-ai/internal/fileprocessor"
+	"github.com/KooQix/term-ai/internal/provider"
+	"github.com/KooQix/term-ai/internal/ui"
+)
+
+// Files reach the model in one of two ways. Attached files, and context files
+// by default, are stored once with the prompt they were sent with and stay in
+// the history from there. Context files going with every request (pinned ones,
+// the project outline or retrieved excerpts, or all of them with
+// files.include_context_in_every_msg) are never stored: each request carries
+// them on its last prompt only.
+
+// takeAttachments returns the files to store with the next prompt: the
+// attached ones, which are cleared, and the context files not sent yet
+func (m *chatModel) takeAttachments() []provider.Attachment {
+	var attachments []provider.Attachment
+	for _, file := range m.attachedFiles {
+		attachments = append(attachments, attachmentOf(file))
+	}
+	for _, file := range m.unsentContextFiles() {
+		attachments = append(attachments, attachmentOf(file))
+	}
+	m.attachedFiles = nil
+	return attachments
+}
+
+// everyRequest reports whether a context file goes with every request rather
+// than being stored once
+func (m *chatModel) everyRequest(file *fileprocessor.FileAttachment) bool {
+	return m.cfg.Files.IncludeContextInEveryMsg || m.isOutline(file) || m.isRetrieval(file) || m.ctxManager.IsFilePinned(absPath(file.Path))
+}
+
+// unsentContextFiles returns the context files to store with the next prompt
+func (m *chatModel) unsentContextFiles() []*fileprocessor.FileAttachment {
+	var files []*fileprocessor.FileAttachment
+	for _, file := range m.contextFiles {
+		if !m.everyRequest(file) && !m.ctxManager.HasAttachment(absPath(file.Path)) {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// everyRequestFiles returns the context files placed on the last prompt of every request
+func (m *chatModel) everyRequestFiles() []*fileprocessor.FileAttachment {
+	var files []*fileprocessor.FileAttachment
+	for _, file := range m.contextFiles {
+		if m.everyRequest(file) {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// placeContextFiles adds the context files going with every request to the
+// last prompt of messages, a copy of the history. A file stored with an
+// earlier message (before being pinned) is not sent there too.
+func (m *chatModel) placeContextFiles(messages []provider.Message) {
+	files := m.everyRequestFiles()
+	if len(files) == 0 {
+		return
+	}
+
+	placed := make([]provider.Attachment, 0, len(files))
+	for _, file := range files {
+		placed = append(placed, attachmentOf(file))
+	}
+	isPlaced := func(a provider.Attachment) bool {
+		return slices.ContainsFunc(placed, func(p provider.Attachment) bool { return p.Path == a.Path })
+	}
+
+	last := -1
+	for i := range messages {
+		if slices.ContainsFunc(messages[i].Attachments, isPlaced) {
+			messages[i].Attachments = slices.DeleteFunc(slices.Clone(messages[i].Attachments), isPlaced)
+		}
+		if messages[i].Role == provider.RoleUser {
+			last = i
+		}
+	}
+	if last >= 0 {
+		messages[last].Attachments = append(slices.Clone(messages[last].Attachments), placed...)
+	}
+}
+
+// attachmentOf converts a file into a message attachment, recorded under its absolute path
+func attachmentOf(file *fileprocessor.FileAttachment) provider.Attachment {
+	a := file.Attachment()
+	a.Path = absPath(file.Path)
+	return a
+}
+
+// fileTokens estimates the tokens files add to a request
+func fileTokens(files []*fileprocessor.FileAttachment) int {
+	tokens := 0
+	for _, file := range files {
+		tokens += ctxmanager.AttachmentTokens(file.Attachment())
+	}
+	return tokens
+}
+
+// formatUserMessage renders a prompt, with the names of the files sent along
+func formatUserMessage(content string, attachments []provider.Attachment) string {
+	formatted := ui.FormatUserMessage(content)
+	if len(attachments) == 0 {
+		return formatted
+	}
+
+	names := make([]string, 0, len(attachments))
+	for _, a := range attachments {
+		names = append(names, a.Name)
+	}
+	return formatted + "\n" + ui.InfoStyle.Render("📎 "+strings.Join(names, ", "))
+}
@@ -3,6 +3,8 @@ package chat
 import (
 	"context"
 	"fmt"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/KooQix/term-ai/internal/config"
@@ -21,12 +23,16 @@ type ChatCommands struct {
 }
 
 type chatModel struct {
+	cfg                *config.Config
 	textarea           textarea.Model
 	viewport           viewport.Model
 	messages           []string
 	ctxManager         *ctxmanager.Manager
 	provider           provider.Provider
 	Profile            *config.Profile
+	profileLocked      bool              // profile chosen explicitly, not replaced by the one recorded in a loaded chat
+	turnProfile        *config.Profile   // profile answering the current turn only (/retry <profile>), nil for the session one
+	turnProvider       provider.Provider // provider of turnProfile
 	streaming          bool
 	currentResp        string
 	streamChan         <-chan provider.StreamChunk
@@ -39,15 +45,23 @@ type chatModel struct {
 	attachedFiles  []*fileprocessor.FileAttachment
 	contextFiles   []*fileprocessor.FileAttachment
 	contextDirPath string
+	outline        *outlineContext   // project outline sent in place of the directory files, nil if none
+	retrieval      *retrievalContext // index whose excerpts are sent in place of the directory files, nil if none
 
 	chatPath string // Path to save/load conversation (only set when saving/loading)
 
+	autosaver *ctxmanager.Autosaver // persists every completed turn, nil when autosave is off
+
+	trimmed    int  // messages left out of the last request to fit the context window
+	compacting bool // a summary of the older turns is being generated
+
 	commands        ChatCommands
 	commandsHandler *commandHandler
 }
 
 func NewChatModel(cfg *config.Config, ta textarea.Model, vp viewport.Model, prov provider.Provider, profile *config.Profile, commands ChatCommands) *chatModel {
 	m := &chatModel{
+		cfg:        cfg,
 		textarea:   ta,
 		viewport:   vp,
 		messages:   []string{},
@@ -61,6 +75,8 @@ func NewChatModel(cfg *config.Config, ta textarea.Model, vp viewport.Model, prov
 	// Add the command handler
 	m.commandsHandler = newCommandHandler(m)
 
+	m.ctxManager.SetProfile(profile.Name, profile.Model)
+
 	// Add system config if defined in config
 	// Get from the profile (can be nil, empty, or non-empty)
 	// Profile context set by cfg.GetProfile
@@ -76,6 +92,14 @@ type streamMsg struct {
 	channel <-chan provider.StreamChunk
 }
 
+// preparedMsg carries the context prepared for a request: the rebuilt
+// project outline ("" to keep the previous one) and the retrieved excerpts
+type preparedMsg struct {
+	outline    string
+	outlineErr error // the outline cache couldn't be saved
+	retrieved  *retrieved
+}
+
 type errMsg struct {
 	err error
 }
@@ -154,7 +178,7 @@ func (m *chatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Check for Alt+Enter or Ctrl+Enter to send message
 			if msg.Alt || strings.Contains(msg.String(), "ctrl+enter") {
 				// Send message with Alt+Enter or Ctrl+Enter
-				if m.streaming {
+				if m.streaming || m.compacting {
 					return m, nil
 				}
 				userMsg := strings.TrimSpace(m.textarea.Value())
@@ -167,21 +191,16 @@ func (m *chatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m.commandsHandler.handle(userMsg)
 				}
 
-				// Add user message
+				// Add user message, with the files going along stored once
+				attachments := m.takeAttachments()
 				m.messages = append(m.messages, "")
-				m.messages = append(m.messages, ui.FormatUserMessage(userMsg))
+				m.messages = append(m.messages, formatUserMessage(userMsg, attachments))
 				m.messages = append(m.messages, "")
-				m.ctxManager.AddUserMessage(userMsg)
+				m.ctxManager.AddUserMessage(userMsg, attachments...)
 				m.textarea.Reset()
 				m.updateViewport()
 
-				// Start streaming
-				m.streaming = true
-				m.currentResp = ""
-				m.messages = append(m.messages, ui.AssistantStyle.Render("Assistant: "))
-				m.updateViewport()
-
-				return m, m.streamResponse()
+				return m, m.startStream()
 			}
 			// Regular Enter without modifiers - let textarea handle it (adds newline)
 			// Fall through to default textarea behavior
@@ -197,6 +216,7 @@ func (m *chatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.err = msg.chunk.Error
 			m.streaming = false
 			m.streamChan = nil
+			m.endTurnProfile()
 			m.messages = append(m.messages, ui.FormatError(msg.chunk.Error))
 			m.updateViewport()
 			return m, nil
@@ -206,6 +226,9 @@ func (m *chatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			toolCall := msg.chunk.ToolCall
 			if toolCall.Result != "" {
 				m.messages = append(m.messages, ui.FormatToolCall(toolCall.Name, toolCall.Args))
+				if toolCall.Reduced != "" {
+					m.messages = append(m.messages, ui.FormatToolResultNote(toolCall.Reduced))
+				}
 
 				m.updateViewport()
 			}
@@ -225,10 +248,11 @@ func (m *chatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if msg.chunk.Done {
 			m.streaming = false
 			m.streamChan = nil
+			if m.turnProfile != nil {
+				m.ctxManager.SetProfile(m.turnProfile.Name, m.turnProfile.Model)
+			}
 			m.ctxManager.AddAssistantMessage(m.currentResp)
-
-			// Clear attached files after successful send
-			m.attachedFiles = nil
+			m.endTurnProfile()
 
 			// Format the complete response with syntax highlighting
 			formatted, err := ui.FormatResponse(m.currentResp)
@@ -245,7 +269,18 @@ func (m *chatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.messages = append(m.messages, "")
 			m.messages = append(m.messages, ui.FormatSeparator())
 			m.updateViewport()
-			return m, nil
+
+			m.autosave()
+
+			var cmds []tea.Cmd
+			if m.shouldAutoTitle() {
+				cmds = append(cmds, m.generateTitle())
+			}
+			if m.shouldAutoCompact() {
+				cmds = append(cmds, m.compact(m.cfg.Context.CompactKeepTurns, true))
+				m.updateViewport()
+			}
+			return m, tea.Batch(cmds...)
 		}
 
 		// Continue reading from stream
@@ -254,9 +289,30 @@ func (m *chatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case preparedMsg:
+		if msg.outline != "" {
+			m.outline.file.Content = msg.outline
+		}
+		if msg.outlineErr != nil {
+			m.AddMessage(ui.FormatError(msg.outlineErr))
+		}
+		if msg.retrieved != nil {
+			m.applyRetrieval(*msg.retrieved)
+		}
+		return m, m.sendRequest()
+
+	case titleMsg:
+		m.applyTitle(msg.title)
+		return m, nil
+
+	case compactMsg:
+		m.applyCompaction(msg)
+		return m, nil
+
 	case errMsg:
 		m.err = msg.err
 		m.streaming = false
+		m.endTurnProfile()
 		m.messages = append(m.messages, ui.FormatError(msg.err))
 		m.updateViewport()
 		return m, nil
@@ -265,47 +321,70 @@ func (m *chatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(tiCmd, vpCmd)
 }
 
-func (m *chatModel) streamResponse() tea.Cmd {
-	// Start streaming
+// startStream requests a response to the conversation, once the project
+// outline and the retrieved excerpts going with it are prepared off the UI
+// goroutine (see preparedMsg)
+func (m *chatModel) startStream() tea.Cmd {
+	m.streaming = true
+	m.currentResp = ""
+
+	refreshOutline, retrieve := m.outlineRefresh(), m.retriever()
+	if refreshOutline == nil && retrieve == nil {
+		return m.sendRequest()
+	}
 	return func() tea.Msg {
-		ctx := context.Background()
+		var msg preparedMsg
+		if refreshOutline != nil {
+			msg.outline, msg.outlineErr = refreshOutline()
+		}
+		if retrieve != nil {
+			res := retrieve()
+			msg.retrieved = &res
+		}
+		return msg
+	}
+}
 
-		// Get messages from context manager
-		messages := m.ctxManager.GetMessages()
-
-		// If we have attached or context files, modify the last user message
-		if len(m.attachedFiles) > 0 || len(m.contextFiles) > 0 {
-			if len(messages) > 0 {
-				lastMsg := &messages[len(messages)-1]
-
-				// Combine attached and context files
-				allFiles := append([]*fileprocessor.FileAttachment{}, m.attachedFiles...)
-				allFiles = append(allFiles, m.contextFiles...)
-
-				// Separate images from text content
-				var images []string
-				var textContent strings.Builder
-				textContent.WriteString(lastMsg.Content)
-
-				for _, file := range allFiles {
-					switch file.Type {
-					case "image":
-						images = append(images, file.Content)
-					case "pdf", "text", "code":
-						textContent.WriteString(fmt.Sprintf("\n\n--- Content from %s ---\n%s\n--- End of %s ---",
-							file.Name, file.Content, file.Name))
-					}
-				}
+// sendRequest shows the assistant placeholder and streams the response to the conversation
+func (m *chatModel) sendRequest() tea.Cmd {
+	messages := m.requestMessages()
+	m.messages = append(m.messages, ui.AssistantStyle.Render("Assistant: "))
+	m.updateViewport()
 
-				// Update the message
-				lastMsg.Content = textContent.String()
-				if len(images) > 0 {
-					lastMsg.Images = images
-				}
-			}
-		}
+	return m.streamResponse(messages)
+}
+
+// endTurnProfile goes back to the session profile after a turn answered by another one (/retry <profile>)
+func (m *chatModel) endTurnProfile() {
+	if m.turnProfile == nil {
+		return
+	}
+	m.turnProfile, m.turnProvider = nil, nil
+	m.ctxManager.SetProfile(m.Profile.Name, m.Profile.Model)
+}
+
+// requestMessages builds the messages of the next request: the history, with
+// the context files going with every request on the last prompt, trimmed to
+// the context window of the answering profile
+func (m *chatModel) requestMessages() []provider.Message {
+	// A copy, so that what is added for this request only stays out of the history
+	messages := slices.Clone(m.ctxManager.GetMessages())
+	m.placeContextFiles(messages)
+
+	return m.fitContext(messages)
+}
+
+func (m *chatModel) streamResponse(messages []provider.Message) tea.Cmd {
+	prov := m.provider
+	if m.turnProvider != nil {
+		prov = m.turnProvider
+	}
+
+	// Start streaming
+	return func() tea.Msg {
+		ctx := context.Background()
 
-		chunkChan, err := m.provider.Stream(ctx, messages)
+		chunkChan, err := prov.Stream(ctx, messages)
 		if err != nil {
 			return errMsg{err}
 		}
@@ -350,15 +429,15 @@ func (m *chatModel) loadChat(path string) error {
 		return fmt.Errorf("failed to load chat: %w", err)
 	}
 
-	// Reset the chat messages
-	m.messages = make([]string, 0)
+	// Resume on the profile the chat was last saved with
+	profileNotice := m.restoreProfile()
 
 	// Update the system context in the profile, and use the one set for the conversation
 	messages := m.ctxManager.GetMessages()
 
 	if len(messages) > 0 {
 		firstMessage := &messages[0]
-		if firstMessage.Role == provider.RoleSystem {
+		if firstMessage.Role == provider.RoleSystem && !ctxmanager.IsSummary(*firstMessage) {
 			m.Profile.SystemContext = &firstMessage.Content
 		} else {
 			// No system message, clear the profile system context
@@ -373,7 +452,26 @@ func (m *chatModel) loadChat(path string) error {
 	}
 	m.ctxManager.SetSystemMessage(systemContext)
 
-	// Attach all the context messages to the chat view
+	numMessages := m.renderHistory()
+
+	m.messages = append(m.messages, ui.FormatSuccess(fmt.Sprintf("Chat loaded from '%s', %d messages", path, numMessages)))
+	if profileNotice != "" {
+		m.messages = append(m.messages, ui.FormatInfo(profileNotice))
+	}
+	if pinNotice := m.restorePinnedFiles(); pinNotice != "" {
+		m.messages = append(m.messages, ui.FormatInfo(pinNotice))
+	}
+
+	m.updateViewport()
+
+	return nil
+}
+
+// renderHistory rebuilds the chat view from the conversation context. It
+// returns the number of user and assistant messages.
+func (m *chatModel) renderHistory() int {
+	m.messages = make([]string, 0)
+
 	numMessages := 0
 	for _, msg := range m.ctxManager.GetMessages() {
 		if msg.Role != provider.RoleSystem {
@@ -382,7 +480,7 @@ func (m *chatModel) loadChat(path string) error {
 
 		var formatted string
 		if msg.Role == provider.RoleUser {
-			formatted = ui.FormatUserMessage(msg.Content)
+			formatted = formatUserMessage(msg.Content, msg.Attachments)
 		} else if msg.Role == provider.RoleAssistant {
 			// Format the response with syntax highlighting
 			resp, err := ui.FormatResponse(msg.Content)
@@ -390,21 +488,122 @@ func (m *chatModel) loadChat(path string) error {
 				// If formatting fails, use the original response
 				resp = msg.Content
 			}
-			// Add the "Assistant:" prefix after formatting
-			formatted = ui.AssistantStyle.Render("Assistant:\n") + resp
+			// Add the "Assistant:" prefix after formatting, with the model that answered if known
+			label := "Assistant:"
+			if msg.Meta != nil && msg.Meta.Model != "" {
+				label = fmt.Sprintf("Assistant (%s):", msg.Meta.Model)
+			}
+			formatted = ui.AssistantStyle.Render(label+"\n") + resp
+		} else if ctxmanager.IsSummary(msg) {
+			formatted = ui.FormatCompacted(msg.Meta.Compacted, strings.TrimPrefix(msg.Content, ctxmanager.SummaryPrefix), msg.Meta.Transcript)
 		} else if msg.Role == provider.RoleSystem {
 			formatted = ui.FormatSystemMessage(msg.Content)
 		}
 
+		if msg.Meta != nil && msg.Meta.Pinned {
+			formatted = ui.InfoStyle.Render("📌 pinned") + "\n" + formatted
+		}
+
 		m.messages = append(m.messages, formatted)
 		m.messages = append(m.messages, "")
 		m.messages = append(m.messages, ui.FormatSeparator())
 	}
 
-	m.messages = append(m.messages, ui.FormatSuccess(fmt.Sprintf("Chat loaded from '%s', %d messages", path, numMessages)))
+	return numMessages
+}
 
-	m.updateViewport()
+// messageIndex converts a message number, as used by chat commands (user and
+// assistant messages counted from 1), into an index in the conversation context
+func (m *chatModel) messageIndex(n int) (int, error) {
+	count := 0
+	for i, msg := range m.ctxManager.GetMessages() {
+		if msg.Role == provider.RoleSystem {
+			continue
+		}
+		count++
+		if count == n {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no message %d, the conversation has %d", n, count)
+}
+
+// messageNumber converts an index in the conversation context into a message
+// number as used by chat commands, the inverse of messageIndex
+func (m *chatModel) messageNumber(index int) int {
+	return countNonSystem(m.ctxManager.GetMessages()[:index+1])
+}
+
+// messageCount returns the number of user and assistant messages
+func (m *chatModel) messageCount() int {
+	return countNonSystem(m.ctxManager.GetMessages())
+}
+
+func countNonSystem(messages []provider.Message) int {
+	count := 0
+	for _, msg := range messages {
+		if msg.Role != provider.RoleSystem {
+			count++
+		}
+	}
+	return count
+}
+
+// preview returns the first line of text, cut to at most n characters
+func preview(text string, n int) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	if runes := []rune(line); len(runes) > n {
+		line = string(runes[:n]) + "…"
+	}
+	return line
+}
+
+// restoreProfile switches to the profile recorded in the loaded chat metadata,
+// unless the profile was chosen explicitly. It returns a notice for the user
+// ("" when nothing changed), and records the active profile in the metadata.
+func (m *chatModel) restoreProfile() string {
+	defer m.ctxManager.SetProfile(m.Profile.Name, m.Profile.Model)
+
+	recorded := m.ctxManager.Metadata().Profile
+	if recorded == "" || recorded == m.Profile.Name {
+		return ""
+	}
+	if m.profileLocked {
+		return fmt.Sprintf("Chat was saved with profile '%s', continuing with '%s'", recorded, m.Profile.Name)
+	}
+
+	profile, err := m.cfg.GetProfile(recorded)
+	if err != nil {
+		return fmt.Sprintf("Chat was saved with profile '%s' which no longer exists, continuing with '%s'", recorded, m.Profile.Name)
+	}
+
+	m.Profile = profile
+	m.provider = provider.NewFromProfile(profile)
+	return fmt.Sprintf("Resumed on profile '%s' (%s)", profile.Name, profile.Model)
+}
+
+// autosave persists the conversation to the session autosave, if enabled
+func (m *chatModel) autosave() {
+	if m.autosaver == nil {
+		return
+	}
+	if err := m.autosaver.Save(m.ctxManager); err != nil {
+		m.AddMessage(ui.FormatError(fmt.Errorf("autosave failed: %w", err)))
+	}
+}
 
+// SetAutosaver enables autosaving of every completed turn
+func (m *chatModel) SetAutosaver(a *ctxmanager.Autosaver) {
+	m.autosaver = a
+}
+
+// ResumeAutosave loads an autosaved session. Unlike a saved chat, the autosave
+// is not used as the /save target.
+func (m *chatModel) ResumeAutosave(path string) error {
+	if err := m.loadChat(path); err != nil {
+		return err
+	}
+	m.AddMessage(ui.FormatInfo("Resumed autosaved session, use /save to keep it as a named chat"))
 	return nil
 }
 
@@ -418,10 +617,62 @@ func (m *chatModel) LoadChatHandler(path string) error {
 
 func (m *chatModel) AttachFiles(files []*fileprocessor.FileAttachment) {
 	m.attachedFiles = append(m.attachedFiles, files...)
+	m.recordFiles(files)
 }
 
 func (m *chatModel) AddContextFiles(files []*fileprocessor.FileAttachment) {
 	m.contextFiles = append(m.contextFiles, files...)
+	m.recordFiles(files)
+}
+
+// recordFiles keeps track of attached file paths in the conversation metadata
+func (m *chatModel) recordFiles(files []*fileprocessor.FileAttachment) {
+	for _, file := range files {
+		m.ctxManager.AddFiles(absPath(file.Path))
+	}
+}
+
+// restorePinnedFiles adds the pinned files of a loaded conversation to the
+// context, reading them again. It returns a notice for the user, "" if none.
+func (m *chatModel) restorePinnedFiles() string {
+	var (
+		restored int
+		missing  []string
+	)
+	for _, path := range m.ctxManager.PinnedFiles() {
+		if slices.ContainsFunc(m.contextFiles, func(f *fileprocessor.FileAttachment) bool { return absPath(f.Path) == path }) {
+			continue
+		}
+		files, err := fileprocessor.ProcessFiles([]string{path})
+		if err != nil {
+			missing = append(missing, path)
+			continue
+		}
+		m.contextFiles = append(m.contextFiles, files...)
+		restored++
+	}
+
+	var notice string
+	if restored > 0 {
+		notice = fmt.Sprintf("Restored %d pinned context file(s)", restored)
+	}
+	if len(missing) > 0 {
+		notice = strings.TrimSpace(notice + "\nCould not read pinned file(s): " + strings.Join(missing, ", ") + " (/unpin <file> to forget them)")
+	}
+	return notice
+}
+
+// absPath returns the absolute form of a path, the path itself if that fails
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// LockProfile keeps the current profile when loading a chat saved with another one
+func (m *chatModel) LockProfile() {
+	m.profileLocked = true
 }
 
 func (m *chatModel) SetContextDir(dir string) {
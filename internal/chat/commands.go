@@ -4,18 +4,27 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/KooQix/term-ai/internal/config"
+	ctxmanager "github.com/KooQix/term-ai/internal/context"
+	"github.com/KooQix/term-ai/internal/export"
 	"github.com/KooQix/term-ai/internal/fileprocessor"
 	"github.com/KooQix/term-ai/internal/provider"
+	"github.com/KooQix/term-ai/internal/search"
 	"github.com/KooQix/term-ai/internal/ui"
 	"github.com/KooQix/term-ai/internal/utils"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
+const (
+	searchLimit      = 20 // caps the number of /search matches shown in the chat
+	branchPreviewLen = 60 // characters of the last message shown by /branches
+)
+
 type commandHandler struct {
 	m *chatModel
 }
@@ -62,10 +71,49 @@ func (c *commandHandler) handle(cmd string) (tea.Model, tea.Cmd) {
 		c.removeContext(args)
 	case "/add-message":
 		c.addMessage(args)
+	case "/title":
+		c.setTitle(args)
+	case "/tags":
+		c.setTags(args)
 	case "/save":
 		c.saveChat(args)
 	case "/load":
 		c.LoadChat(args)
+	case "/search":
+		c.search(args)
+	case "/export":
+		c.exportChat(args)
+	case "/fork":
+		c.fork(args)
+	case "/branches":
+		c.listBranches()
+	case "/switch":
+		c.switchBranch(args)
+	case "/retry":
+		retryCmd := c.retry(args)
+		c.m.textarea.Reset()
+		c.m.updateViewport()
+		return c.m, retryCmd
+	case "/edit":
+		// The textarea is loaded with the message, don't reset it then
+		if !c.edit(args) {
+			c.m.textarea.Reset()
+		}
+		c.m.updateViewport()
+		return c.m, nil
+	case "/undo":
+		c.undo()
+	case "/pin":
+		c.pin(args, true)
+	case "/unpin":
+		c.pin(args, false)
+	case "/pins":
+		c.listPins()
+	case "/compact":
+		compactCmd := c.compact(args)
+		c.m.textarea.Reset()
+		c.m.updateViewport()
+		return c.m, compactCmd
 	case "/cp":
 		c.copyLastAssistantMessage()
 	case "/pager":
@@ -109,7 +157,7 @@ func (c *commandHandler) attach(args []string) {
 		if err != nil {
 			c.m.AddMessage(ui.FormatError(err))
 		} else {
-			c.m.attachedFiles = append(c.m.attachedFiles, attachments...)
+			c.m.AttachFiles(attachments)
 			c.m.AddMessage(ui.FormatSuccess(fmt.Sprintf("Attached %d file(s)", len(attachments))))
 		}
 
@@ -135,22 +183,46 @@ func (c *commandHandler) listContext() {
 		info := fmt.Sprintf("Context: %s (%d files)\n", c.m.contextDirPath, len(c.m.contextFiles))
 		info += "Files:\n"
 		for _, file := range c.m.contextFiles {
-			info += fmt.Sprintf("  • %s (%s)\n", file.Name, file.Type)
+			pin := ""
+			if c.m.ctxManager.IsFilePinned(absPath(file.Path)) {
+				pin = " 📌"
+			}
+			info += fmt.Sprintf("  • %s (%s)%s\n", file.Name, file.Type, pin)
 		}
 		c.m.AddMessage(ui.InfoStyle.Render(info))
 	}
 }
 
+// addContext adds files to the context, pinned to the conversation with --pin:
+// /context-add [--pin] <file> [...]
 func (c *commandHandler) addContext(args []string) {
-	if len(args) == 0 {
+	pin := false
+	paths := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "--pin" {
+			pin = true
+			continue
+		}
+		paths = append(paths, arg)
+	}
+
+	if len(paths) == 0 {
 		c.m.AddMessage(ui.FormatError(fmt.Errorf("/context-add requires at least one file path")))
 	} else {
 		// Process files and add to context
-		attachments, err := fileprocessor.ProcessFiles(args)
+		attachments, err := fileprocessor.ProcessFiles(paths)
 		if err != nil {
 			c.m.AddMessage(ui.FormatError(err))
 		} else {
 			c.m.AddContextFiles(attachments)
+			if pin {
+				for _, file := range attachments {
+					c.m.ctxManager.PinFile(absPath(file.Path))
+				}
+				c.m.autosave()
+				c.m.AddMessage(ui.FormatSuccess(fmt.Sprintf("Added and pinned %d file(s) to context", len(attachments))))
+				return
+			}
 			c.m.AddMessage(ui.FormatSuccess(fmt.Sprintf("Added %d file(s) to context", len(attachments))))
 		}
 
@@ -166,6 +238,11 @@ func (c *commandHandler) removeContext(args []string) {
 		for i, file := range c.m.contextFiles {
 			if file.Name == filename || file.Path == filename {
 				c.m.contextFiles = append(c.m.contextFiles[:i], c.m.contextFiles[i+1:]...)
+				// Not sent anymore, from the messages it was stored with either
+				unpinned := c.m.ctxManager.UnpinFile(absPath(file.Path))
+				if c.m.ctxManager.RemoveAttachments(absPath(file.Path)) > 0 || unpinned {
+					c.m.autosave()
+				}
 				removed = true
 				break
 			}
@@ -190,10 +267,49 @@ func (c *commandHandler) addMessage(args []string) {
 	}
 }
 
+func (c *commandHandler) setTitle(args []string) {
+	if len(args) == 0 {
+		title := c.m.ctxManager.Metadata().Title
+		if title == "" {
+			title = "(untitled)"
+		}
+		c.m.AddMessage(ui.InfoStyle.Render("Title: " + title))
+		return
+	}
+
+	title := strings.Join(args, " ")
+	c.m.ctxManager.SetTitle(title)
+	c.m.AddMessage(ui.FormatSuccess(fmt.Sprintf("Title set to '%s'", title)))
+}
+
+func (c *commandHandler) setTags(args []string) {
+	if len(args) == 0 {
+		tags := c.m.ctxManager.Metadata().Tags
+		if len(tags) == 0 {
+			c.m.AddMessage(ui.InfoStyle.Render("No tags"))
+		} else {
+			c.m.AddMessage(ui.InfoStyle.Render("Tags: " + strings.Join(tags, ", ")))
+		}
+		return
+	}
+
+	// "/tags -" removes all tags
+	if len(args) == 1 && args[0] == "-" {
+		c.m.ctxManager.SetTags(nil)
+		c.m.AddMessage(ui.FormatSuccess("Tags cleared"))
+		return
+	}
+
+	c.m.ctxManager.SetTags(args)
+	c.m.AddMessage(ui.FormatSuccess(fmt.Sprintf("Tags set to: %s", strings.Join(args, ", "))))
+}
+
 func (c *commandHandler) saveChat(args []string) {
 	// If the chatPath is already set (an no name/path is provided), use it as default
 	if c.m.chatPath != "" && len(args) == 0 {
-		// Save chat to existing path
+		// Save chat to existing path (a chat loaded from the legacy format is
+		// written next to it in the current one)
+		c.m.chatPath = ctxmanager.ChatFilePath(c.m.chatPath)
 		if err := c.m.ctxManager.Save(c.m.chatPath); err != nil {
 			c.m.AddMessage(ui.FormatError(fmt.Errorf("failed to save chat: %v", err)))
 		} else {
@@ -202,43 +318,59 @@ func (c *commandHandler) saveChat(args []string) {
 		return
 	}
 
-	// Otherwise, expect a name and optional directory
-	if len(args) == 0 {
-		c.m.AddMessage(ui.FormatError(fmt.Errorf("/save requires a chat name")))
-	} else {
-		name := args[0]
-		dir, err := config.GetDefaultChatsPath()
-		if err != nil {
-			c.m.AddMessage(ui.FormatError(fmt.Errorf("failed to get default chats path: %w", err)))
+	// Otherwise, expect a name (defaulting to the conversation title) and optional directory
+	name := ""
+	if len(args) > 0 && args[0] != "-d" {
+		name = args[0]
+		args = args[1:]
+	}
+
+	dir, err := config.GetDefaultChatsPath()
+	if err != nil {
+		c.m.AddMessage(ui.FormatError(fmt.Errorf("failed to get default chats path: %w", err)))
+		return
+	}
+	if len(args) > 1 && args[0] == "-d" {
+		if absDir, err := utils.GetAbsolutePath(args[1]); err != nil {
+			c.m.AddMessage(ui.FormatError(fmt.Errorf("invalid directory path: %w", err)))
 			return
+		} else {
+			dir = absDir
 		}
-		if len(args) > 2 && args[1] == "-d" {
-			if absDir, err := utils.GetAbsolutePath(args[2]); err != nil {
-				c.m.AddMessage(ui.FormatError(fmt.Errorf("invalid directory path: %w", err)))
-				return
-			} else {
-				dir = absDir
-			}
-		}
+	}
 
-		// Save chat
-		if err := c.m.ctxManager.Save(filepath.Join(dir, name)); err != nil {
-			c.m.AddMessage(ui.FormatError(fmt.Errorf("failed to save chat: %v", err)))
-		} else {
-			c.m.AddMessage(ui.FormatSuccess(fmt.Sprintf("Conversation '%s' saved successfully", name)))
+	path := filepath.Join(dir, name)
+	if name == "" {
+		title := c.m.ctxManager.Metadata().Title
+		if title == "" {
+			c.m.AddMessage(ui.FormatError(fmt.Errorf("/save requires a chat name (the conversation has no title yet)")))
+			return
 		}
+		path = ctxmanager.UniqueChatPath(dir, title)
+		name = config.GetDisplayPath(path)
+	}
+
+	// Save chat
+	if err := c.m.ctxManager.Save(path); err != nil {
+		c.m.AddMessage(ui.FormatError(fmt.Errorf("failed to save chat: %v", err)))
+		return
+	}
+	c.m.AddMessage(ui.FormatSuccess(fmt.Sprintf("Conversation '%s' saved successfully", name)))
 
-		// Set the chatPath for future saves (so that subsequent /save commands without a name will overwrite the same file)
-		c.m.chatPath = filepath.Join(dir, name)
+	// Make chats saved out of the default directory searchable
+	if err := config.RegisterChatDir(dir); err != nil {
+		c.m.AddMessage(ui.FormatError(fmt.Errorf("failed to register chat directory: %w", err)))
 	}
 
+	// Set the chatPath for future saves (so that subsequent /save commands without a name will overwrite the same file)
+	c.m.chatPath = ctxmanager.ChatFilePath(path)
 }
 
 func (c *commandHandler) LoadChat(args []string) (tea.Model, tea.Cmd) {
 	if len(args) == 0 {
 		c.m.AddMessage(ui.FormatError(fmt.Errorf("/load requires a chat file path")))
 	} else {
-		path := args[0] + config.ChatFileExt
+		path := args[0]
 
 		// Looking for relative path in default chats directory
 		if !filepath.IsAbs(path) {
@@ -248,12 +380,16 @@ func (c *commandHandler) LoadChat(args []string) (tea.Model, tea.Cmd) {
 				return c.m, nil
 			}
 
-			if _, err := os.Stat(filepath.Join(defaultDir, path)); err == nil {
-				// File exists as given (relative to cwd), use it
-				path = filepath.Join(defaultDir, path)
+			if resolved, err := ctxmanager.ResolveChatFile(filepath.Join(defaultDir, path)); err == nil {
+				path = resolved
 			}
 		}
 
+		// Otherwise, relative to cwd (with or without extension)
+		if resolved, err := ctxmanager.ResolveChatFile(path); err == nil {
+			path = resolved
+		}
+
 		// Load chat
 		if absPath, err := utils.GetAbsolutePath(path); err != nil {
 			c.m.AddMessage(ui.FormatError(fmt.Errorf("invalid file path: %w", err)))
@@ -272,6 +408,340 @@ func (c *commandHandler) LoadChat(args []string) (tea.Model, tea.Cmd) {
 	return c.m, nil
 }
 
+func (c *commandHandler) search(args []string) {
+	if len(args) == 0 {
+		c.m.AddMessage(ui.FormatError(fmt.Errorf("/search requires a query")))
+		return
+	}
+
+	q, err := search.ParseQuery(strings.Join(args, " "))
+	if err != nil {
+		c.m.AddMessage(ui.FormatError(err))
+		return
+	}
+	q.Limit = searchLimit
+
+	hits, locked, err := search.Search(q, false)
+	if err != nil {
+		c.m.AddMessage(ui.FormatError(fmt.Errorf("search failed: %w", err)))
+		return
+	}
+	if locked > 0 {
+		c.m.AddMessage(ui.FormatInfo(fmt.Sprintf("%d encrypted chat(s) not searched, set $%s or chat.encryption.keyfile to include them", locked, ctxmanager.PassphraseEnv)))
+	}
+
+	result := search.Format(hits, q, func(s string) string { return ui.MatchStyle.Render(s) })
+	if len(hits) > 0 {
+		result += "\n\n" + ui.InfoStyle.Render("Open a chat with: /load <id>")
+	}
+	c.m.AddMessage(result)
+}
+
+// exportChat writes the current conversation to a file: /export [format] [path]
+func (c *commandHandler) exportChat(args []string) {
+	format := export.FormatHTML
+	if len(args) > 0 {
+		var err error
+		if format, err = export.ParseFormat(args[0]); err != nil {
+			c.m.AddMessage(ui.FormatError(err))
+			return
+		}
+	}
+
+	conv := c.m.ctxManager.Conversation()
+	if len(conv.Messages) == 0 {
+		c.m.AddMessage(ui.FormatError(fmt.Errorf("nothing to export yet")))
+		return
+	}
+
+	path := export.DefaultFileName(conv, c.m.chatPath, format)
+	if len(args) > 1 {
+		path = strings.Join(args[1:], " ")
+	}
+
+	if err := export.WriteFile(path, conv, format); err != nil {
+		c.m.AddMessage(ui.FormatError(err))
+		return
+	}
+	c.m.AddMessage(ui.FormatSuccess(fmt.Sprintf("Exported conversation to %s", path)))
+}
+
+// fork starts a new branch after message n (the last one by default): /fork [n] [name]
+func (c *commandHandler) fork(args []string) {
+	keep := len(c.m.ctxManager.GetMessages())
+	if len(args) > 0 {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			if keep, err = c.m.messageIndex(n); err != nil {
+				c.m.AddMessage(ui.FormatError(err))
+				return
+			}
+			keep++
+			args = args[1:]
+		}
+	}
+
+	from := c.m.ctxManager.ActiveBranch()
+	branch, err := c.m.ctxManager.Fork(keep, strings.Join(args, " "))
+	if err != nil {
+		c.m.AddMessage(ui.FormatError(err))
+		return
+	}
+
+	c.m.renderHistory()
+	c.m.autosave()
+	c.m.AddMessage(ui.FormatSuccess(fmt.Sprintf("Started branch %d from branch %d, the next message continues it (/branches to list, /switch %d to come back)", branch+1, from+1, from+1)))
+}
+
+func (c *commandHandler) listBranches() {
+	branches := c.m.ctxManager.Branches()
+	if len(branches) < 2 {
+		c.m.AddMessage(ui.InfoStyle.Render("This conversation has a single branch, use /fork [n] to start another one"))
+		return
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Branches (%d):\n", len(branches))
+	for _, b := range branches {
+		marker := " "
+		if b.Active {
+			marker = "*"
+		}
+		fmt.Fprintf(&sb, "%s %d", marker, b.Index+1)
+		if b.Name != "" {
+			fmt.Fprintf(&sb, " %q", b.Name)
+		}
+		fmt.Fprintf(&sb, " - %d messages", b.Messages)
+		if b.Parent >= 0 {
+			fmt.Fprintf(&sb, ", forked from %d after message %d", b.Parent+1, b.ForkAfter)
+		}
+		if b.Last != nil {
+			fmt.Fprintf(&sb, "\n    %s: %s", b.Last.Role, preview(b.Last.Content, branchPreviewLen))
+		}
+		sb.WriteString("\n")
+	}
+	c.m.AddMessage(ui.InfoStyle.Render(strings.TrimRight(sb.String(), "\n")))
+}
+
+// switchBranch makes another branch active: /switch <number|name>
+func (c *commandHandler) switchBranch(args []string) {
+	if len(args) == 0 {
+		c.m.AddMessage(ui.FormatError(fmt.Errorf("/switch requires a branch number or name (see /branches)")))
+		return
+	}
+
+	target := -1
+	if n, err := strconv.Atoi(args[0]); err == nil {
+		target = n - 1
+	} else {
+		name := strings.Join(args, " ")
+		for _, b := range c.m.ctxManager.Branches() {
+			if strings.EqualFold(b.Name, name) {
+				target = b.Index
+				break
+			}
+		}
+		if target < 0 {
+			c.m.AddMessage(ui.FormatError(fmt.Errorf("no branch named '%s'", name)))
+			return
+		}
+	}
+
+	if err := c.m.ctxManager.SwitchBranch(target); err != nil {
+		c.m.AddMessage(ui.FormatError(err))
+		return
+	}
+
+	count := c.m.renderHistory()
+	c.m.autosave()
+	c.m.AddMessage(ui.FormatSuccess(fmt.Sprintf("Switched to branch %d, %d messages", target+1, count)))
+}
+
+// retry regenerates the answer to the last prompt, optionally with another
+// profile for this turn only: /retry [profile]
+func (c *commandHandler) retry(args []string) tea.Cmd {
+	lastUser := c.m.ctxManager.LastIndex(provider.RoleUser)
+	if lastUser < 0 {
+		c.m.AddMessage(ui.FormatError(fmt.Errorf("nothing to retry yet")))
+		return nil
+	}
+
+	if len(args) > 0 && args[0] != c.m.Profile.Name {
+		profile, err := c.m.cfg.GetProfile(args[0])
+		if err != nil {
+			c.m.AddMessage(ui.FormatError(err))
+			return nil
+		}
+		c.m.turnProfile = profile
+		c.m.turnProvider = provider.NewFromProfile(profile)
+	}
+
+	// The last prompt may have failed and have no answer yet: it is sent again as is
+	c.m.ctxManager.Truncate(lastUser + 1)
+	c.m.renderHistory()
+	if c.m.turnProfile != nil {
+		c.m.AddMessage(ui.FormatInfo(fmt.Sprintf("Retrying with profile '%s' (%s)", c.m.turnProfile.Name, c.m.turnProfile.Model)))
+	}
+	return c.m.startStream()
+}
+
+// edit loads one of the user's messages (the last one by default) back into
+// the input and drops it from the history along with everything after it:
+// /edit [n]. Returns whether a message was loaded.
+func (c *commandHandler) edit(args []string) bool {
+	index := c.m.ctxManager.LastIndex(provider.RoleUser)
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			c.m.AddMessage(ui.FormatError(fmt.Errorf("/edit takes a message number, got '%s'", args[0])))
+			return false
+		}
+		if index, err = c.m.messageIndex(n); err != nil {
+			c.m.AddMessage(ui.FormatError(err))
+			return false
+		}
+	}
+	if index < 0 {
+		c.m.AddMessage(ui.FormatError(fmt.Errorf("no message to edit yet")))
+		return false
+	}
+
+	msg := c.m.ctxManager.GetMessages()[index]
+	if msg.Role != provider.RoleUser {
+		c.m.AddMessage(ui.FormatError(fmt.Errorf("message %s is not one of yours", args[0])))
+		return false
+	}
+
+	c.m.ctxManager.Truncate(index)
+	c.m.renderHistory()
+
+	// Files attached to the message go with it again, context files are sent again anyway
+	for _, a := range msg.Attachments {
+		if !slices.ContainsFunc(c.m.contextFiles, func(f *fileprocessor.FileAttachment) bool { return absPath(f.Path) == a.Path }) {
+			c.m.attachedFiles = append(c.m.attachedFiles, fileprocessor.FromAttachment(a))
+		}
+	}
+
+	c.m.AddMessage(ui.FormatInfo("Editing your message, send it to continue the conversation from there (use /fork first to keep the current thread)"))
+	c.m.textarea.SetValue(msg.Content)
+	return true
+}
+
+// undo drops the last exchange: the last prompt and its answer
+func (c *commandHandler) undo() {
+	lastUser := c.m.ctxManager.LastIndex(provider.RoleUser)
+	if lastUser < 0 {
+		c.m.AddMessage(ui.FormatError(fmt.Errorf("nothing to undo")))
+		return
+	}
+
+	c.m.ctxManager.Truncate(lastUser)
+	c.m.renderHistory()
+	c.m.autosave()
+	c.m.AddMessage(ui.FormatSuccess("Removed the last exchange"))
+}
+
+// pin pins (or unpins) message n, the last one by default, or a context file,
+// so that trimming and compaction keep it: /pin [n|file], /unpin [n|file]
+func (c *commandHandler) pin(args []string, pinned bool) {
+	verb := "Pinned"
+	if !pinned {
+		verb = "Unpinned"
+	}
+
+	if len(args) > 0 {
+		if _, err := strconv.Atoi(args[0]); err != nil {
+			c.pinFile(strings.Join(args, " "), pinned, verb)
+			return
+		}
+	}
+
+	n := c.m.messageCount()
+	if len(args) > 0 {
+		n, _ = strconv.Atoi(args[0])
+	}
+	if n == 0 {
+		c.m.AddMessage(ui.FormatError(fmt.Errorf("no message to pin yet")))
+		return
+	}
+
+	index, err := c.m.messageIndex(n)
+	if err == nil {
+		err = c.m.ctxManager.SetPinned(index, pinned)
+	}
+	if err != nil {
+		c.m.AddMessage(ui.FormatError(err))
+		return
+	}
+
+	c.m.renderHistory()
+	c.m.autosave()
+	c.m.AddMessage(ui.FormatSuccess(fmt.Sprintf("%s message %d", verb, n)))
+}
+
+// pinFile pins (or unpins) a file of the context, by name or path
+func (c *commandHandler) pinFile(name string, pinned bool, verb string) {
+	for _, file := range c.m.contextFiles {
+		if file.Name != name && file.Path != name {
+			continue
+		}
+		if pinned {
+			c.m.ctxManager.PinFile(absPath(file.Path))
+		} else if !c.m.ctxManager.UnpinFile(absPath(file.Path)) {
+			c.m.AddMessage(ui.FormatError(fmt.Errorf("'%s' is not pinned", name)))
+			return
+		}
+		c.m.autosave()
+		c.m.AddMessage(ui.FormatSuccess(fmt.Sprintf("%s '%s'", verb, name)))
+		return
+	}
+	c.m.AddMessage(ui.FormatError(fmt.Errorf("'%s' is neither a message number nor a context file (add files with /context-add --pin)", name)))
+}
+
+func (c *commandHandler) listPins() {
+	indexes := c.m.ctxManager.PinnedIndexes()
+	files := c.m.ctxManager.PinnedFiles()
+	if len(indexes) == 0 && len(files) == 0 {
+		c.m.AddMessage(ui.InfoStyle.Render("Nothing pinned, use /pin [n] or /context-add --pin <file>"))
+		return
+	}
+
+	var sb strings.Builder
+	messages := c.m.ctxManager.GetMessages()
+	if len(indexes) > 0 {
+		fmt.Fprintf(&sb, "Pinned messages (%d):\n", len(indexes))
+		for _, i := range indexes {
+			fmt.Fprintf(&sb, "  %d %s: %s\n", c.m.messageNumber(i), messages[i].Role, preview(messages[i].Content, branchPreviewLen))
+		}
+	}
+	if len(files) > 0 {
+		fmt.Fprintf(&sb, "Pinned files (%d):\n", len(files))
+		for _, path := range files {
+			fmt.Fprintf(&sb, "  • %s\n", path)
+		}
+	}
+	c.m.AddMessage(ui.InfoStyle.Render(strings.TrimRight(sb.String(), "\n")))
+}
+
+// compact replaces the older turns with a summary, keeping the last n turns
+// (compact_keep_turns by default) verbatim: /compact [n]
+func (c *commandHandler) compact(args []string) tea.Cmd {
+	if c.m.streaming || c.m.compacting {
+		c.m.AddMessage(ui.FormatError(fmt.Errorf("wait for the current response before compacting")))
+		return nil
+	}
+
+	keep := c.m.cfg.Context.CompactKeepTurns
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			c.m.AddMessage(ui.FormatError(fmt.Errorf("/compact takes the number of recent turns to keep, got '%s'", args[0])))
+			return nil
+		}
+		keep = n
+	}
+	return c.m.compact(keep, false)
+}
+
 // pagerExec is a tea.ExecCommand that dumps text to stdout and blocks until
 // the user presses Enter (\n or \r). Implemented in Go rather than as a shell
 // `cat + read` so we can control stdin/stdout precisely — the shell version
@@ -0,0 +1,158 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/KooQix/term-ai/internal/config"
+	ctxmanager "github.com/KooQix/term-ai/internal/context"
+	"github.com/KooQix/term-ai/internal/provider"
+	"github.com/KooQix/term-ai/internal/ui"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	compactPrompt = "Summarize the conversation below so it can replace it in the assistant's context. " +
+		"Keep every fact, decision, file name, command, error message and code detail the rest of the conversation may need, " +
+		"and what was tried and did not work. Leave out pleasantries. Write a concise summary in plain markdown, without preamble."
+	compactTimeout    = 2 * time.Minute
+	compactExcerptLen = 8000 // bytes of each message sent to the summarizing profile
+)
+
+type compactMsg struct {
+	keepTurns  int
+	candidates []provider.Message
+	summary    string
+	transcript string
+	err        error
+}
+
+// shouldAutoCompact reports whether the conversation filled enough of the
+// context window to be compacted after a turn
+func (m *chatModel) shouldAutoCompact() bool {
+	threshold := m.cfg.Context.CompactThreshold
+	return threshold > 0 && !m.compacting && m.contextUsage() >= threshold
+}
+
+// compact saves the full transcript, then asks the summarizing profile (the
+// current one by default) for a summary of all but the last keepTurns turns.
+// The summary replaces them once received, see applyCompaction.
+func (m *chatModel) compact(keepTurns int, auto bool) tea.Cmd {
+	candidates := m.ctxManager.CompactCandidates(keepTurns)
+	if len(candidates) == 0 {
+		if !auto {
+			m.AddMessage(ui.InfoStyle.Render(fmt.Sprintf("Nothing to compact: the conversation has no more than %d unpinned turns", keepTurns)))
+		}
+		return nil
+	}
+
+	prov := m.provider
+	if name := m.cfg.Context.CompactProfile; name != "" && name != m.Profile.Name {
+		profile, err := m.cfg.GetProfile(name)
+		if err != nil {
+			m.AddMessage(ui.FormatError(fmt.Errorf("failed to compact: %w", err)))
+			return nil
+		}
+		prov = provider.NewFromProfile(profile)
+	}
+
+	// Nothing is lost: the conversation as it is now is kept on disk
+	transcript, err := m.saveTranscript()
+	if err != nil {
+		m.AddMessage(ui.FormatError(fmt.Errorf("failed to save the transcript, nothing was compacted: %w", err)))
+		return nil
+	}
+
+	m.compacting = true
+	reason := ""
+	if auto {
+		reason = fmt.Sprintf(" (the conversation fills %d%% of the context window)", m.contextUsage())
+	}
+	m.AddMessage(ui.FormatInfo(fmt.Sprintf("Compacting %d earlier messages into a summary%s…", len(candidates), reason)))
+
+	conversation := compactExcerpt(candidates)
+
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), compactTimeout)
+		defer cancel()
+
+		summary, err := prov.Complete(ctx, []provider.Message{
+			{Role: provider.RoleSystem, Content: compactPrompt},
+			{Role: provider.RoleUser, Content: conversation},
+		})
+		if err == nil && strings.TrimSpace(summary) == "" {
+			err = fmt.Errorf("the summary came back empty")
+		}
+		return compactMsg{
+			keepTurns:  keepTurns,
+			candidates: candidates,
+			summary:    strings.TrimSpace(summary),
+			transcript: transcript,
+			err:        err,
+		}
+	}
+}
+
+// applyCompaction replaces the summarized messages with the summary
+func (m *chatModel) applyCompaction(msg compactMsg) {
+	m.compacting = false
+	if msg.err != nil {
+		m.AddMessage(ui.FormatError(fmt.Errorf("failed to compact the conversation: %w", msg.err)))
+		m.updateViewport()
+		return
+	}
+
+	count, err := m.ctxManager.Compact(msg.keepTurns, msg.candidates, msg.summary, msg.transcript)
+	if err != nil {
+		m.AddMessage(ui.FormatError(err))
+		m.updateViewport()
+		return
+	}
+
+	m.renderHistory()
+	m.autosave()
+	m.AddMessage(ui.FormatSuccess(fmt.Sprintf("Compacted %d messages into a summary, the conversation now fills %d%% of the context window", count, m.contextUsage())))
+	m.updateViewport()
+}
+
+// saveTranscript writes the conversation as it is to the transcripts directory
+func (m *chatModel) saveTranscript() (string, error) {
+	dir, err := config.GetTranscriptsPath()
+	if err != nil {
+		return "", err
+	}
+
+	conv := m.ctxManager.Conversation()
+	path := ctxmanager.UniqueChatPath(dir, time.Now().Format("20060102-150405")+" "+conv.Metadata.Title)
+	if err := ctxmanager.WriteConversation(path, conv); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// compactExcerpt renders the messages to summarize as a transcript
+func compactExcerpt(messages []provider.Message) string {
+	var sb strings.Builder
+	for _, msg := range messages {
+		content, _ := msg.RequestContent()
+		if len(content) > compactExcerptLen {
+			content = strings.ToValidUTF8(content[:compactExcerptLen], "") + "\n[…]"
+		}
+
+		switch {
+		case ctxmanager.IsSummary(msg):
+			sb.WriteString("Summary of earlier messages: " + strings.TrimPrefix(content, ctxmanager.SummaryPrefix))
+		case msg.Role == provider.RoleTool:
+			sb.WriteString(fmt.Sprintf("tool result (%s): %s", msg.Name, content))
+		default:
+			sb.WriteString(string(msg.Role) + ": " + content)
+			for _, call := range msg.ToolCalls {
+				sb.WriteString(fmt.Sprintf("\n[called %s with %s]", call.Function.Name, call.Function.Arguments))
+			}
+		}
+		sb.WriteString("\n\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,47 @@
+package chat
+
+import (
+	"slices"
+
+	"github.com/KooQix/term-ai/internal/fileprocessor"
+)
+
+// outlineContext is the project outline sent in place of the files of a
+// directory (chat --dir-mode outline)
+type outlineContext struct {
+	opts  fileprocessor.ScanOptions
+	cache *fileprocessor.OutlineCache
+	file  *fileprocessor.FileAttachment
+}
+
+// SetOutline adds a project outline to the context. It goes with every
+// request, rebuilt beforehand so it follows the changes made to the files.
+func (m *chatModel) SetOutline(outline *fileprocessor.ProjectOutline, opts fileprocessor.ScanOptions, cache *fileprocessor.OutlineCache) {
+	m.outline = &outlineContext{opts: opts, cache: cache, file: outline.Attachment()}
+	m.AddContextFiles([]*fileprocessor.FileAttachment{m.outline.file})
+}
+
+// isOutline reports whether a context file is the project outline
+func (m *chatModel) isOutline(file *fileprocessor.FileAttachment) bool {
+	return m.outline != nil && file == m.outline.file
+}
+
+// outlineRefresh returns a function rebuilding the project outline, only the
+// changed files being parsed again, nil when the outline isn't in the
+// context. It runs off the UI goroutine and returns "" if the directory can't
+// be read, the previous outline being kept then, and the error saving the
+// cache if any.
+func (m *chatModel) outlineRefresh() func() (string, error) {
+	if m.outline == nil || !slices.Contains(m.contextFiles, m.outline.file) {
+		return nil
+	}
+
+	o := m.outline
+	return func() (string, error) {
+		outline, err := fileprocessor.BuildOutline(o.file.Path, o.opts, o.cache)
+		if err != nil {
+			return "", nil
+		}
+		return outline.Content, o.cache.Save()
+	}
+}
@@ -0,0 +1,137 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/KooQix/term-ai/internal/config"
+	"github.com/KooQix/term-ai/internal/fileprocessor"
+	"github.com/KooQix/term-ai/internal/provider"
+	"github.com/KooQix/term-ai/internal/rag"
+	"github.com/KooQix/term-ai/internal/ui"
+)
+
+// retrievalContext holds the index of a directory whose most relevant chunks
+// are sent with each request in place of its files (chat --dir-mode rag)
+type retrievalContext struct {
+	index *rag.Index
+	opts  fileprocessor.ScanOptions
+	topK  int
+	file  *fileprocessor.FileAttachment
+}
+
+// SetRetrieval adds the retrieved excerpts of an indexed directory to the
+// context. They go with every request, retrieved again for its question.
+func (m *chatModel) SetRetrieval(index *rag.Index, opts fileprocessor.ScanOptions, topK int) {
+	m.retrieval = &retrievalContext{
+		index: index,
+		opts:  opts,
+		topK:  topK,
+		file: &fileprocessor.FileAttachment{
+			Path:     index.Root,
+			Type:     "text",
+			MimeType: "text/plain",
+			Name:     "retrieved excerpts (" + index.Root + ")",
+		},
+	}
+	m.AddContextFiles([]*fileprocessor.FileAttachment{m.retrieval.file})
+}
+
+// isRetrieval reports whether a context file holds the retrieved excerpts
+func (m *chatModel) isRetrieval(file *fileprocessor.FileAttachment) bool {
+	return m.retrieval != nil && file == m.retrieval.file
+}
+
+// retrieved is the outcome of a retrieval, applied to the context by applyRetrieval
+type retrieved struct {
+	content   string
+	citations []string
+	errs      []error
+}
+
+// retriever returns a function updating the index with the changed files,
+// then ranking its chunks against the last prompt and keeping the best ones
+// for the request, nil when the excerpts aren't in the context. It runs off
+// the UI goroutine.
+func (m *chatModel) retriever() func() retrieved {
+	r := m.retrieval
+	if r == nil || !slices.Contains(m.contextFiles, r.file) {
+		return nil
+	}
+
+	query := ""
+	messages := m.ctxManager.GetMessages()
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == provider.RoleUser {
+			query = messages[i].Content
+			break
+		}
+	}
+	profile := m.Profile
+	if m.turnProfile != nil {
+		profile = m.turnProfile
+	}
+
+	return func() retrieved {
+		var res retrieved
+		if _, err := r.index.Update(r.opts); err != nil {
+			res.errs = append(res.errs, fmt.Errorf("failed to update the index of %s: %w", r.index.Root, err))
+		}
+		vector, err := queryVector(r.index, profile, query)
+		if err != nil {
+			res.errs = append(res.errs, err)
+		}
+		// Saved once updated and embedded
+		if err := r.index.Save(); err != nil {
+			res.errs = append(res.errs, err)
+		}
+		results := r.index.Search(query, vector, r.topK)
+		if len(results) == 0 {
+			res.content = "No excerpt of the files of " + r.index.Root + " matches the question."
+			return res
+		}
+		res.content = rag.Format(results, r.index.Root)
+		for _, result := range results {
+			res.citations = append(res.citations, result.Citation())
+		}
+		return res
+	}
+}
+
+// applyRetrieval puts the retrieved excerpts in the context and shows where they come from
+func (m *chatModel) applyRetrieval(res retrieved) {
+	for _, err := range res.errs {
+		m.AddMessage(ui.FormatError(err))
+	}
+	m.retrieval.file.Content = res.content
+	if len(res.citations) > 0 {
+		m.AddMessage(ui.InfoStyle.Render("🔎 " + strings.Join(res.citations, ", ")))
+	}
+}
+
+// queryVector embeds the new chunks of the index and the query with the
+// embedding model of profile, nil if it has none or embedding fails (ranking
+// then falls back to BM25 alone, the error telling why). The index is left to
+// save.
+func queryVector(index *rag.Index, profile *config.Profile, query string) ([]float32, error) {
+	embedder, err := provider.NewEmbedder(profile)
+	if err != nil || strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	if _, err := index.Embed(ctx, embedder, profile.EmbeddingModel); err != nil {
+		return nil, fmt.Errorf("failed to embed the index, ranking by terms only: %w", err)
+	}
+
+	vectors, err := embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed the question, ranking by terms only: %w", err)
+	}
+	return vectors[0], nil
+}
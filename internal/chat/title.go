@@ -0,0 +1,112 @@
+package chat
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/KooQix/term-ai/internal/provider"
+	"github.com/KooQix/term-ai/internal/ui"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	titlePrompt = "Write a short title (at most 6 words) for the conversation below. " +
+		"Reply with the title only: no quotes, no trailing punctuation."
+	titleTimeout    = 30 * time.Second
+	titleExcerptLen = 2000 // bytes of each message sent to the titling profile
+	titleMaxLen     = 80
+)
+
+type titleMsg struct {
+	title string
+}
+
+// shouldAutoTitle reports whether a title should be generated now: auto titling
+// is on, the current profile allows it, and the conversation is untitled and
+// just completed its first exchange
+func (m *chatModel) shouldAutoTitle() bool {
+	if m.cfg == nil || !m.cfg.Chat.AutoTitle || m.Profile.DisableAutoTitle {
+		return false
+	}
+	if m.ctxManager.Metadata().Title != "" {
+		return false
+	}
+
+	exchanges := 0
+	for _, msg := range m.ctxManager.GetMessages() {
+		if msg.Role == provider.RoleAssistant {
+			exchanges++
+		}
+	}
+	return exchanges == 1
+}
+
+// generateTitle asks the titling profile (the current one by default) for a
+// short title of the first exchange. Failures are silent: the conversation just
+// stays untitled.
+func (m *chatModel) generateTitle() tea.Cmd {
+	prov := m.provider
+	if name := m.cfg.Chat.TitleProfile; name != "" && name != m.Profile.Name {
+		profile, err := m.cfg.GetProfile(name)
+		if err != nil {
+			return nil
+		}
+		prov = provider.NewFromProfile(profile)
+	}
+
+	var excerpt strings.Builder
+	for _, msg := range m.ctxManager.GetMessages() {
+		if msg.Role != provider.RoleUser && msg.Role != provider.RoleAssistant {
+			continue
+		}
+		content := msg.Content
+		if len(content) > titleExcerptLen {
+			content = strings.ToValidUTF8(content[:titleExcerptLen], "")
+		}
+		excerpt.WriteString(string(msg.Role) + ": " + content + "\n\n")
+	}
+	conversation := excerpt.String()
+
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+		defer cancel()
+
+		title, err := prov.Complete(ctx, []provider.Message{
+			{Role: provider.RoleSystem, Content: titlePrompt},
+			{Role: provider.RoleUser, Content: conversation},
+		})
+		if err != nil {
+			return nil
+		}
+		return titleMsg{title: cleanTitle(title)}
+	}
+}
+
+// cleanTitle keeps the first line of a generated title, without quotes,
+// markdown emphasis or trailing punctuation
+func cleanTitle(title string) string {
+	title = strings.TrimSpace(title)
+	if i := strings.IndexByte(title, '\n'); i >= 0 {
+		title = title[:i]
+	}
+	title = strings.TrimPrefix(title, "Title:")
+	title = strings.Trim(title, " \t\"'`*#")
+	title = strings.TrimRight(title, ".!?:;,")
+
+	if runes := []rune(title); len(runes) > titleMaxLen {
+		title = strings.TrimSpace(string(runes[:titleMaxLen]))
+	}
+	return title
+}
+
+// applyTitle stores a generated title, unless the user set one in the meantime
+func (m *chatModel) applyTitle(title string) {
+	if title == "" || m.ctxManager.Metadata().Title != "" {
+		return
+	}
+	m.ctxManager.SetTitle(title)
+	m.autosave()
+	m.AddMessage(ui.FormatInfo("📝 Title: " + title))
+	m.updateViewport()
+}
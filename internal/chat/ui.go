@@ -52,6 +52,9 @@ func (m chatModel) renderHeader() string {
 	if m.streaming {
 		status = "Streaming..."
 		statusColor = "#FFAA00" // Orange
+	} else if m.compacting {
+		status = "Compacting..."
+		statusColor = "#FFAA00" // Orange
 	}
 	if m.err != nil {
 		status = "Error"
@@ -85,6 +88,20 @@ func (m chatModel) renderHeader() string {
 			Background(lipgloss.Color("#5C4D7B"))
 
 		header += " " + chatPathStyle.Render(fmt.Sprintf(" 💾 %s ", config.GetDisplayPath(m.chatPath)))
+	} else if title := m.ctxManager.Metadata().Title; title != "" {
+		titleStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFFFFF")).
+			Background(lipgloss.Color("#5C4D7B"))
+
+		header += " " + titleStyle.Render(fmt.Sprintf(" 📝 %s ", title))
+	}
+
+	if m.ctxManager.HasBranches() {
+		branchStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFFFFF")).
+			Background(lipgloss.Color("#5C4D7B"))
+
+		header += " " + branchStyle.Render(fmt.Sprintf(" ⑂ %d/%d ", m.ctxManager.ActiveBranch()+1, m.ctxManager.BranchCount()))
 	}
 
 	// Add context information if present
@@ -93,6 +110,20 @@ func (m chatModel) renderHeader() string {
 		header += " " + contextStyle.Render(contextInfo)
 	}
 
+	// Context window usage, warning colors when getting full
+	usage := m.contextUsage()
+	usageColor := "#5C4D7B"
+	switch {
+	case usage >= 90:
+		usageColor = "#C0392B"
+	case usage >= 70:
+		usageColor = "#B9770E"
+	}
+	usageStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#FFFFFF")).
+		Background(lipgloss.Color(usageColor))
+	header += " " + usageStyle.Render(fmt.Sprintf(" ctx %d%% of %s ", usage, formatTokens(m.cfg.ContextWindow(m.answeringProfile()))))
+
 	header += " " + statusStyle.Render(" ● "+status+" ")
 
 	return header
@@ -109,18 +140,7 @@ func (m chatModel) renderInputArea() string {
 
 		fileNames := make([]string, 0, len(m.attachedFiles))
 		for _, file := range m.attachedFiles {
-			fileType := ""
-			switch file.Type {
-			case "image":
-				fileType = "📷"
-			case "pdf":
-				fileType = "📄"
-			case "code":
-				fileType = "💻"
-			case "text":
-				fileType = "📝"
-			}
-			fileNames = append(fileNames, fmt.Sprintf("%s %s", fileType, file.Name))
+			fileNames = append(fileNames, fmt.Sprintf("%s %s", file.Icon(), file.Name))
 		}
 
 		attachInfo := fmt.Sprintf("📎 Attached: %s", strings.Join(fileNames, ", "))
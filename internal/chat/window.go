@@ -0,0 +1,72 @@
+package chat
+
+import (
+	"fmt"
+
+	"github.com/KooQix/term-ai/internal/config"
+	ctxmanager "github.com/KooQix/term-ai/internal/context"
+	"github.com/KooQix/term-ai/internal/provider"
+	"github.com/KooQix/term-ai/internal/ui"
+)
+
+// answeringProfile returns the profile answering the next turn
+func (m *chatModel) answeringProfile() *config.Profile {
+	if m.turnProfile != nil {
+		return m.turnProfile
+	}
+	return m.Profile
+}
+
+// contextBudget returns the tokens a request may use with a profile: the
+// context window of its model less what is kept free for the response
+func (m *chatModel) contextBudget(p *config.Profile) int {
+	reserve := p.MaxTokens
+	if reserve <= 0 {
+		reserve = m.cfg.Context.ReserveTokens
+	}
+	return max(m.cfg.ContextWindow(p)-reserve, 1)
+}
+
+// fitContext trims the messages of a request to the context window following
+// the configured policy. The user is told when trimming starts, and when the
+// request still doesn't fit.
+func (m *chatModel) fitContext(messages []provider.Message) []provider.Message {
+	p := m.answeringProfile()
+	budget := m.contextBudget(p)
+	fit := ctxmanager.Fit(messages, budget, m.cfg.Context.Policy, m.cfg.Context.SlidingWindow)
+
+	if fit.Dropped > 0 && m.trimmed == 0 {
+		m.AddMessage(ui.FormatInfo(fmt.Sprintf("Context trimmed: earlier messages are left out to fit the %s-token window of %s (%d dropped)",
+			formatTokens(m.cfg.ContextWindow(p)), p.Model, fit.Dropped)))
+	}
+	m.trimmed = fit.Dropped
+
+	if fit.Tokens > budget {
+		m.AddMessage(ui.FormatInfo(fmt.Sprintf("The request (~%s tokens) exceeds the %s tokens available with %s, the provider may reject it",
+			formatTokens(fit.Tokens), formatTokens(budget), p.Model)))
+	}
+
+	return fit.Messages
+}
+
+// contextUsage returns the estimated share of the context window taken by the
+// history and the files going with the next message, in percent. It goes over
+// 100 when the history no longer fits and gets trimmed.
+func (m *chatModel) contextUsage() int {
+	tokens := ctxmanager.EstimateMessagesTokens(m.ctxManager.GetMessages()) + fileTokens(m.attachedFiles) +
+		fileTokens(m.unsentContextFiles()) + fileTokens(m.everyRequestFiles())
+	return tokens * 100 / m.cfg.ContextWindow(m.answeringProfile())
+}
+
+// formatTokens shows a token count compactly (8192 -> "8.2k")
+func formatTokens(tokens int) string {
+	switch {
+	case tokens >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(tokens)/1_000_000)
+	case tokens >= 10_000:
+		return fmt.Sprintf("%dk", tokens/1000)
+	case tokens >= 1000:
+		return fmt.Sprintf("%.1fk", float64(tokens)/1000)
+	}
+	return fmt.Sprint(tokens)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -20,6 +21,14 @@ type Profile struct {
 	TopP        float64 `yaml:"top_p,omitempty"`
 
 	SystemContext *string `yaml:"system_context"` // nil means use global system context || empty string means no system context
+
+	DisableAutoTitle bool `yaml:"disable_auto_title,omitempty"` // Never send this profile's conversations out to generate a title
+
+	ContextWindow int `yaml:"context_window,omitempty"` // Context window of the model in tokens (0 = look it up from the model name)
+
+	EmbeddingModel string `yaml:"embedding_model,omitempty"` // Model turning texts into vectors (termai embed, retrieval), empty if the profile has none
+
+	Vision *bool `yaml:"vision,omitempty"` // Whether the model reads images (nil = known from the model name)
 }
 
 type UIConfig struct {
@@ -31,20 +40,74 @@ type UIConfig struct {
 type FileConfig struct {
 	MaxFileSize              int64 `yaml:"max_file_size"`                // Maximum file size in bytes (default: 10MB)
 	AutoClearAfterSend       bool  `yaml:"auto_clear_after_send"`        // Clear attached files after sending message
-	IncludeContextInEveryMsg bool  `yaml:"include_context_in_every_msg"` // Include context files in every message
+	IncludeContextInEveryMsg bool  `yaml:"include_context_in_every_msg"` // Send context files with every request (on its last message) instead of storing them once
+
+	DirMode          string   `yaml:"dir_mode"`               // What --dir sends: "files" (their content), "outline" (tree and declarations, files read through a tool) or "rag" (excerpts retrieved per question)
+	RAGTopK          int      `yaml:"rag_top_k"`              // Excerpts retrieved per question in the rag mode
+	ScanMaxDepth     int      `yaml:"scan_max_depth"`         // Levels of subdirectories scanned by --dir (0 = top level only, -1 = unlimited)
+	ScanMaxTotalSize int64    `yaml:"scan_max_total_size"`    // Total bytes of files kept by --dir, shallower files first (0 = no limit)
+	ScanExclude      []string `yaml:"scan_exclude,omitempty"` // Globs of files and directories always left out by --dir
+
+	Extensions map[string]string `yaml:"extensions,omitempty"` // Extension -> format its files are read as (".tf": code), over the built-in ones
+
+	ImageMaxDimension int `yaml:"image_max_dimension"` // Longest side of attached images in pixels, larger ones are scaled down (0 = no limit)
+	ImageQuality      int `yaml:"image_quality"`       // JPEG quality of re-encoded images, 1-100
+}
+
+// What chat --dir sends to the model
+const (
+	DirModeFiles   = "files"
+	DirModeOutline = "outline"
+	DirModeRAG     = "rag"
+)
+
+type ChatConfig struct {
+	AutoTitle    bool   `yaml:"auto_title"`              // Generate a title after the first exchange of a new conversation
+	TitleProfile string `yaml:"title_profile,omitempty"` // Profile used to generate titles, ideally a cheap or local one (empty = current profile)
+
+	Autosave         bool `yaml:"autosave"`              // Persist every completed turn so a crashed session can be resumed
+	AutosaveMaxAge   int  `yaml:"autosave_max_age_days"` // Delete autosaves older than this many days (0 = keep forever)
+	AutosaveMaxCount int  `yaml:"autosave_max_count"`    // Keep at most this many autosaves, newest first (0 = unlimited)
+
+	Dirs []string `yaml:"dirs,omitempty"` // Other directories holding saved chats (registered by /save -d), searched along the default one
+
+	Encryption EncryptionConfig `yaml:"encryption,omitempty"`
+}
+
+type EncryptionConfig struct {
+	Enabled bool   `yaml:"enabled"`           // Encrypt saved chats, autosaves and transcripts (AES-256-GCM)
+	Keyfile string `yaml:"keyfile,omitempty"` // File whose content the key is derived from (default: the passphrase in $TERMAI_PASSPHRASE)
 }
 
 type ToolsConfig struct {
 	MaxIter int                       `yaml:"max_iter"`     // Default max iterations for tools that support it (can be overridden by specific tool config)
 	Config  map[string]map[string]any `yaml:"tool_configs"` // Tool-specific configurations, keyed by tool name (e.g. "web_search": {"api_key
+
+	MaxResultBytes   int            `yaml:"max_result_bytes"`            // Max size of a single tool result handed back to the model (0 = unlimited)
+	MaxTurnBytes     int            `yaml:"max_turn_bytes"`              // Max combined size of all tool results within one turn (0 = unlimited)
+	ResultLimits     map[string]int `yaml:"result_limits,omitempty"`     // Per-tool override of max_result_bytes, keyed by tool name
+	SummarizeProfile string         `yaml:"summarize_profile,omitempty"` // Profile used to summarize oversized results instead of truncating them (empty = truncate)
+}
+
+type ContextConfig struct {
+	Policy        string         `yaml:"policy"`            // How the history is trimmed to fit the model window: drop_oldest, sliding_window or none
+	SlidingWindow int            `yaml:"sliding_window"`    // Number of recent turns kept by the sliding_window policy
+	ReserveTokens int            `yaml:"reserve_tokens"`    // Tokens kept free for the response when the profile sets no max_tokens
+	Windows       map[string]int `yaml:"windows,omitempty"` // Context window sizes in tokens keyed by model name or prefix, checked before the built-in ones
+
+	CompactThreshold int    `yaml:"compact_threshold"`         // Compact the conversation once it fills this percentage of the context window (0 = only on /compact)
+	CompactKeepTurns int    `yaml:"compact_keep_turns"`        // Number of recent turns kept verbatim when compacting
+	CompactProfile   string `yaml:"compact_profile,omitempty"` // Profile used to summarize compacted turns (empty = current profile)
 }
 
 type Config struct {
-	DefaultProfile string     `yaml:"default_profile"`
-	Profiles       []Profile  `yaml:"profiles"`
-	UI             UIConfig   `yaml:"ui,omitempty"`
-	Files          FileConfig `yaml:"files,omitempty"`
-	SystemContext  string     `yaml:"system_context,omitempty"`
+	DefaultProfile string        `yaml:"default_profile"`
+	Profiles       []Profile     `yaml:"profiles"`
+	UI             UIConfig      `yaml:"ui,omitempty"`
+	Files          FileConfig    `yaml:"files,omitempty"`
+	Chat           ChatConfig    `yaml:"chat,omitempty"`
+	Context        ContextConfig `yaml:"context,omitempty"`
+	SystemContext  string        `yaml:"system_context,omitempty"`
 
 	ToolConfigs ToolsConfig `yaml:"tool_configs"`
 }
@@ -54,7 +117,11 @@ const (
 	ConfigFileName = "config.yaml"
 
 	ConversationsDirectory = "conversations"
-	ChatFileExt            = ".termai.md"
+	AutosaveDirectory      = "autosave"
+	TranscriptsDirectory   = "transcripts"
+	IndexesDirectory       = "indexes"
+	ChatFileExt            = ".termai.json"
+	LegacyChatFileExt      = ".termai.md" // line-prefixed format used before the JSON one, still readable
 )
 
 var AppConfig *Config
@@ -91,6 +158,94 @@ func GetDefaultChatsPath() (string, error) {
 	return conversationsPath, nil
 }
 
+// GetChatDirs returns every directory holding saved chats: the default one first, then the registered ones
+func GetChatDirs() ([]string, error) {
+	defaultPath, err := GetDefaultChatsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := []string{defaultPath}
+	if AppConfig != nil {
+		for _, dir := range AppConfig.Chat.Dirs {
+			if dir != defaultPath && !slices.Contains(dirs, dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs, nil
+}
+
+// RegisterChatDir records a directory holding saved chats, so it is searched
+// along the default one. The default directory (and its subdirectories) need
+// no registration.
+func RegisterChatDir(dir string) error {
+	if AppConfig == nil {
+		return fmt.Errorf("config not loaded")
+	}
+
+	defaultPath, err := GetDefaultChatsPath()
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(defaultPath, dir); err == nil && !strings.HasPrefix(rel, "..") {
+		return nil
+	}
+	if slices.Contains(AppConfig.Chat.Dirs, dir) {
+		return nil
+	}
+
+	AppConfig.Chat.Dirs = append(AppConfig.Chat.Dirs, dir)
+	return AppConfig.Save()
+}
+
+// GetAutosavePath returns the directory holding session autosaves, creating it if needed
+func GetAutosavePath() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	autosavePath := filepath.Join(configDir, AutosaveDirectory)
+
+	if err := os.MkdirAll(autosavePath, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create autosave directory: %w", err)
+	}
+
+	return autosavePath, nil
+}
+
+// GetTranscriptsPath returns the directory holding the full transcripts of
+// compacted conversations, creating it if needed
+func GetTranscriptsPath() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	transcriptsPath := filepath.Join(configDir, TranscriptsDirectory)
+
+	if err := os.MkdirAll(transcriptsPath, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create transcripts directory: %w", err)
+	}
+
+	return transcriptsPath, nil
+}
+
+// GetIndexesPath returns the directory holding the retrieval indexes of
+// context directories, creating it if needed
+func GetIndexesPath() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	indexesPath := filepath.Join(configDir, IndexesDirectory)
+
+	if err := os.MkdirAll(indexesPath, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create indexes directory: %w", err)
+	}
+
+	return indexesPath, nil
+}
+
 // GetConfigDir returns the path to the config directory
 func GetConfigDir() (string, error) {
 	homeDir, err := os.UserHomeDir()
@@ -226,13 +381,122 @@ func (c *Config) RemoveProfile(name string) error {
 	return fmt.Errorf("profile '%s' not found", name)
 }
 
+// fallbackContextWindow is assumed for models of unknown window size, small
+// enough for most local models
+const fallbackContextWindow = 8192
+
+// defaultContextWindows holds the context window sizes of common models,
+// matched on the longest prefix of the model name
+var defaultContextWindows = map[string]int{
+	"gpt-3.5-turbo": 16385,
+	"gpt-4":         8192,
+	"gpt-4-32k":     32768,
+	"gpt-4-turbo":   128000,
+	"gpt-4o":        128000,
+	"gpt-4.1":       1047576,
+	"gpt-5":         400000,
+	"o1":            200000,
+	"o3":            200000,
+	"o4":            200000,
+	"claude":        200000,
+	"gemini":        1048576,
+	"llama3":        8192,
+	"llama3.1":      131072,
+	"llama3.2":      131072,
+	"llama3.3":      131072,
+	"mistral":       32768,
+	"mixtral":       32768,
+	"codellama":     16384,
+	"qwen2.5":       32768,
+	"qwen3":         40960,
+	"deepseek":      128000,
+	"gemma2":        8192,
+	"gemma3":        131072,
+	"phi3":          4096,
+	"phi4":          16384,
+}
+
+// ContextWindow returns the context window of a profile's model in tokens: the
+// profile's context_window when set, else the size configured in
+// context.windows or known for the longest matching model prefix, else a
+// conservative default. Vendor prefixes such as "openai/" are ignored.
+func (c *Config) ContextWindow(p *Profile) int {
+	if p.ContextWindow > 0 {
+		return p.ContextWindow
+	}
+
+	model := strings.ToLower(p.Model)
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+
+	if window, ok := longestPrefixMatch(c.Context.Windows, model); ok {
+		return window
+	}
+	if window, ok := longestPrefixMatch(defaultContextWindows, model); ok {
+		return window
+	}
+	return fallbackContextWindow
+}
+
+// visionModels are the prefixes of the names of models reading images, and
+// visionMarkers words naming vision variants of other models (llama3.2-vision)
+var (
+	visionModels  = []string{"gpt-4o", "gpt-4.1", "gpt-4-turbo", "gpt-5", "o1", "o3", "o4", "claude", "gemini", "gemma3", "llama4", "pixtral", "mistral-small3.1", "mistral-medium"}
+	visionMarkers = []string{"vision", "llava", "-vl", "vl:", "moondream", "minicpm-v"}
+)
+
+// SupportsVision reports whether a profile's model reads images: the
+// profile's vision setting when set, else whether the model is known to
+func (p *Profile) SupportsVision() bool {
+	if p.Vision != nil {
+		return *p.Vision
+	}
+
+	model := strings.ToLower(p.Model)
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	for _, prefix := range visionModels {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	for _, marker := range visionMarkers {
+		if strings.Contains(model, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func longestPrefixMatch(windows map[string]int, model string) (int, bool) {
+	best, window := -1, 0
+	for prefix, size := range windows {
+		if size > 0 && strings.HasPrefix(model, strings.ToLower(prefix)) && len(prefix) > best {
+			best, window = len(prefix), size
+		}
+	}
+	return window, best >= 0
+}
+
 func GetDisplayPath(originalPath string) string {
 	if originalPath == "" {
 		return ""
 	}
 
-	originalPath = strings.TrimSuffix(originalPath, ChatFileExt)
-	return filepath.Base(originalPath)
+	return filepath.Base(TrimChatExt(originalPath))
+}
+
+// TrimChatExt removes the conversation file extension (current or legacy) from a path
+func TrimChatExt(path string) string {
+	path = strings.TrimSuffix(path, ChatFileExt)
+	return strings.TrimSuffix(path, LegacyChatFileExt)
+}
+
+// IsChatFile reports whether a path has a conversation file extension (current or legacy)
+func IsChatFile(path string) bool {
+	return strings.HasSuffix(path, ChatFileExt) || strings.HasSuffix(path, LegacyChatFileExt)
 }
 
 func getDefaultConfig() *Config {
@@ -279,10 +543,32 @@ func getDefaultConfig() *Config {
 			MaxFileSize:              10485760, // 10MB
 			AutoClearAfterSend:       true,
 			IncludeContextInEveryMsg: false,
+			DirMode:                  DirModeFiles,
+			RAGTopK:                  8,
+			ScanMaxDepth:             5,
+			ScanMaxTotalSize:         524288, // 512KB
+			ImageMaxDimension:        2048,
+			ImageQuality:             85,
+		},
+		Chat: ChatConfig{
+			AutoTitle:        true,
+			Autosave:         true,
+			AutosaveMaxAge:   30,
+			AutosaveMaxCount: 50,
+		},
+		Context: ContextConfig{
+			Policy:        "drop_oldest",
+			SlidingWindow: 20,
+			ReserveTokens: 1024,
+
+			CompactThreshold: 80,
+			CompactKeepTurns: 4,
 		},
 		ToolConfigs: ToolsConfig{
-			MaxIter: 8,
-			Config:  make(map[string]map[string]any),
+			MaxIter:        8,
+			Config:         make(map[string]map[string]any),
+			MaxResultBytes: 16384, // 16KB
+			MaxTurnBytes:   65536, // 64KB
 		},
 
 		SystemContext: "You are an AI CLI assistant for a software developer. Provide clear, concise, and actionable responses focused on commands, debugging (including extended sessions), and project development tasks. Use markdown formatting for code and commands. Consider the current project context and previous interactions when necessary. Ask clarifying questions if the request is ambiguous. Avoid unnecessary explanations unless explicitly requested. Handle errors gracefully and suggest best practices or alternatives when appropriate.",
@@ -0,0 +1,225 @@
+package context
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KooQix/term-ai/internal/config"
+	"github.com/KooQix/term-ai/internal/provider"
+)
+
+//////////////////// Autosave and crash recovery \\\\\\\\\\\\\\\\\\\\
+
+// A running session holds a "<id>.lock" file (containing its PID) next to its
+// autosave. The lock is removed on a clean exit, so a lock left behind by a
+// process that is no longer running marks an unfinished session.
+const lockExt = ".lock"
+
+// Autosaver persists a conversation to the autosave directory after every turn
+type Autosaver struct {
+	path   string
+	locked bool
+}
+
+// AutosaveEntry describes a session found in the autosave directory
+type AutosaveEntry struct {
+	ID         string
+	Path       string
+	ModTime    time.Time
+	Unfinished bool // the session did not exit cleanly
+	Running    bool // the session is still running in another process
+}
+
+// NewAutosaver starts a new autosaved session
+func NewAutosaver() (*Autosaver, error) {
+	dir, err := config.GetAutosavePath()
+	if err != nil {
+		return nil, err
+	}
+
+	// Nothing is written (nor locked) before the first completed turn: the PID
+	// keeps sessions started in the same second apart until then
+	id := fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405"), os.Getpid())
+	path := filepath.Join(dir, id+config.ChatFileExt)
+	for i := 2; fileExists(path); i++ {
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d%s", id, i, config.ChatFileExt))
+	}
+
+	return &Autosaver{path: path}, nil
+}
+
+// ResumeAutosaver continues writing to an existing autosave (when resuming it)
+func ResumeAutosaver(path string) (*Autosaver, error) {
+	a := &Autosaver{path: ChatFilePath(path)}
+	if err := a.lock(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Path returns the autosave file of the session
+func (a *Autosaver) Path() string {
+	return a.path
+}
+
+// Save persists the conversation, if it has anything worth keeping
+func (a *Autosaver) Save(m *Manager) error {
+	if !slices.ContainsFunc(m.messages, func(msg provider.Message) bool { return msg.Role != provider.RoleSystem }) {
+		return nil
+	}
+
+	if err := m.write(a.path); err != nil {
+		return err
+	}
+	return a.lock()
+}
+
+// Close marks the session as cleanly exited
+func (a *Autosaver) Close() error {
+	if err := os.Remove(a.lockPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// lock marks the session as running in this process
+func (a *Autosaver) lock() error {
+	if a.locked {
+		return nil
+	}
+	if err := os.WriteFile(a.lockPath(), []byte(strconv.Itoa(os.Getpid())), 0o600); err != nil {
+		return fmt.Errorf("failed to lock autosave: %w", err)
+	}
+	a.locked = true
+	return nil
+}
+
+func (a *Autosaver) lockPath() string {
+	return config.TrimChatExt(a.path) + lockExt
+}
+
+// ListAutosaves returns the autosaved sessions, newest first
+func ListAutosaves() ([]AutosaveEntry, error) {
+	dir, err := config.GetAutosavePath()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read autosave directory: %w", err)
+	}
+
+	var entries []AutosaveEntry
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), config.ChatFileExt) {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+
+		entry := AutosaveEntry{
+			ID:      config.GetDisplayPath(file.Name()),
+			Path:    filepath.Join(dir, file.Name()),
+			ModTime: info.ModTime(),
+		}
+		if pid, ok := readLock(config.TrimChatExt(entry.Path) + lockExt); ok {
+			entry.Running = processAlive(pid)
+			entry.Unfinished = !entry.Running
+		}
+		entries = append(entries, entry)
+	}
+
+	slices.SortFunc(entries, func(a, b AutosaveEntry) int {
+		return b.ModTime.Compare(a.ModTime)
+	})
+	return entries, nil
+}
+
+// FindAutosave returns the autosave with the given ID, or the most recent one
+// (not running in another process) for "last" or ""
+func FindAutosave(id string) (*AutosaveEntry, error) {
+	entries, err := ListAutosaves()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if id == "" || id == "last" {
+			if !entry.Running {
+				return &entry, nil
+			}
+		} else if entry.ID == id {
+			return &entry, nil
+		}
+	}
+
+	if id == "" || id == "last" {
+		return nil, fmt.Errorf("no autosaved session found")
+	}
+	return nil, fmt.Errorf("autosaved session '%s' not found", id)
+}
+
+// AcknowledgeUnfinished clears the stale lock of an unfinished session, so it
+// is only reported once. The autosave itself is kept and can still be resumed.
+func AcknowledgeUnfinished(entry AutosaveEntry) error {
+	if !entry.Unfinished {
+		return nil
+	}
+	return os.Remove(config.TrimChatExt(entry.Path) + lockExt)
+}
+
+// PruneAutosaves deletes autosaves older than maxAge and beyond the maxCount
+// newest ones (0 disables either limit). Running sessions are never pruned.
+func PruneAutosaves(maxAge time.Duration, maxCount int) error {
+	entries, err := ListAutosaves()
+	if err != nil {
+		return err
+	}
+
+	kept := 0
+	for _, entry := range entries {
+		if entry.Running {
+			continue
+		}
+
+		tooOld := maxAge > 0 && time.Since(entry.ModTime) > maxAge
+		tooMany := maxCount > 0 && kept >= maxCount
+		if !tooOld && !tooMany {
+			kept++
+			continue
+		}
+
+		if err := os.Remove(entry.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to prune autosave '%s': %w", entry.ID, err)
+		}
+		os.Remove(config.TrimChatExt(entry.Path) + lockExt)
+	}
+
+	return nil
+}
+
+func readLock(path string) (int, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		// Unreadable lock: treat it as left behind by a dead process
+		return 0, true
+	}
+	return pid, true
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,235 @@
+package context
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/KooQix/term-ai/internal/provider"
+)
+
+//////////////////// Conversation branches \\\\\\\\\\\\\\\\\\\\
+
+// Messages form a tree: a branch starts with the first ForkAt messages of its
+// parent's path and continues with its own messages. The Manager works on the
+// active branch's full path (m.messages) and writes it back to the tree
+// before forking, switching or saving. A conversation that was never forked
+// has no tree at all.
+
+// Branch is a line of messages forked from another branch
+type Branch struct {
+	Name      string             `json:"name,omitempty"`
+	Parent    int                `json:"parent"`  // index of the parent branch, -1 for the root
+	ForkAt    int                `json:"fork_at"` // number of messages of the parent's path the branch starts with
+	Messages  []provider.Message `json:"messages"`
+	CreatedAt time.Time          `json:"created_at,omitzero"`
+}
+
+// BranchInfo describes a branch for listing. Counts leave system messages out.
+type BranchInfo struct {
+	Index     int
+	Name      string
+	Parent    int // -1 for a root
+	ForkAfter int // messages shared with the parent
+	Messages  int // messages of the full path
+	Active    bool
+	Last      *provider.Message // last message of the path, nil when empty
+}
+
+// HasBranches reports whether the conversation has been forked
+func (m *Manager) HasBranches() bool {
+	return len(m.branches) > 1
+}
+
+// BranchCount returns the number of branches, 1 for a conversation never forked
+func (m *Manager) BranchCount() int {
+	return max(len(m.branches), 1)
+}
+
+// ActiveBranch returns the index of the active branch
+func (m *Manager) ActiveBranch() int {
+	return m.active
+}
+
+// Fork starts a new branch from the first keep messages of the active path
+// and makes it active. It returns the index of the new branch.
+func (m *Manager) Fork(keep int, name string) (int, error) {
+	if keep < 0 || keep > len(m.messages) {
+		return 0, fmt.Errorf("cannot fork after message %d, the conversation has %d", keep, len(m.messages))
+	}
+
+	m.syncBranch()
+	m.branches = append(m.branches, Branch{
+		Name:      name,
+		Parent:    m.active,
+		ForkAt:    keep,
+		CreatedAt: time.Now(),
+	})
+	m.active = len(m.branches) - 1
+	m.messages = slices.Clone(m.messages[:keep])
+
+	return m.active, nil
+}
+
+// SwitchBranch makes another branch active
+func (m *Manager) SwitchBranch(index int) error {
+	m.syncBranch()
+	if index < 0 || index >= len(m.branches) {
+		return fmt.Errorf("branch %d does not exist", index+1)
+	}
+
+	m.active = index
+	m.messages = m.branchPath(index)
+	return nil
+}
+
+// Branches lists the branches of the conversation, a single one when it was never forked
+func (m *Manager) Branches() []BranchInfo {
+	m.syncBranch()
+
+	infos := make([]BranchInfo, 0, len(m.branches))
+	for i, b := range m.branches {
+		path := m.branchPath(i)
+		info := BranchInfo{
+			Index:     i,
+			Name:      b.Name,
+			Parent:    b.Parent,
+			ForkAfter: countConversational(path[:min(b.ForkAt, len(path))]),
+			Messages:  countConversational(path),
+			Active:    i == m.active,
+		}
+		if len(path) > 0 {
+			info.Last = &path[len(path)-1]
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// syncBranch writes the active path back to the tree, creating the tree on
+// first use. When the shared part of the path was changed (e.g. the history
+// was truncated before the fork point), the branch no longer shares it with
+// its parent and becomes a root with its own copy of the messages; children
+// forked from a part of the path that changed get the same treatment.
+func (m *Manager) syncBranch() {
+	if len(m.branches) == 0 {
+		m.branches = []Branch{{Parent: -1, CreatedAt: m.metadata.CreatedAt}}
+		m.active = 0
+	}
+
+	old := m.branchPath(m.active)
+	b := &m.branches[m.active]
+
+	if b.Parent >= 0 {
+		parentPath := m.branchPath(b.Parent)
+		if b.ForkAt > len(m.messages) || !samePrefix(m.messages, parentPath, b.ForkAt) {
+			b.Parent, b.ForkAt = -1, 0
+		}
+	}
+	b.Messages = slices.Clone(m.messages[b.ForkAt:])
+
+	for i := range m.branches {
+		child := &m.branches[i]
+		if child.Parent != m.active || i == m.active {
+			continue
+		}
+		if child.ForkAt > len(m.messages) || !samePrefix(old, m.messages, child.ForkAt) {
+			child.Messages = append(slices.Clone(old[:min(child.ForkAt, len(old))]), child.Messages...)
+			child.Parent, child.ForkAt = -1, 0
+		}
+	}
+}
+
+// branchPath returns a copy of the full message path of a branch
+func (m *Manager) branchPath(index int) []provider.Message {
+	var chain []int
+	for i := index; i >= 0 && i < len(m.branches) && !slices.Contains(chain, i); i = m.branches[i].Parent {
+		chain = append(chain, i)
+	}
+
+	path := make([]provider.Message, 0)
+	for j := len(chain) - 1; j >= 0; j-- {
+		b := m.branches[chain[j]]
+		if j < len(chain)-1 {
+			path = path[:min(b.ForkAt, len(path))]
+		}
+		path = append(path, b.Messages...)
+	}
+	return slices.Clone(path)
+}
+
+// countConversational counts the messages that aren't system messages
+func countConversational(messages []provider.Message) int {
+	count := 0
+	for _, msg := range messages {
+		if msg.Role != provider.RoleSystem {
+			count++
+		}
+	}
+	return count
+}
+
+// samePrefix reports whether a and b share their first n messages
+func samePrefix(a, b []provider.Message, n int) bool {
+	if len(a) < n || len(b) < n {
+		return false
+	}
+	for i := range n {
+		if a[i].Role != b[i].Role || a[i].Content != b[i].Content {
+			return false
+		}
+	}
+	return true
+}
+
+// loadBranches restores the tree of a loaded conversation, ignoring a malformed one
+func (m *Manager) loadBranches(branches []Branch, active int) {
+	m.branches, m.active = nil, 0
+	if len(branches) < 2 || active < 0 || active >= len(branches) {
+		return
+	}
+	for i, b := range branches {
+		if b.Parent >= i || b.Parent < -1 {
+			return
+		}
+	}
+
+	m.branches, m.active = branches, active
+	m.syncBranch()
+}
+
+// treeSnapshot returns a copy of the tree for saving, nil when never forked
+func (m *Manager) treeSnapshot() ([]Branch, int) {
+	if !m.HasBranches() {
+		return nil, 0
+	}
+	m.syncBranch()
+
+	branches := slices.Clone(m.branches)
+	for i := range branches {
+		branches[i].Messages = slices.Clone(branches[i].Messages)
+	}
+	return branches, m.active
+}
+
+// updateShared applies a change made to the message at index of the active
+// path to the ancestor branch holding it, when the message is shared with the
+// parent: writing the active path back only covers the branch's own messages.
+func (m *Manager) updateShared(index int, update func(*provider.Message)) {
+	if !m.HasBranches() {
+		return
+	}
+
+	b := m.active
+	for m.branches[b].Parent >= 0 && index < m.branches[b].ForkAt {
+		b = m.branches[b].Parent
+	}
+	if b == m.active {
+		return
+	}
+
+	owner := &m.branches[b]
+	if i := index - owner.ForkAt; i >= 0 && i < len(owner.Messages) {
+		update(&owner.Messages[i])
+	}
+}
@@ -0,0 +1,122 @@
+package context
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/KooQix/term-ai/internal/provider"
+)
+
+//////////////////// Compaction \\\\\\\\\\\\\\\\\\\\
+
+// Compaction replaces the older turns of a long conversation with a summary
+// written by the model. The summary is a system message placed where the
+// first summarized message was; the system prompt, pinned turns and the most
+// recent turns stay as they are. Compacting again folds the previous
+// summaries into the new one.
+
+// SummaryPrefix introduces the summary of compacted messages
+const SummaryPrefix = "Summary of the earlier part of the conversation:\n\n"
+
+// IsSummary reports whether a message is the summary of compacted messages
+func IsSummary(msg provider.Message) bool {
+	return msg.Role == provider.RoleSystem && msg.Meta != nil && msg.Meta.Compacted > 0
+}
+
+// CompactCandidates returns the messages a compaction keeping the last
+// keepTurns turns would summarize, in order. None when there is nothing to
+// compact.
+func (m *Manager) CompactCandidates(keepTurns int) []provider.Message {
+	var candidates []provider.Message
+	for i, selected := range compactSelection(m.messages, keepTurns) {
+		if selected {
+			candidates = append(candidates, m.messages[i])
+		}
+	}
+	return candidates
+}
+
+// Compact replaces the messages returned by CompactCandidates with a summary.
+// The candidates are checked against the conversation, so that one changed in
+// the meantime isn't rewritten from a stale summary. transcript is the file
+// holding the conversation as it was, recorded on the summary. It returns the
+// number of messages the summary stands for.
+func (m *Manager) Compact(keepTurns int, candidates []provider.Message, summary, transcript string) (int, error) {
+	selection := compactSelection(m.messages, keepTurns)
+
+	var current []provider.Message
+	for i, selected := range selection {
+		if selected {
+			current = append(current, m.messages[i])
+		}
+	}
+	if len(current) == 0 || !samePrefix(current, candidates, len(candidates)) || len(current) != len(candidates) {
+		return 0, fmt.Errorf("the conversation changed while it was being summarized, nothing was compacted")
+	}
+
+	compacted := 0
+	for _, msg := range current {
+		if IsSummary(msg) {
+			compacted += msg.Meta.Compacted
+		} else {
+			compacted++
+		}
+	}
+
+	summaryMsg := provider.Message{
+		Role:    provider.RoleSystem,
+		Content: SummaryPrefix + summary,
+		Meta: &provider.MessageMeta{
+			CreatedAt:  time.Now(),
+			Compacted:  compacted,
+			Transcript: transcript,
+		},
+	}
+
+	messages := make([]provider.Message, 0, len(m.messages)-len(current)+1)
+	placed := false
+	for i, msg := range m.messages {
+		if !selection[i] {
+			messages = append(messages, msg)
+			continue
+		}
+		if !placed {
+			messages = append(messages, summaryMsg)
+			placed = true
+		}
+	}
+	m.messages = messages
+
+	return compacted, nil
+}
+
+// compactSelection marks the messages a compaction summarizes: those of the
+// turns before the last keepTurns ones, pinned turns excepted, along with the
+// summaries of previous compactions
+func compactSelection(messages []provider.Message, keepTurns int) []bool {
+	selection := make([]bool, len(messages))
+	turns := splitTurns(messages)
+
+	found := false
+	for _, t := range turns[:max(len(turns)-max(keepTurns, 1), 0)] {
+		if t.pinned {
+			continue
+		}
+		for i := t.start; i < t.end; i++ {
+			if messages[i].Role != provider.RoleSystem {
+				selection[i] = true
+				found = true
+			}
+		}
+	}
+	if !found {
+		return make([]bool, len(messages))
+	}
+
+	for i, msg := range messages {
+		if IsSummary(msg) {
+			selection[i] = true
+		}
+	}
+	return selection
+}
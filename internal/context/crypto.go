@@ -0,0 +1,251 @@
+package context
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/KooQix/term-ai/internal/config"
+)
+
+//////////////////// Encryption at rest \\\\\\\\\\\\\\\\\\\\
+
+// Conversations are encrypted with AES-256-GCM when chat.encryption.enabled
+// is set. The key is derived from the content of the configured keyfile
+// (HKDF-SHA256, the keyfile being random already), or else from the
+// passphrase in $TERMAI_PASSPHRASE (PBKDF2-SHA256, slow on purpose). All
+// files are written with one salt kept in the config directory, so that a
+// process derives the passphrase key once for all of them.
+// An encrypted file is the magic line, the salt, the nonce and the sealed
+// JSON; plaintext files are still read as is, so both kinds coexist. Files of
+// the first version (magic "TERMAI-ENC1") derived any secret with PBKDF2.
+
+// PassphraseEnv is the environment variable holding the encryption passphrase
+const PassphraseEnv = "TERMAI_PASSPHRASE"
+
+const (
+	encryptedMagic   = "TERMAI-ENC2\n"
+	encryptedMagicV1 = "TERMAI-ENC1\n"
+	saltFileName     = "encryption.salt"
+	saltSize         = 16
+	kdfIterations    = 600_000
+)
+
+// ErrNoKey is returned when reading an encrypted conversation without a key configured
+var ErrNoKey = fmt.Errorf("conversation is encrypted and no key is available (set $%s or chat.encryption.keyfile)", PassphraseEnv)
+
+var (
+	keysMu sync.Mutex
+	keys   = map[string][]byte{} // magic and salt -> derived key, as derivation is slow on purpose
+	salt   []byte                // salt used for the files written, read from the config directory
+)
+
+// IsEncrypted reports whether data is an encrypted conversation
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedMagic)) || bytes.HasPrefix(data, []byte(encryptedMagicV1))
+}
+
+// IsEncryptedFile reports whether the file at path is an encrypted conversation
+func IsEncryptedFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	head := make([]byte, len(encryptedMagic))
+	if _, err := io.ReadFull(file, head); err != nil {
+		return false
+	}
+	return IsEncrypted(head)
+}
+
+// EncryptionEnabled reports whether saved conversations are to be encrypted
+func EncryptionEnabled() bool {
+	cfg, err := config.Load()
+	return err == nil && cfg.Chat.Encryption.Enabled
+}
+
+// HasKey reports whether a key is available to encrypt and decrypt conversations
+func HasKey() bool {
+	secret, err := keySecret()
+	return err == nil && secret != nil
+}
+
+// Encrypt seals a conversation with the configured key
+func Encrypt(plaintext []byte) ([]byte, error) {
+	keysMu.Lock()
+	if salt == nil {
+		var err error
+		if salt, err = loadSalt(); err != nil {
+			keysMu.Unlock()
+			return nil, err
+		}
+	}
+	fileSalt := salt
+	keysMu.Unlock()
+
+	gcm, err := newGCM(encryptedMagic, fileSalt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate a nonce: %w", err)
+	}
+
+	out := make([]byte, 0, len(encryptedMagic)+saltSize+len(nonce)+len(plaintext)+gcm.Overhead())
+	out = append(out, encryptedMagic...)
+	out = append(out, fileSalt...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plaintext, []byte(encryptedMagic)), nil
+}
+
+// Decrypt opens a conversation sealed by Encrypt
+func Decrypt(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return nil, fmt.Errorf("not an encrypted conversation")
+	}
+	magic := string(data[:len(encryptedMagic)])
+	data = data[len(magic):]
+	if len(data) < saltSize {
+		return nil, fmt.Errorf("encrypted conversation is truncated")
+	}
+
+	gcm, err := newGCM(magic, data[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	data = data[saltSize:]
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted conversation is truncated")
+	}
+
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(magic))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt conversation (wrong key or corrupted file)")
+	}
+	return plaintext, nil
+}
+
+// newGCM returns the cipher for the key of a file of the given magic, derived with fileSalt
+func newGCM(magic string, fileSalt []byte) (cipher.AEAD, error) {
+	key, err := deriveKey(magic, fileSalt)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// deriveKey derives the AES-256 key of a file of the given magic for fileSalt
+// from the configured secret
+func deriveKey(magic string, fileSalt []byte) ([]byte, error) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	cacheKey := magic + string(fileSalt)
+	if key, ok := keys[cacheKey]; ok {
+		return key, nil
+	}
+
+	secret, fromKeyfile, err := keySecretSource()
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, ErrNoKey
+	}
+
+	var key []byte
+	if fromKeyfile && magic == encryptedMagic {
+		key, err = hkdf.Key(sha256.New, secret, fileSalt, "term-ai conversation", 32)
+	} else {
+		key, err = pbkdf2.Key(sha256.New, string(secret), fileSalt, kdfIterations, 32)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive the encryption key: %w", err)
+	}
+	keys[cacheKey] = key
+	return key, nil
+}
+
+// loadSalt returns the salt kept in the config directory, creating it the
+// first time
+func loadSalt() ([]byte, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(configDir, saltFileName)
+
+	if existing, err := os.ReadFile(path); err == nil && len(existing) == saltSize {
+		return existing, nil
+	}
+
+	newSalt := make([]byte, saltSize)
+	if _, err := rand.Read(newSalt); err != nil {
+		return nil, fmt.Errorf("failed to generate a salt: %w", err)
+	}
+	if err := config.EnsureConfigDir(); err != nil {
+		return nil, err
+	}
+	// Written aside then renamed, so that another process never reads half of it
+	tmp := path + fmt.Sprintf(".%d.tmp", os.Getpid())
+	if err := os.WriteFile(tmp, newSalt, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to save the encryption salt: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("failed to save the encryption salt: %w", err)
+	}
+	return newSalt, nil
+}
+
+// keySecret returns the content of the keyfile, or the passphrase, or nil when neither is set
+func keySecret() ([]byte, error) {
+	secret, _, err := keySecretSource()
+	return secret, err
+}
+
+// keySecretSource is keySecret, also telling whether the secret is the keyfile's
+func keySecretSource() ([]byte, bool, error) {
+	if cfg, err := config.Load(); err == nil && cfg.Chat.Encryption.Keyfile != "" {
+		path := cfg.Chat.Encryption.Keyfile
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, rest)
+			}
+		}
+
+		secret, err := os.ReadFile(path)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read the encryption keyfile: %w", err)
+		}
+		if secret = bytes.TrimSpace(secret); len(secret) == 0 {
+			return nil, false, errors.New("the encryption keyfile is empty")
+		}
+		return secret, true, nil
+	}
+
+	if passphrase := os.Getenv(PassphraseEnv); passphrase != "" {
+		return []byte(passphrase), false, nil
+	}
+	return nil, false, nil
+}
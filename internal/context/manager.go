@@ -1,49 +1,101 @@
 package context
 
 import (
-	"bufio"
-	"fmt"
-	"os"
-	"path/filepath"
-	"strings"
+	"slices"
+	"time"
 
-	"github.com/KooQix/term-ai/internal/config"
 	"github.com/KooQix/term-ai/internal/provider"
 )
 
+// Metadata describes a conversation as a whole
+type Metadata struct {
+	Title     string    `json:"title,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+	Profile   string    `json:"profile,omitempty"` // profile in use when last saved, restored on load
+	Model     string    `json:"model,omitempty"`   // model in use when last saved
+	Files     []string  `json:"files,omitempty"`   // paths of the files attached during the conversation
+	Source    string    `json:"source,omitempty"`  // origin of an imported conversation ("chatgpt:<id>", ...)
+
+	PinnedFiles []string `json:"pinned_files,omitempty"` // paths of the context files pinned to the conversation
+}
+
 // Manager handles conversation context
 type Manager struct {
-	messages []provider.Message
+	messages []provider.Message // path of the active branch
+	metadata Metadata
+	branches []Branch // nil until the conversation is forked
+	active   int      // index of the active branch
 }
 
 // NewManager creates a new context manager
 func NewManager() *Manager {
 	return &Manager{
 		messages: make([]provider.Message, 0),
+		metadata: Metadata{CreatedAt: time.Now()},
 	}
 }
 
-// AddUserMessage adds a user message to the context
-func (m *Manager) AddUserMessage(content string) {
+// AddUserMessage adds a user message to the context, with the files sent along
+func (m *Manager) AddUserMessage(content string, attachments ...provider.Attachment) {
 	m.messages = append(m.messages, provider.Message{
-		Role:    provider.RoleUser,
-		Content: content,
+		Role:        provider.RoleUser,
+		Content:     content,
+		Attachments: attachments,
+		Meta:        &provider.MessageMeta{CreatedAt: time.Now()},
 	})
 }
 
-// AddAssistantMessage adds an assistant message to the context
+// AddAssistantMessage adds an assistant message to the context, recording the
+// profile and model currently in use (see SetProfile)
 func (m *Manager) AddAssistantMessage(content string) {
 	m.messages = append(m.messages, provider.Message{
 		Role:    provider.RoleAssistant,
 		Content: content,
+		Meta: &provider.MessageMeta{
+			CreatedAt: time.Now(),
+			Profile:   m.metadata.Profile,
+			Model:     m.metadata.Model,
+		},
 	})
 }
 
+// Metadata returns the conversation metadata
+func (m *Manager) Metadata() *Metadata {
+	return &m.metadata
+}
+
+// SetProfile records the profile and model producing the next assistant messages
+func (m *Manager) SetProfile(profile, model string) {
+	m.metadata.Profile = profile
+	m.metadata.Model = model
+}
+
+// SetTitle sets the conversation title
+func (m *Manager) SetTitle(title string) {
+	m.metadata.Title = title
+}
+
+// SetTags replaces the conversation tags
+func (m *Manager) SetTags(tags []string) {
+	m.metadata.Tags = tags
+}
+
+// AddFiles records attached file paths, ignoring the ones already known
+func (m *Manager) AddFiles(paths ...string) {
+	for _, path := range paths {
+		if !slices.Contains(m.metadata.Files, path) {
+			m.metadata.Files = append(m.metadata.Files, path)
+		}
+	}
+}
+
 func (m *Manager) SetSystemMessage(content string) {
 	if len(m.messages) > 0 {
-		// Update the first system message
+		// Update the first system message, summaries of compacted messages aside
 		for i, msg := range m.messages {
-			if msg.Role == provider.RoleSystem {
+			if msg.Role == provider.RoleSystem && !IsSummary(msg) {
 				if content == "" {
 					// Remove the system message
 					m.messages = append(m.messages[:i], m.messages[i+1:]...)
@@ -82,136 +134,71 @@ func (m *Manager) GetMessages() []provider.Message {
 	return m.messages
 }
 
-// Clear clears all messages
-func (m *Manager) Clear() {
-	m.messages = make([]provider.Message, 0)
-}
-
-// IsEmpty returns true if there are no messages
-func (m *Manager) IsEmpty() bool {
-	return len(m.messages) == 0
-}
-
-// Retrieve the last message of the conversation
-func (m *Manager) GetLastMessage() *provider.Message {
-	if len(m.messages) == 0 {
-		return nil
-	}
-	return &m.messages[len(m.messages)-1]
+// HasAttachment reports whether a file at path was sent with a message of the conversation
+func (m *Manager) HasAttachment(path string) bool {
+	return slices.ContainsFunc(m.messages, func(msg provider.Message) bool {
+		return slices.ContainsFunc(msg.Attachments, func(a provider.Attachment) bool { return a.Path == path })
+	})
 }
 
-//////////////////// Saving and loading conversations \\\\\\\\\\\\\\\\\\\\
-
-var msgSeparator = strings.Repeat("-", 50)
-
-// Load loads the conversation from a file
-func (m *Manager) Load(filePath string) error {
-	if !strings.HasSuffix(filePath, config.ChatFileExt) {
-		return fmt.Errorf("Invalid file path: %s. Conversation must be a valid file, and including the %s extension", filePath, config.ChatFileExt)
-	}
-
-	// Now check that the file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return fmt.Errorf("Conversation file does not exist: %s", filePath)
+// RemoveAttachments removes the file at path from the messages it was sent
+// with. It returns the number of messages changed.
+func (m *Manager) RemoveAttachments(path string) int {
+	drop := func(msg *provider.Message) {
+		msg.Attachments = slices.DeleteFunc(slices.Clone(msg.Attachments), func(a provider.Attachment) bool { return a.Path == path })
 	}
 
-	file, err := os.Open(filePath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	m.messages = make([]provider.Message, 0)
-
-	scanner := bufio.NewScanner(file)
-	// Saved chats can contain long lines (pasted code, JSON, etc.); bufio's
-	// default 64KB cap would silently fail Scan(). Allow up to 10MB per line.
-	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
-	for scanner.Scan() {
-		// Read line by line
-		line := scanner.Text()
-		if line == msgSeparator {
+	changed := 0
+	for i := range m.messages {
+		if !slices.ContainsFunc(m.messages[i].Attachments, func(a provider.Attachment) bool { return a.Path == path }) {
 			continue
 		}
-
-		var role provider.ContextRole
-		switch {
-		case strings.Contains(line, fmt.Sprintf("%s: ", provider.RoleUser)):
-			role = provider.RoleUser
-		case strings.Contains(line, fmt.Sprintf("%s: ", provider.RoleAssistant)):
-			role = provider.RoleAssistant
-		case strings.Contains(line, fmt.Sprintf("%s: ", provider.RoleSystem)):
-			role = provider.RoleSystem
-		default:
-			fmt.Printf("Unknown role in line: %s\n", line)
-			continue
-		}
-
-		msgBody := strings.TrimPrefix(line, fmt.Sprintf("%s: ", role))
-
-		// Read the next lines until the separator to get the full message body
-		for scanner.Scan() {
-			nextLine := scanner.Text()
-
-			// Break if we reach the separator - end of message
-			if nextLine == msgSeparator {
-				break
-			}
-
-			// Append to message body
-			msgBody += "\n" + nextLine
-		}
-
-		// We reached the end of the message, create the message struct
-		msg := provider.Message{
-			Role:    role,
-			Content: msgBody,
-		}
-		m.messages = append(m.messages, msg)
+		drop(&m.messages[i])
+		m.updateShared(i, drop)
+		changed++
 	}
-
-	return scanner.Err()
+	return changed
 }
 
-// Save appends the conversation to an existing file
-// This assumes the path exists, and the filePath is valid and absolute (use utils.GetAbsolutePath helper)
-func (m *Manager) Save(filePath string) error {
-	// Filepath is path/to/conversation/conversation-name.termai.md
-	// Ensure the conversation name has the correct extension
-	if !strings.HasSuffix(filePath, config.ChatFileExt) {
-		filePath += config.ChatFileExt
-	}
-
-	// Check if the directory exists, create it if not
-	dir := filepath.Dir(filePath)
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
-			return fmt.Errorf("failed to create conversation directory: %w", err)
-		}
+// Truncate keeps the first n messages and drops the rest
+func (m *Manager) Truncate(n int) {
+	if n >= 0 && n < len(m.messages) {
+		m.messages = m.messages[:n]
 	}
+}
 
-	// If file exists, remove it (so it will be recreated)
-	if _, err := os.Stat(filePath); err == nil {
-		if err := os.Remove(filePath); err != nil {
-			return fmt.Errorf("failed to remove existing conversation file: %w", err)
+// LastIndex returns the index of the last message with the given role, -1 if none
+func (m *Manager) LastIndex(role provider.ContextRole) int {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].Role == role {
+			return i
 		}
 	}
+	return -1
+}
 
-	// Create the file
-	file, err := os.Create(filePath)
-	if err != nil {
-		return err
+// Clear clears all messages and starts a new conversation on the same profile,
+// with the same pinned files
+func (m *Manager) Clear() {
+	m.messages = make([]provider.Message, 0)
+	m.branches, m.active = nil, 0
+	m.metadata = Metadata{
+		CreatedAt:   time.Now(),
+		Profile:     m.metadata.Profile,
+		Model:       m.metadata.Model,
+		PinnedFiles: m.metadata.PinnedFiles,
 	}
+}
 
-	defer file.Close()
+// IsEmpty returns true if there are no messages
+func (m *Manager) IsEmpty() bool {
+	return len(m.messages) == 0
+}
 
-	// Write all messages to the file
-	for _, msg := range m.messages {
-		_, err := file.WriteString(string(msg.Role) + ": " + msg.Content + "\n" + msgSeparator + "\n")
-		if err != nil {
-			return err
-		}
+// Retrieve the last message of the conversation
+func (m *Manager) GetLastMessage() *provider.Message {
+	if len(m.messages) == 0 {
+		return nil
 	}
-
-	return nil
+	return &m.messages[len(m.messages)-1]
 }
@@ -0,0 +1,73 @@
+package context
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/KooQix/term-ai/internal/provider"
+)
+
+//////////////////// Pinned messages and files \\\\\\\\\\\\\\\\\\\\
+
+// Pinned messages are always part of the request: trimming to the context
+// window and compaction keep the turns holding them. Pinned files are context
+// files recorded with the conversation, so they come back when it is loaded.
+
+// SetPinned pins or unpins the message at index
+func (m *Manager) SetPinned(index int, pinned bool) error {
+	if index < 0 || index >= len(m.messages) {
+		return fmt.Errorf("no message at index %d", index)
+	}
+
+	pin := func(msg *provider.Message) {
+		// Messages share their meta with the copies made for branches, change a copy
+		meta := provider.MessageMeta{}
+		if msg.Meta != nil {
+			meta = *msg.Meta
+		}
+		meta.Pinned = pinned
+		msg.Meta = &meta
+	}
+
+	pin(&m.messages[index])
+	m.updateShared(index, pin)
+	return nil
+}
+
+// PinnedIndexes returns the indexes of the pinned messages
+func (m *Manager) PinnedIndexes() []int {
+	var indexes []int
+	for i, msg := range m.messages {
+		if msg.Meta != nil && msg.Meta.Pinned {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// PinFile records a context file as pinned
+func (m *Manager) PinFile(path string) {
+	if !slices.Contains(m.metadata.PinnedFiles, path) {
+		m.metadata.PinnedFiles = append(m.metadata.PinnedFiles, path)
+	}
+}
+
+// UnpinFile forgets a pinned context file, reporting whether it was pinned
+func (m *Manager) UnpinFile(path string) bool {
+	i := slices.Index(m.metadata.PinnedFiles, path)
+	if i < 0 {
+		return false
+	}
+	m.metadata.PinnedFiles = slices.Delete(m.metadata.PinnedFiles, i, i+1)
+	return true
+}
+
+// IsFilePinned reports whether a context file is pinned
+func (m *Manager) IsFilePinned(path string) bool {
+	return slices.Contains(m.metadata.PinnedFiles, path)
+}
+
+// PinnedFiles returns the paths of the pinned context files
+func (m *Manager) PinnedFiles() []string {
+	return slices.Clone(m.metadata.PinnedFiles)
+}
@@ -0,0 +1,381 @@
+package context
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/KooQix/term-ai/internal/config"
+	"github.com/KooQix/term-ai/internal/provider"
+	"github.com/KooQix/term-ai/internal/utils"
+)
+
+//////////////////// Saving and loading conversations \\\\\\\\\\\\\\\\\\\\
+
+// ConversationVersion is the version of the conversation format written by Save
+const ConversationVersion = 1
+
+// conversationFile is the on-disk layout of a saved conversation.
+// Messages are stored with every provider.Message field (tool calls, tool
+// results, images, names), so a save/load round-trip is lossless.
+type conversationFile struct {
+	Version  int                `json:"version"`
+	SavedAt  time.Time          `json:"saved_at"`
+	Metadata Metadata           `json:"metadata"`
+	Messages []provider.Message `json:"messages"` // path of the active branch
+
+	// Tree of a forked conversation (see Branch). Messages stays the active
+	// path, so readers unaware of branches still see a linear conversation.
+	Branches     []Branch `json:"branches,omitempty"`
+	ActiveBranch int      `json:"active_branch,omitempty"`
+}
+
+// Summary describes a saved conversation without loading it into a Manager
+type Summary struct {
+	Path     string
+	Metadata Metadata
+	Messages int // number of user and assistant messages
+	Branches int // number of branches, 1 for a conversation never forked
+
+	Encrypted bool
+}
+
+// ChatFilePath returns path with the current conversation extension, whatever
+// extension (current, legacy or none) it was given with
+func ChatFilePath(path string) string {
+	return config.TrimChatExt(path) + config.ChatFileExt
+}
+
+// ResolveChatFile finds the conversation file for a path given with or
+// without its extension. The current format is preferred over the legacy one.
+func ResolveChatFile(path string) (string, error) {
+	candidates := []string{path}
+	if !config.IsChatFile(path) {
+		candidates = []string{path + config.ChatFileExt, path + config.LegacyChatFileExt}
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("Conversation file does not exist: %s", path)
+}
+
+// UniqueChatPath returns a conversation path in dir named after title. When
+// the name is taken, "-2", "-3", ... is appended, so the same title in the
+// same directory always resolves the same way.
+func UniqueChatPath(dir, title string) string {
+	slug := utils.Slugify(title)
+	if slug == "" {
+		slug = "chat"
+	}
+
+	path := filepath.Join(dir, slug+config.ChatFileExt)
+	for i := 2; ; i++ {
+		if _, err := ResolveChatFile(config.TrimChatExt(path)); err != nil {
+			return path
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d%s", slug, i, config.ChatFileExt))
+	}
+}
+
+// Load loads the conversation from a file, in the current or the legacy format
+func (m *Manager) Load(filePath string) error {
+	if !config.IsChatFile(filePath) {
+		return fmt.Errorf("Invalid file path: %s. Conversation must be a valid file, and including the %s extension", filePath, config.ChatFileExt)
+	}
+
+	// Now check that the file exists
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return fmt.Errorf("Conversation file does not exist: %s", filePath)
+	}
+
+	conv, err := readConversation(filePath)
+	if err != nil {
+		return err
+	}
+
+	m.messages = conv.Messages
+	m.metadata = conv.Metadata
+	m.loadBranches(conv.Branches, conv.ActiveBranch)
+	return nil
+}
+
+// Conversation is a saved conversation read without a Manager
+type Conversation struct {
+	Path     string
+	Metadata Metadata
+	Messages []provider.Message // path of the active branch
+
+	Branches     []Branch // nil when the conversation was never forked
+	ActiveBranch int
+}
+
+// ReadConversation reads a saved conversation, in the current or the legacy format
+func ReadConversation(filePath string) (*Conversation, error) {
+	conv, err := readConversation(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conversation{
+		Path:         filePath,
+		Metadata:     conv.Metadata,
+		Messages:     conv.Messages,
+		Branches:     conv.Branches,
+		ActiveBranch: conv.ActiveBranch,
+	}, nil
+}
+
+// Conversation returns a snapshot of the managed conversation
+func (m *Manager) Conversation() *Conversation {
+	metadata := m.metadata
+	metadata.Tags = slices.Clone(metadata.Tags)
+	metadata.Files = slices.Clone(metadata.Files)
+	metadata.PinnedFiles = slices.Clone(metadata.PinnedFiles)
+
+	branches, active := m.treeSnapshot()
+	return &Conversation{
+		Metadata:     metadata,
+		Messages:     slices.Clone(m.messages),
+		Branches:     branches,
+		ActiveBranch: active,
+	}
+}
+
+// EncodeConversation renders a conversation in the saved (JSON) format
+func EncodeConversation(conv *Conversation) ([]byte, error) {
+	data, err := json.MarshalIndent(conversationFile{
+		Version:      ConversationVersion,
+		SavedAt:      time.Now(),
+		Metadata:     conv.Metadata,
+		Messages:     conv.Messages,
+		Branches:     conv.Branches,
+		ActiveBranch: conv.ActiveBranch,
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode conversation: %w", err)
+	}
+	return data, nil
+}
+
+// ReadSummary reads the metadata of a saved conversation. An encrypted one
+// read without a key is still summarized, with ErrNoKey: only its file date
+// is known.
+func ReadSummary(filePath string) (*Summary, error) {
+	conv, err := readConversation(filePath)
+	if errors.Is(err, ErrNoKey) {
+		summary := &Summary{Path: filePath, Branches: 1, Encrypted: true}
+		if info, err := os.Stat(filePath); err == nil {
+			summary.Metadata.UpdatedAt = info.ModTime()
+		}
+		return summary, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	count := 0
+	for _, msg := range conv.Messages {
+		if msg.Role == provider.RoleUser || msg.Role == provider.RoleAssistant {
+			count++
+		}
+	}
+
+	return &Summary{
+		Path:     filePath,
+		Metadata: conv.Metadata,
+		Messages: count,
+		Branches: max(len(conv.Branches), 1),
+
+		Encrypted: IsEncryptedFile(filePath),
+	}, nil
+}
+
+// readConversation reads a conversation file in the current or the legacy format
+func readConversation(filePath string) (*conversationFile, error) {
+	if !strings.HasSuffix(filePath, config.LegacyChatFileExt) {
+		return loadJSON(filePath)
+	}
+
+	messages, err := loadLegacy(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// The legacy format has no metadata, the file time is the best we have
+	conv := &conversationFile{Messages: messages}
+	if info, err := os.Stat(filePath); err == nil {
+		conv.Metadata.CreatedAt = info.ModTime()
+		conv.Metadata.UpdatedAt = info.ModTime()
+	}
+	return conv, nil
+}
+
+// Save writes the conversation to a file, replacing any previous content.
+// The current extension is enforced, so saving a conversation loaded from the
+// legacy format writes a new file next to it.
+// This assumes the filePath is valid and absolute (use utils.GetAbsolutePath helper)
+func (m *Manager) Save(filePath string) error {
+	// Filepath is path/to/conversation/conversation-name.termai.json
+	return m.write(ChatFilePath(filePath))
+}
+
+// write atomically writes the conversation to filePath as is
+func (m *Manager) write(filePath string) error {
+	now := time.Now()
+	if m.metadata.CreatedAt.IsZero() {
+		m.metadata.CreatedAt = now
+	}
+	m.metadata.UpdatedAt = now
+
+	return WriteConversation(filePath, m.Conversation())
+}
+
+// WriteConversation atomically writes a conversation to filePath, keeping its
+// metadata (dates included) untouched. It is encrypted when chat.encryption
+// is enabled.
+func WriteConversation(filePath string, conv *Conversation) error {
+	return writeConversation(filePath, conv, EncryptionEnabled())
+}
+
+// SetEncrypted rewrites a saved conversation encrypted or in plaintext,
+// reporting whether the file changed
+func SetEncrypted(filePath string, encrypt bool) (bool, error) {
+	if IsEncryptedFile(filePath) == encrypt {
+		return false, nil
+	}
+
+	conv, err := ReadConversation(filePath)
+	if err != nil {
+		return false, err
+	}
+	return true, writeConversation(filePath, conv, encrypt)
+}
+
+func writeConversation(filePath string, conv *Conversation, encrypt bool) error {
+	// Check if the directory exists, create it if not
+	dir := filepath.Dir(filePath)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return fmt.Errorf("failed to create conversation directory: %w", err)
+		}
+	}
+
+	data, err := EncodeConversation(conv)
+	if err != nil {
+		return err
+	}
+
+	if encrypt {
+		if data, err = Encrypt(data); err != nil {
+			return fmt.Errorf("failed to encrypt conversation: %w", err)
+		}
+	}
+
+	if err := utils.WriteFileAtomic(filePath, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write conversation file: %w", err)
+	}
+
+	return nil
+}
+
+// loadJSON reads a conversation saved in the current format
+func loadJSON(filePath string) (*conversationFile, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if IsEncrypted(data) {
+		if data, err = Decrypt(data); err != nil {
+			return nil, err
+		}
+	}
+
+	var conv conversationFile
+	if err := json.Unmarshal(data, &conv); err != nil {
+		return nil, fmt.Errorf("failed to parse conversation file: %w", err)
+	}
+
+	if conv.Version > ConversationVersion {
+		return nil, fmt.Errorf("conversation format version %d is newer than supported (%d), please update termai", conv.Version, ConversationVersion)
+	}
+
+	if conv.Messages == nil {
+		conv.Messages = make([]provider.Message, 0)
+	}
+
+	return &conv, nil
+}
+
+var msgSeparator = strings.Repeat("-", 50)
+
+// loadLegacy reads a conversation saved in the line-prefixed format
+// ("role: content" blocks separated by dashes). Only roles and text survive
+// in that format.
+func loadLegacy(filePath string) ([]provider.Message, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	messages := make([]provider.Message, 0)
+
+	scanner := bufio.NewScanner(file)
+	// Saved chats can contain long lines (pasted code, JSON, etc.); bufio's
+	// default 64KB cap would silently fail Scan(). Allow up to 10MB per line.
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		// Read line by line
+		line := scanner.Text()
+		if line == msgSeparator {
+			continue
+		}
+
+		var role provider.ContextRole
+		switch {
+		case strings.Contains(line, fmt.Sprintf("%s: ", provider.RoleUser)):
+			role = provider.RoleUser
+		case strings.Contains(line, fmt.Sprintf("%s: ", provider.RoleAssistant)):
+			role = provider.RoleAssistant
+		case strings.Contains(line, fmt.Sprintf("%s: ", provider.RoleSystem)):
+			role = provider.RoleSystem
+		default:
+			fmt.Printf("Unknown role in line: %s\n", line)
+			continue
+		}
+
+		msgBody := strings.TrimPrefix(line, fmt.Sprintf("%s: ", role))
+
+		// Read the next lines until the separator to get the full message body
+		for scanner.Scan() {
+			nextLine := scanner.Text()
+
+			// Break if we reach the separator - end of message
+			if nextLine == msgSeparator {
+				break
+			}
+
+			// Append to message body
+			msgBody += "\n" + nextLine
+		}
+
+		// We reached the end of the message, create the message struct
+		messages = append(messages, provider.Message{
+			Role:    role,
+			Content: msgBody,
+		})
+	}
+
+	return messages, scanner.Err()
+}
@@ -0,0 +1,174 @@
+package context
+
+import (
+	"slices"
+	"unicode/utf8"
+
+	"github.com/KooQix/term-ai/internal/provider"
+)
+
+//////////////////// Context window \\\\\\\\\\\\\\\\\\\\
+
+// Token counts are estimated locally: providers tokenize differently and
+// exposing their tokenizers isn't worth a dependency. The estimate errs on the
+// high side so a trimmed history fits the window.
+
+// Trimming policies, applied before each request
+const (
+	PolicyDropOldest    = "drop_oldest"    // drop the oldest turns until the history fits
+	PolicySlidingWindow = "sliding_window" // keep the last turns only, then drop the oldest ones if still too large
+	PolicyNone          = "none"           // send the whole history
+)
+
+const (
+	// MessageOverheadTokens accounts for the role and separators of a message
+	MessageOverheadTokens = 4
+	// ImageTokens is what an image costs, about a 1024px image in high detail
+	ImageTokens = 765
+)
+
+// EstimateTextTokens estimates the tokens of a text: about 4 bytes per token
+// for ASCII, one token per rune otherwise (CJK, emoji, ...)
+func EstimateTextTokens(text string) int {
+	ascii, other := 0, 0
+	for i := 0; i < len(text); {
+		if text[i] < utf8.RuneSelf {
+			ascii++
+			i++
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		other++
+		i += size
+	}
+	return (ascii+3)/4 + other
+}
+
+// EstimateTokens estimates the tokens a message takes in a request, including
+// its images and tool calls
+func EstimateTokens(msg provider.Message) int {
+	tokens := MessageOverheadTokens + EstimateTextTokens(msg.Content) + len(msg.Images)*ImageTokens
+	tokens += EstimateTextTokens(msg.Name) + EstimateTextTokens(msg.ToolCallID)
+	for _, call := range msg.ToolCalls {
+		tokens += MessageOverheadTokens + EstimateTextTokens(call.Function.Name) + EstimateTextTokens(call.Function.Arguments)
+	}
+	for _, a := range msg.Attachments {
+		tokens += AttachmentTokens(a)
+	}
+	return tokens
+}
+
+// AttachmentTokens estimates the tokens an attached file adds to a message
+func AttachmentTokens(a provider.Attachment) int {
+	if a.IsImage() {
+		return ImageTokens
+	}
+	// Content and the lines around it naming the file, images of pages without text
+	return MessageOverheadTokens + EstimateTextTokens(a.Content) + 2*EstimateTextTokens(a.Name) + len(a.PageImages)*ImageTokens
+}
+
+// EstimateMessagesTokens estimates the tokens of a list of messages
+func EstimateMessagesTokens(messages []provider.Message) int {
+	tokens := 0
+	for _, msg := range messages {
+		tokens += EstimateTokens(msg)
+	}
+	return tokens
+}
+
+// turn is a user message and everything up to the next one. System messages
+// inside its span are not part of it.
+type turn struct {
+	start, end int // span of the turn in the messages
+	tokens     int // tokens of the turn's own messages
+	pinned     bool
+}
+
+// splitTurns splits a history into turns, messages before the first user
+// message forming a turn of their own
+func splitTurns(messages []provider.Message) []turn {
+	var turns []turn
+	for i, msg := range messages {
+		if msg.Role == provider.RoleSystem {
+			continue
+		}
+		if len(turns) == 0 || msg.Role == provider.RoleUser {
+			turns = append(turns, turn{start: i})
+		}
+		t := &turns[len(turns)-1]
+		t.end = i + 1
+		t.tokens += EstimateTokens(msg)
+		t.pinned = t.pinned || (msg.Meta != nil && msg.Meta.Pinned)
+	}
+	return turns
+}
+
+// FitResult is a history trimmed to a token budget
+type FitResult struct {
+	Messages []provider.Message
+	Tokens   int // estimated tokens of the kept messages
+	Dropped  int // messages left out
+}
+
+// Fit trims a history to a token budget following a policy. Whole turns (a
+// user message and everything up to the next one) are dropped, oldest first;
+// system messages, turns holding a pinned message and the last turn are always
+// kept, so the result may still exceed the budget. The sliding_window policy
+// first keeps only the last window turns. The messages are not modified.
+func Fit(messages []provider.Message, budget int, policy string, window int) FitResult {
+	result := FitResult{Messages: messages, Tokens: EstimateMessagesTokens(messages)}
+	if policy == PolicyNone || len(messages) == 0 {
+		return result
+	}
+
+	turns := splitTurns(messages)
+	if len(turns) < 2 {
+		return result
+	}
+
+	dropped := make([]bool, len(turns))
+	total := result.Tokens
+
+	if policy == PolicySlidingWindow && window > 0 {
+		for i := range len(turns) - window {
+			if !turns[i].pinned {
+				dropped[i] = true
+				total -= turns[i].tokens
+			}
+		}
+	}
+
+	for i := 0; i < len(turns)-1 && total > budget; i++ {
+		if !dropped[i] && !turns[i].pinned {
+			dropped[i] = true
+			total -= turns[i].tokens
+		}
+	}
+
+	if !slices.Contains(dropped, true) {
+		return result
+	}
+
+	// Keep system messages where they are, drop the messages of dropped turns
+	droppedAt := make([]bool, len(messages))
+	for i, t := range turns {
+		if dropped[i] {
+			for j := t.start; j < t.end; j++ {
+				droppedAt[j] = messages[j].Role != provider.RoleSystem
+			}
+		}
+	}
+
+	kept := make([]provider.Message, 0, len(messages))
+	for i, msg := range messages {
+		if droppedAt[i] {
+			result.Dropped++
+			continue
+		}
+		kept = append(kept, msg)
+	}
+
+	result.Messages = kept
+	result.Tokens = total
+	return result
+}
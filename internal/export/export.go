@@ -0,0 +1,252 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/KooQix/term-ai/internal/config"
+	ctxmanager "github.com/KooQix/term-ai/internal/context"
+	"github.com/KooQix/term-ai/internal/provider"
+	"github.com/KooQix/term-ai/internal/utils"
+)
+
+// Format is an export output format
+type Format string
+
+const (
+	FormatHTML     Format = "html"
+	FormatMarkdown Format = "md"
+	FormatJSON     Format = "json"
+	FormatPDF      Format = "pdf"
+)
+
+// Formats lists the supported export formats
+var Formats = []Format{FormatHTML, FormatMarkdown, FormatJSON, FormatPDF}
+
+// ParseFormat validates a format name ("markdown" is accepted for "md")
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case FormatHTML, FormatMarkdown, FormatJSON, FormatPDF:
+		return f, nil
+	case "markdown":
+		return FormatMarkdown, nil
+	}
+	return "", fmt.Errorf("unknown export format '%s' (use html, md, json or pdf)", name)
+}
+
+// Ext returns the file extension of the format
+func (f Format) Ext() string {
+	return "." + string(f)
+}
+
+// Export writes the conversation to w in the given format
+func Export(w io.Writer, conv *ctxmanager.Conversation, format Format) error {
+	switch format {
+	case FormatHTML:
+		return writeHTML(w, conv)
+	case FormatMarkdown:
+		_, err := io.WriteString(w, markdown(conv))
+		return err
+	case FormatJSON:
+		data, err := ctxmanager.EncodeConversation(conv)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case FormatPDF:
+		return writePDF(w, conv)
+	}
+	return fmt.Errorf("unknown export format '%s'", format)
+}
+
+// WriteFile exports the conversation to path, replacing it atomically
+func WriteFile(path string, conv *ctxmanager.Conversation, format Format) error {
+	var buf bytes.Buffer
+	if err := Export(&buf, conv, format); err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(path, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	return nil
+}
+
+// DefaultFileName names an export after the conversation title, or after
+// fallback (typically the chat file) for untitled conversations
+func DefaultFileName(conv *ctxmanager.Conversation, fallback string, format Format) string {
+	name := utils.Slugify(conv.Metadata.Title)
+	if name == "" && fallback != "" {
+		name = filepath.Base(config.TrimChatExt(fallback))
+	}
+	if name == "" {
+		name = "conversation"
+	}
+	return name + format.Ext()
+}
+
+//////////////////// Shared helpers \\\\\\\\\\\\\\\\\\\\
+
+type segmentKind int
+
+const (
+	segmentText segmentKind = iota
+	segmentCode
+	segmentThinking
+)
+
+// segment is a part of a message body: prose, a fenced code block or a
+// <think> block emitted by reasoning models
+type segment struct {
+	kind segmentKind
+	text string
+	lang string // code blocks only
+}
+
+var thinkRe = regexp.MustCompile(`(?s)<think(?:ing)?>(.*?)</think(?:ing)?>`)
+
+// splitContent cuts a message body into prose, code and thinking segments
+func splitContent(content string) []segment {
+	var segments []segment
+
+	last := 0
+	for _, loc := range thinkRe.FindAllStringSubmatchIndex(content, -1) {
+		segments = append(segments, splitFences(content[last:loc[0]])...)
+		if thinking := strings.TrimSpace(content[loc[2]:loc[3]]); thinking != "" {
+			segments = append(segments, segment{kind: segmentThinking, text: thinking})
+		}
+		last = loc[1]
+	}
+	segments = append(segments, splitFences(content[last:])...)
+
+	return segments
+}
+
+// splitFences cuts markdown into prose and fenced code blocks. An unclosed
+// fence runs to the end of the text.
+func splitFences(text string) []segment {
+	var (
+		segments []segment
+		buf      []string
+		inCode   bool
+		fence    string
+		lang     string
+	)
+
+	flush := func(kind segmentKind) {
+		joined := strings.Join(buf, "\n")
+		if kind == segmentCode || strings.TrimSpace(joined) != "" {
+			segments = append(segments, segment{kind: kind, text: joined, lang: lang})
+		}
+		buf = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case !inCode && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")):
+			flush(segmentText)
+			inCode = true
+			fence = trimmed[:3]
+			lang = strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1]))
+		case inCode && strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "":
+			flush(segmentCode)
+			inCode = false
+			lang = ""
+		default:
+			buf = append(buf, line)
+		}
+	}
+
+	if inCode {
+		flush(segmentCode)
+	} else {
+		flush(segmentText)
+	}
+	return segments
+}
+
+// attachmentLang returns the highlighting language of an attached file, from its extension
+func attachmentLang(a provider.Attachment) string {
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(a.Name)), ".")
+}
+
+// roleLabel is the heading shown for a message
+func roleLabel(msg provider.Message) string {
+	switch msg.Role {
+	case provider.RoleUser:
+		return "You"
+	case provider.RoleAssistant:
+		if msg.Meta != nil && msg.Meta.Model != "" {
+			return fmt.Sprintf("Assistant (%s)", msg.Meta.Model)
+		}
+		return "Assistant"
+	case provider.RoleSystem:
+		if ctxmanager.IsSummary(msg) {
+			return fmt.Sprintf("Summary of %d earlier messages", msg.Meta.Compacted)
+		}
+		return "System"
+	case provider.RoleTool:
+		if msg.Name != "" {
+			return fmt.Sprintf("Tool result (%s)", msg.Name)
+		}
+		return "Tool result"
+	}
+	return string(msg.Role)
+}
+
+// messageTime returns the time of a message, zero when unknown
+func messageTime(msg provider.Message) time.Time {
+	if msg.Meta != nil {
+		return msg.Meta.CreatedAt
+	}
+	return time.Time{}
+}
+
+// title returns the conversation title, or a generic one
+func title(conv *ctxmanager.Conversation) string {
+	if conv.Metadata.Title != "" {
+		return conv.Metadata.Title
+	}
+	return "Conversation"
+}
+
+// metadataRows lists the non-empty metadata fields as label/value pairs
+func metadataRows(conv *ctxmanager.Conversation) [][2]string {
+	meta := conv.Metadata
+	var rows [][2]string
+
+	if !meta.CreatedAt.IsZero() {
+		rows = append(rows, [2]string{"Created", formatTime(meta.CreatedAt)})
+	}
+	if !meta.UpdatedAt.IsZero() {
+		rows = append(rows, [2]string{"Updated", formatTime(meta.UpdatedAt)})
+	}
+	if meta.Profile != "" {
+		profile := meta.Profile
+		if meta.Model != "" {
+			profile += " (" + meta.Model + ")"
+		}
+		rows = append(rows, [2]string{"Profile", profile})
+	} else if meta.Model != "" {
+		rows = append(rows, [2]string{"Model", meta.Model})
+	}
+	if len(meta.Tags) > 0 {
+		rows = append(rows, [2]string{"Tags", strings.Join(meta.Tags, ", ")})
+	}
+	if len(meta.Files) > 0 {
+		rows = append(rows, [2]string{"Files", strings.Join(meta.Files, ", ")})
+	}
+	rows = append(rows, [2]string{"Exported", formatTime(time.Now())})
+
+	return rows
+}
+
+func formatTime(t time.Time) string {
+	return t.Local().Format("2006-01-02 15:04")
+}
@@ -0,0 +1,128 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	ctxmanager "github.com/KooQix/term-ai/internal/context"
+	"github.com/KooQix/term-ai/internal/provider"
+	"github.com/KooQix/term-ai/internal/ui"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// htmlStyle is embedded in every export so the file is self-contained
+const htmlStyle = `
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; line-height: 1.5; color: #1f2328; background: #f6f8fa; margin: 0; }
+main { max-width: 860px; margin: 0 auto; padding: 2rem 1rem; }
+h1 { margin-top: 0; }
+table.meta { border-collapse: collapse; margin-bottom: 2rem; font-size: 0.9rem; }
+table.meta th { text-align: left; padding: 0.2rem 1rem 0.2rem 0; color: #59636e; font-weight: 600; vertical-align: top; }
+table.meta td { padding: 0.2rem 0; word-break: break-all; }
+.msg { background: #fff; border: 1px solid #d1d9e0; border-left-width: 4px; border-radius: 6px; padding: 0.75rem 1rem; margin-bottom: 1rem; }
+.msg.user { border-left-color: #0969da; }
+.msg.assistant { border-left-color: #1a7f37; }
+.msg.system { border-left-color: #8250df; }
+.msg.tool { border-left-color: #9a6700; }
+.msg header { display: flex; justify-content: space-between; font-weight: 600; margin-bottom: 0.5rem; }
+.msg header time { font-weight: normal; color: #59636e; font-size: 0.85rem; }
+.msg pre { padding: 0.75rem; border-radius: 6px; overflow-x: auto; font-size: 0.85rem; }
+.msg code { font-family: ui-monospace, Menlo, Consolas, monospace; }
+.msg img { max-width: 100%; border-radius: 6px; }
+.msg table { border-collapse: collapse; }
+.msg table td, .msg table th { border: 1px solid #d1d9e0; padding: 0.25rem 0.5rem; }
+details { background: #f6f8fa; border-radius: 6px; padding: 0.5rem 0.75rem; margin: 0.5rem 0; }
+details summary { cursor: pointer; color: #59636e; }
+details pre.plain { background: #fff; white-space: pre-wrap; }
+`
+
+// writeHTML renders the conversation as a single self-contained HTML page:
+// styles are inlined, code is highlighted with inline colors and images are
+// embedded as data URLs
+func writeHTML(w io.Writer, conv *ctxmanager.Conversation) error {
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+	var sb strings.Builder
+	esc := html.EscapeString
+
+	sb.WriteString("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n")
+	sb.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	fmt.Fprintf(&sb, "<title>%s</title>\n<style>%s</style>\n</head>\n<body>\n<main>\n", esc(title(conv)), htmlStyle)
+	fmt.Fprintf(&sb, "<h1>%s</h1>\n<table class=\"meta\">\n", esc(title(conv)))
+	for _, row := range metadataRows(conv) {
+		fmt.Fprintf(&sb, "<tr><th>%s</th><td>%s</td></tr>\n", esc(row[0]), esc(row[1]))
+	}
+	sb.WriteString("</table>\n")
+
+	for _, msg := range conv.Messages {
+		fmt.Fprintf(&sb, "<section class=\"msg %s\">\n<header><span>%s</span>", esc(string(msg.Role)), esc(roleLabel(msg)))
+		if t := messageTime(msg); !t.IsZero() {
+			fmt.Fprintf(&sb, "<time>%s</time>", esc(formatTime(t)))
+		}
+		sb.WriteString("</header>\n")
+
+		if msg.Role == provider.RoleTool {
+			writeHTMLDetails(&sb, "Output", plainBlock(msg.Content))
+		} else {
+			for _, seg := range splitContent(msg.Content) {
+				switch seg.kind {
+				case segmentText:
+					var buf bytes.Buffer
+					if err := md.Convert([]byte(seg.text), &buf); err != nil {
+						buf.Reset()
+						buf.WriteString(plainBlock(seg.text))
+					}
+					sb.Write(buf.Bytes())
+				case segmentCode:
+					sb.WriteString(codeBlock(seg.text, seg.lang))
+				case segmentThinking:
+					writeHTMLDetails(&sb, "💭 Thinking", plainBlock(seg.text))
+				}
+			}
+		}
+
+		for _, call := range msg.ToolCalls {
+			writeHTMLDetails(&sb, "🔧 Tool call: "+call.Function.Name, codeBlock(prettyArguments(call.Function.Arguments), "json"))
+		}
+
+		for _, a := range msg.Attachments {
+			if !a.IsImage() {
+				writeHTMLDetails(&sb, "📎 "+a.Name, codeBlock(a.Content, attachmentLang(a)))
+			}
+		}
+
+		for i, img := range msg.AllImages() {
+			// Only embedded images: a remote URL would make the file depend on the network
+			if strings.HasPrefix(img, "data:image/") {
+				fmt.Fprintf(&sb, "<p><img src=\"%s\" alt=\"image %d\"></p>\n", esc(img), i+1)
+			}
+		}
+
+		sb.WriteString("</section>\n")
+	}
+
+	sb.WriteString("</main>\n</body>\n</html>\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func writeHTMLDetails(sb *strings.Builder, summary, body string) {
+	fmt.Fprintf(sb, "<details>\n<summary>%s</summary>\n%s\n</details>\n", html.EscapeString(summary), body)
+}
+
+// codeBlock highlights code, falling back to an escaped plain block
+func codeBlock(code, lang string) string {
+	highlighted, err := ui.HighlightHTML(strings.Trim(code, "\n"), lang)
+	if err != nil {
+		return plainBlock(code)
+	}
+	return highlighted
+}
+
+func plainBlock(text string) string {
+	return "<pre class=\"plain\"><code>" + html.EscapeString(strings.Trim(text, "\n")) + "</code></pre>\n"
+}
@@ -0,0 +1,92 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	ctxmanager "github.com/KooQix/term-ai/internal/context"
+	"github.com/KooQix/term-ai/internal/provider"
+)
+
+// markdown renders the conversation as a Markdown document. Tool calls and
+// thinking blocks are wrapped in <details> so they render collapsed on GitHub
+// and most Markdown viewers.
+func markdown(conv *ctxmanager.Conversation) string {
+	var meta strings.Builder
+	meta.WriteString("| | |\n|---|---|")
+	for _, row := range metadataRows(conv) {
+		fmt.Fprintf(&meta, "\n| **%s** | %s |", row[0], escapeTableCell(row[1]))
+	}
+
+	// Blocks are separated by a blank line
+	blocks := []string{"# " + title(conv), meta.String()}
+
+	for _, msg := range conv.Messages {
+		heading := roleLabel(msg)
+		if t := messageTime(msg); !t.IsZero() {
+			heading += " · " + formatTime(t)
+		}
+		blocks = append(blocks, "---", "### "+heading)
+
+		if msg.Role == provider.RoleTool {
+			blocks = append(blocks, markdownDetails("Output", fence(msg.Content, "")))
+			continue
+		}
+
+		for _, seg := range splitContent(msg.Content) {
+			switch seg.kind {
+			case segmentText:
+				blocks = append(blocks, strings.Trim(seg.text, "\n"))
+			case segmentCode:
+				blocks = append(blocks, fence(seg.text, seg.lang))
+			case segmentThinking:
+				blocks = append(blocks, markdownDetails("💭 Thinking", seg.text))
+			}
+		}
+
+		for _, call := range msg.ToolCalls {
+			blocks = append(blocks, markdownDetails("🔧 Tool call: "+call.Function.Name, fence(prettyArguments(call.Function.Arguments), "json")))
+		}
+
+		for _, a := range msg.Attachments {
+			if !a.IsImage() {
+				blocks = append(blocks, markdownDetails("📎 "+a.Name, fence(a.Content, attachmentLang(a))))
+			}
+		}
+
+		for i, img := range msg.AllImages() {
+			blocks = append(blocks, fmt.Sprintf("![image %d](%s)", i+1, img))
+		}
+	}
+
+	return strings.Join(blocks, "\n\n") + "\n"
+}
+
+func markdownDetails(summary, body string) string {
+	return fmt.Sprintf("<details>\n<summary>%s</summary>\n\n%s\n\n</details>", summary, strings.Trim(body, "\n"))
+}
+
+// fence wraps code in a fenced block long enough not to clash with backticks
+// inside the code
+func fence(code, lang string) string {
+	marker := "```"
+	for strings.Contains(code, marker) {
+		marker += "`"
+	}
+	return marker + lang + "\n" + strings.Trim(code, "\n") + "\n" + marker
+}
+
+func escapeTableCell(text string) string {
+	return strings.ReplaceAll(text, "|", `\|`)
+}
+
+// prettyArguments indents JSON tool arguments, leaving anything else untouched
+func prettyArguments(args string) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(args), "", "  "); err != nil {
+		return args
+	}
+	return buf.String()
+}
@@ -0,0 +1,413 @@
+package export
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/gif" // decoders for embedded images
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"regexp"
+	"strings"
+
+	ctxmanager "github.com/KooQix/term-ai/internal/context"
+	"github.com/KooQix/term-ai/internal/provider"
+)
+
+// The PDF export is a minimal writer: it only uses the standard Type 1 fonts
+// (no embedding, WinAnsi encoding, so characters outside Latin-1 are replaced)
+// and lays out text line by line. It is meant for sharing and archiving, the
+// HTML export is the faithful one.
+
+const (
+	pdfPageWidth   = 595.0 // A4, in points
+	pdfPageHeight  = 842.0
+	pdfMargin      = 50.0
+	pdfTextWidth   = pdfPageWidth - 2*pdfMargin
+	pdfMaxImageH   = 400.0
+	pdfBodySize    = 10.0
+	pdfCodeSize    = 8.5
+	pdfLineSpacing = 1.35
+	pdfCodeColumns = 97 // Courier glyphs are 0.6 em wide: pdfTextWidth / (pdfCodeSize * 0.6)
+)
+
+type pdfFont int
+
+const (
+	fontRegular pdfFont = iota
+	fontBold
+	fontMono
+)
+
+var pdfFontNames = [...]string{"Helvetica", "Helvetica-Bold", "Courier"}
+
+// helveticaWidths are the glyph widths (1/1000 em) of Helvetica for ASCII 32-126
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// winAnsiExtras maps the non Latin-1 characters of the WinAnsi encoding
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '•': 0x95, '–': 0x96, '—': 0x97,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '™': 0x99,
+}
+
+type pdfImage struct {
+	width, height int
+	data          []byte // zlib-compressed RGB samples
+}
+
+type pdfDoc struct {
+	pages  []*bytes.Buffer // content streams
+	images []pdfImage
+	page   *bytes.Buffer
+	y      float64 // baseline of the next line
+}
+
+// writePDF renders the conversation as a paginated A4 document
+func writePDF(w io.Writer, conv *ctxmanager.Conversation) error {
+	d := &pdfDoc{}
+
+	d.paragraph(title(conv), fontBold, 18, 0)
+	d.gap(4)
+	for _, row := range metadataRows(conv) {
+		d.paragraph(row[0]+": "+row[1], fontRegular, 9, 0)
+	}
+
+	for _, msg := range conv.Messages {
+		d.gap(8)
+		d.rule()
+		d.gap(6)
+
+		heading := roleLabel(msg)
+		if t := messageTime(msg); !t.IsZero() {
+			heading += " - " + formatTime(t)
+		}
+		d.paragraph(heading, fontBold, 11, 0)
+		d.gap(2)
+
+		if msg.Role == provider.RoleTool {
+			d.code(msg.Content)
+			continue
+		}
+
+		for _, seg := range splitContent(msg.Content) {
+			switch seg.kind {
+			case segmentText:
+				d.prose(seg.text)
+			case segmentCode:
+				d.code(seg.text)
+			case segmentThinking:
+				d.paragraph("Thinking:", fontBold, pdfBodySize, 0)
+				d.paragraph(seg.text, fontRegular, pdfBodySize, 12)
+			}
+			d.gap(4)
+		}
+
+		for _, call := range msg.ToolCalls {
+			d.paragraph("Tool call: "+call.Function.Name, fontBold, pdfBodySize, 0)
+			d.code(prettyArguments(call.Function.Arguments))
+		}
+
+		for _, a := range msg.Attachments {
+			if !a.IsImage() {
+				d.paragraph("Attached file: "+a.Name, fontBold, pdfBodySize, 0)
+				d.code(a.Content)
+			}
+		}
+
+		for i, img := range msg.AllImages() {
+			if err := d.image(img); err != nil {
+				d.paragraph(fmt.Sprintf("[image %d could not be embedded: %v]", i+1, err), fontRegular, pdfBodySize, 0)
+			}
+		}
+	}
+
+	return d.write(w)
+}
+
+var (
+	mdHeadingRe  = regexp.MustCompile(`^#{1,6}\s+`)
+	mdBulletRe   = regexp.MustCompile(`^(\s*)[-*+]\s+`)
+	mdEmphasisRe = regexp.MustCompile("\\*\\*|__|`")
+)
+
+// prose lays out markdown text, with headings in bold and the inline markup removed
+func (d *pdfDoc) prose(text string) {
+	for _, line := range strings.Split(strings.Trim(text, "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			d.gap(pdfBodySize * 0.6)
+			continue
+		}
+
+		font := fontRegular
+		if mdHeadingRe.MatchString(line) {
+			line = mdHeadingRe.ReplaceAllString(line, "")
+			font = fontBold
+		}
+		line = mdBulletRe.ReplaceAllString(line, "$1• ")
+		line = mdEmphasisRe.ReplaceAllString(line, "")
+
+		indent := float64(len(line)-len(strings.TrimLeft(line, " "))) * 3
+		d.paragraph(strings.TrimSpace(line), font, pdfBodySize, indent)
+	}
+}
+
+// code lays out a block in monospace, keeping indentation and hard-wrapping long lines
+func (d *pdfDoc) code(text string) {
+	for _, line := range strings.Split(strings.Trim(text, "\n"), "\n") {
+		encoded := encodeWinAnsi(strings.ReplaceAll(line, "\t", "    "))
+		for {
+			chunk := encoded
+			if len(chunk) > pdfCodeColumns {
+				chunk = chunk[:pdfCodeColumns]
+			}
+			d.line(chunk, fontMono, pdfCodeSize, 0)
+			encoded = encoded[len(chunk):]
+			if len(encoded) == 0 {
+				break
+			}
+		}
+	}
+}
+
+// paragraph lays out text word-wrapped to the page width minus indent
+func (d *pdfDoc) paragraph(text string, font pdfFont, size, indent float64) {
+	for _, raw := range strings.Split(text, "\n") {
+		var line []byte
+		for _, word := range strings.Fields(raw) {
+			encoded := encodeWinAnsi(word)
+			candidate := encoded
+			if len(line) > 0 {
+				candidate = append(append(append([]byte{}, line...), ' '), encoded...)
+			}
+			if textWidth(candidate, font, size) <= pdfTextWidth-indent {
+				line = candidate
+				continue
+			}
+			if len(line) > 0 {
+				d.line(line, font, size, indent)
+			}
+			// Words wider than the page are cut
+			for textWidth(encoded, font, size) > pdfTextWidth-indent && len(encoded) > 1 {
+				n := len(encoded) - 1
+				for n > 1 && textWidth(encoded[:n], font, size) > pdfTextWidth-indent {
+					n--
+				}
+				d.line(encoded[:n], font, size, indent)
+				encoded = encoded[n:]
+			}
+			line = encoded
+		}
+		d.line(line, font, size, indent)
+	}
+}
+
+// line writes one line of already encoded text, starting a new page if needed
+func (d *pdfDoc) line(text []byte, font pdfFont, size, indent float64) {
+	d.ensure(size * pdfLineSpacing)
+	d.y -= size * pdfLineSpacing
+	if len(text) == 0 {
+		return
+	}
+	fmt.Fprintf(d.page, "BT /F%d %.2f Tf %.2f %.2f Td (%s) Tj ET\n", font+1, size, pdfMargin+indent, d.y, escapePDFString(text))
+}
+
+func (d *pdfDoc) gap(height float64) {
+	d.ensure(height)
+	d.y -= height
+}
+
+func (d *pdfDoc) rule() {
+	d.ensure(1)
+	fmt.Fprintf(d.page, "0.8 G 0.5 w %.2f %.2f m %.2f %.2f l S 0 G\n", pdfMargin, d.y, pdfPageWidth-pdfMargin, d.y)
+}
+
+// image embeds a data URL image, scaled down to fit the page
+func (d *pdfDoc) image(dataURL string) error {
+	_, payload, ok := strings.Cut(dataURL, ";base64,")
+	if !ok {
+		return fmt.Errorf("not an embedded image")
+	}
+	raw, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return err
+	}
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+
+	bounds := img.Bounds()
+	var samples bytes.Buffer
+	zw := zlib.NewWriter(&samples)
+	row := make([]byte, 0, bounds.Dx()*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row = row[:0]
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			row = append(row, byte(r>>8), byte(g>>8), byte(b>>8))
+		}
+		zw.Write(row)
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	d.images = append(d.images, pdfImage{width: bounds.Dx(), height: bounds.Dy(), data: samples.Bytes()})
+
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+	scale := min(1, pdfTextWidth/w, pdfMaxImageH/h)
+	w, h = w*scale, h*scale
+
+	d.gap(4)
+	d.ensure(h)
+	d.y -= h
+	fmt.Fprintf(d.page, "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", w, h, pdfMargin, d.y, len(d.images))
+	return nil
+}
+
+// ensure starts a new page when less than height is left on the current one
+func (d *pdfDoc) ensure(height float64) {
+	if d.page != nil && d.y-height >= pdfMargin {
+		return
+	}
+	d.page = &bytes.Buffer{}
+	d.pages = append(d.pages, d.page)
+	d.y = pdfPageHeight - pdfMargin
+}
+
+// write serializes the document: catalog, page tree, fonts, images, then a
+// page object and a content stream per page
+func (d *pdfDoc) write(w io.Writer) error {
+	d.ensure(0)
+
+	var (
+		buf     bytes.Buffer
+		offsets []int
+	)
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	const firstFont = 3
+	firstImage := firstFont + len(pdfFontNames)
+	firstPage := firstImage + len(d.images)
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+
+	for _, name := range pdfFontNames {
+		object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+	}
+
+	for _, img := range d.images {
+		object(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream",
+			img.width, img.height, len(img.data), img.data))
+	}
+
+	var resources strings.Builder
+	resources.WriteString("<< /Font <<")
+	for i := range pdfFontNames {
+		fmt.Fprintf(&resources, " /F%d %d 0 R", i+1, firstFont+i)
+	}
+	resources.WriteString(" >>")
+	if len(d.images) > 0 {
+		resources.WriteString(" /XObject <<")
+		for i := range d.images {
+			fmt.Fprintf(&resources, " /Im%d %d 0 R", i+1, firstImage+i)
+		}
+		resources.WriteString(" >>")
+	}
+	resources.WriteString(" >>")
+
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %g %g] /Resources %s /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, resources.String(), firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.Bytes()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// encodeWinAnsi converts text to the WinAnsi encoding of the standard fonts,
+// replacing what it can't represent with '?'
+func encodeWinAnsi(text string) []byte {
+	out := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r == '\t':
+			out = append(out, ' ')
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			out = append(out, byte(r))
+		default:
+			if b, ok := winAnsiExtras[r]; ok {
+				out = append(out, b)
+			} else if r >= 0x20 {
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
+
+// textWidth measures encoded text in points. Characters outside ASCII use an
+// average width, and bold text is assumed slightly wider than regular.
+func textWidth(text []byte, font pdfFont, size float64) float64 {
+	if font == fontMono {
+		return float64(len(text)) * 0.6 * size
+	}
+
+	units := 0
+	for _, b := range text {
+		if b >= 32 && b < 127 {
+			units += helveticaWidths[b-32]
+		} else {
+			units += 600
+		}
+	}
+	if font == fontBold {
+		units = units * 106 / 100
+	}
+	return float64(units) * size / 1000
+}
+
+func escapePDFString(text []byte) string {
+	var sb strings.Builder
+	for _, b := range text {
+		switch {
+		case b == '(' || b == ')' || b == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(b)
+		case b < 0x20 || b >= 0x7f:
+			fmt.Fprintf(&sb, "\\%03o", b)
+		default:
+			sb.WriteByte(b)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,273 @@
+package fileprocessor
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/KooQix/term-ai/internal/config"
+)
+
+// Extractor reads a file into an attachment
+type Extractor interface {
+	Extract(path string) (*FileAttachment, error)
+}
+
+// ExtractorFunc lets a function be used as an Extractor
+type ExtractorFunc func(path string) (*FileAttachment, error)
+
+func (f ExtractorFunc) Extract(path string) (*FileAttachment, error) {
+	return f(path)
+}
+
+// PageExtractor is an Extractor of paged documents that can read some of
+// their pages only
+type PageExtractor interface {
+	Extractor
+	ExtractPages(path string, first, last int) (*FileAttachment, error) // last = 0: to the end
+}
+
+// Format is a kind of file termai can attach, and how to read it
+type Format struct {
+	Name       string   // referred to by files.extensions in the config
+	Type       string   // type of the attachments produced
+	Extensions []string // lowercase, with the dot
+	MIMETypes  []string // sniffed from the content of files whose extension is unknown
+	Binary     bool     // not a text format: files are not sniffed for binary content
+	Extractor  Extractor
+}
+
+var (
+	formats       = map[string]*Format{} // by name
+	formatsByExt  = map[string]*Format{}
+	formatsByMIME = map[string]*Format{}
+)
+
+// RegisterFormat makes a format available, for its extensions and sniffed
+// MIME types. A later format takes over the extensions of an earlier one.
+func RegisterFormat(f Format) {
+	format := &f
+	formats[f.Name] = format
+	for _, ext := range f.Extensions {
+		formatsByExt[ext] = format
+	}
+	for _, mimeType := range f.MIMETypes {
+		formatsByMIME[mimeType] = format
+	}
+}
+
+func init() {
+	RegisterFormat(Format{
+		Name:       "image",
+		Type:       "image",
+		Extensions: []string{".jpg", ".jpeg", ".png", ".gif", ".webp", ".bmp", ".tif", ".tiff", ".heic", ".heif"},
+		MIMETypes:  []string{"image/jpeg", "image/png", "image/gif", "image/webp", "image/bmp"},
+		Binary:     true,
+		Extractor:  ExtractorFunc(processImage),
+	})
+	RegisterFormat(Format{
+		Name:       "pdf",
+		Type:       "pdf",
+		Extensions: []string{".pdf"},
+		MIMETypes:  []string{"application/pdf"},
+		Binary:     true,
+		Extractor:  pdfExtractor{},
+	})
+	RegisterFormat(Format{
+		Name:       "text",
+		Type:       "text",
+		Extensions: []string{".txt", ".md", ".markdown"},
+		Extractor:  textExtractor("text"),
+	})
+	RegisterFormat(Format{
+		Name: "code",
+		Type: "code",
+		Extensions: []string{
+			".go", ".py", ".js", ".ts", ".tsx", ".jsx", ".java", ".c", ".cpp", ".cc", ".h", ".hpp",
+			".rs", ".rb", ".php", ".sh", ".bash", ".yaml", ".yml", ".json", ".xml", ".html", ".css", ".sql",
+		},
+		Extractor: textExtractor("code"),
+	})
+	RegisterFormat(Format{
+		Name:       "docx",
+		Type:       "document",
+		Extensions: []string{".docx"},
+		Binary:     true,
+		Extractor:  officeExtractor("document", extractDocx),
+	})
+	RegisterFormat(Format{
+		Name:       "xlsx",
+		Type:       "spreadsheet",
+		Extensions: []string{".xlsx"},
+		Binary:     true,
+		Extractor:  officeExtractor("spreadsheet", extractXlsx),
+	})
+	RegisterFormat(Format{
+		Name:       "pptx",
+		Type:       "slides",
+		Extensions: []string{".pptx"},
+		Binary:     true,
+		Extractor:  officeExtractor("slides", extractPptx),
+	})
+	RegisterFormat(Format{
+		Name:       "csv",
+		Type:       "spreadsheet",
+		Extensions: []string{".csv"},
+		Extractor:  documentExtractor("spreadsheet", func(data []byte) (string, error) { return extractCSV(data, ',') }),
+	})
+	RegisterFormat(Format{
+		Name:       "tsv",
+		Type:       "spreadsheet",
+		Extensions: []string{".tsv"},
+		Extractor:  documentExtractor("spreadsheet", func(data []byte) (string, error) { return extractCSV(data, '\t') }),
+	})
+	RegisterFormat(Format{
+		Name:       "ipynb",
+		Type:       "notebook",
+		Extensions: []string{".ipynb"},
+		Extractor:  documentExtractor("notebook", extractNotebook),
+	})
+}
+
+// FormatOf returns the format a file is read as: the one files.extensions
+// maps its extension to, else the one registered for its extension, else the
+// one of the MIME type sniffed from its content, else code or text if its
+// content is text (code when its language is known from its name or shebang).
+// Binary files of no known format get a *BinaryError telling what they are.
+func FormatOf(path string) (*Format, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if format, err := configuredFormat(ext); format != nil || err != nil {
+		return format, err
+	}
+	if format := formatsByExt[ext]; format != nil {
+		return format, nil
+	}
+
+	head, err := readHead(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if format := formatsByMIME[sniffMIME(head)]; format != nil {
+		return format, nil
+	}
+
+	// Text of an unknown extension (or none: Makefile, scripts, .env.example)
+	_, binaryKind := textEncoding(head)
+	if binaryKind != "" {
+		return nil, &BinaryError{Name: filepath.Base(path), Kind: binaryKind}
+	}
+	if DetectLanguage(path, string(head)) != "" {
+		return formats["code"], nil
+	}
+	return formats["text"], nil
+}
+
+// configuredFormat returns the format files.extensions maps an extension
+// to, nil if it maps it to none
+func configuredFormat(ext string) (*Format, error) {
+	if ext == "" || config.AppConfig == nil {
+		return nil, nil
+	}
+	for mapped, name := range config.AppConfig.Files.Extensions {
+		if NormalizeExtension(mapped) != ext {
+			continue
+		}
+		if format := formats[name]; format != nil {
+			return format, nil
+		}
+		return nil, fmt.Errorf("files.extensions maps %s to unknown format %q (one of %s)", ext, name, strings.Join(FormatNames(), ", "))
+	}
+	return nil, nil
+}
+
+// NormalizeExtension returns an extension lowercase, with its dot ("TF" gives ".tf")
+func NormalizeExtension(ext string) string {
+	return "." + strings.TrimPrefix(strings.ToLower(strings.TrimSpace(ext)), ".")
+}
+
+// FormatNames returns the names of the registered formats, sorted
+func FormatNames() []string {
+	return slices.Sorted(maps.Keys(formats))
+}
+
+// readHead reads the start of a file, enough to sniff its content
+func readHead(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	head := make([]byte, sniffSize)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// sniffMIME returns the MIME type of content, without parameters
+func sniffMIME(data []byte) string {
+	mimeType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	return mimeType
+}
+
+// GetSupportedExtensions returns all supported file extensions, the ones
+// mapped in files.extensions included
+func GetSupportedExtensions() []string {
+	extensions := slices.Collect(maps.Keys(formatsByExt))
+	if config.AppConfig != nil {
+		for ext, name := range config.AppConfig.Files.Extensions {
+			if ext = NormalizeExtension(ext); formatsByExt[ext] == nil && formats[name] != nil {
+				extensions = append(extensions, ext)
+			}
+		}
+	}
+	slices.Sort(extensions)
+	return extensions
+}
+
+// IsSupported checks if a file can be attached, by its extension or content
+func IsSupported(path string) bool {
+	_, err := FormatOf(path)
+	return err == nil
+}
+
+// isBinaryFormat reports whether a file is of a binary format (image, PDF,
+// Office document) by its extension, so not to be sniffed as text
+func isBinaryFormat(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	if format, _ := configuredFormat(ext); format != nil {
+		return format.Binary
+	}
+	format := formatsByExt[ext]
+	return format != nil && format.Binary
+}
+
+// Icon returns the icon shown for an attachment of this type
+func (f *FileAttachment) Icon() string {
+	switch f.Type {
+	case "image":
+		return "📷"
+	case "pdf":
+		return "📄"
+	case "code":
+		return "💻"
+	case "text":
+		return "📝"
+	case "document":
+		return "📃"
+	case "spreadsheet":
+		return "📊"
+	case "slides":
+		return "📽"
+	case "notebook":
+		return "📓"
+	}
+	return "📎"
+}
@@ -1,6 +1,8 @@
 package fileprocessor
 
 import (
+	"archive/zip"
+	"bytes"
 	"encoding/base64"
 	"fmt"
 	"mime"
@@ -8,68 +10,60 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/ledongthuc/pdf"
+	"github.com/KooQix/term-ai/internal/config"
+	"github.com/KooQix/term-ai/internal/imageproc"
+	"github.com/KooQix/term-ai/internal/provider"
 )
 
 // FileAttachment represents a processed file attachment
 type FileAttachment struct {
-	Path     string // Original file path
-	Type     string // "image", "text", "pdf"
+	Path     string // Original file path, with its selector if any (main.go:40-120)
+	Type     string // "image", "text", "code", "pdf", "document", "spreadsheet", "slides" or "notebook"
 	Content  string // base64 for images, text for others
 	MimeType string // MIME type for images
 	Name     string // filename
+	Language string // language of code, for its fence ("" = none)
+
+	PageImages []provider.PageImage // images of the pages of a PDF without text (scans)
 }
 
-// Supported file extensions
-var (
-	imageExtensions = map[string]bool{
-		".jpg":  true,
-		".jpeg": true,
-		".png":  true,
-		".gif":  true,
-		".webp": true,
-	}
+// Attachment converts the file into a message attachment
+func (f *FileAttachment) Attachment() provider.Attachment {
+	return provider.Attachment{
+		Name:     f.Name,
+		Path:     f.Path,
+		Type:     f.Type,
+		MimeType: f.MimeType,
+		Content:  f.Content,
+		Language: f.Language,
 
-	textExtensions = map[string]bool{
-		".txt":      true,
-		".md":       true,
-		".markdown": true,
+		PageImages: f.PageImages,
 	}
+}
+
+// FromAttachment turns a message attachment back into a file attachment
+func FromAttachment(a provider.Attachment) *FileAttachment {
+	return &FileAttachment{
+		Path:     a.Path,
+		Type:     a.Type,
+		Content:  a.Content,
+		MimeType: a.MimeType,
+		Name:     a.Name,
+		Language: a.Language,
 
-	codeExtensions = map[string]bool{
-		".go":   true,
-		".py":   true,
-		".js":   true,
-		".ts":   true,
-		".tsx":  true,
-		".jsx":  true,
-		".java": true,
-		".c":    true,
-		".cpp":  true,
-		".cc":   true,
-		".h":    true,
-		".hpp":  true,
-		".rs":   true,
-		".rb":   true,
-		".php":  true,
-		".sh":   true,
-		".bash": true,
-		".yaml": true,
-		".yml":  true,
-		".json": true,
-		".xml":  true,
-		".html": true,
-		".css":  true,
-		".sql":  true,
+		PageImages: a.PageImages,
 	}
+}
 
-	pdfExtensions = map[string]bool{
-		".pdf": true,
+// ProcessFile processes a single file and returns a FileAttachment, read by
+// the extractor of its format (see FormatOf). The path may end with a
+// selector attaching part of the file only (see ParseSelector).
+func ProcessFile(arg string) (*FileAttachment, error) {
+	path, sel, err := ParseSelector(arg)
+	if err != nil {
+		return nil, err
 	}
-)
 
-// ProcessFile processes a single file and returns a FileAttachment
-func ProcessFile(path string) (*FileAttachment, error) {
 	// Check if file exists
 	info, err := os.Stat(path)
 	if err != nil {
@@ -84,27 +78,32 @@ func ProcessFile(path string) (*FileAttachment, error) {
 		return nil, fmt.Errorf("%s is a directory, not a file", path)
 	}
 
-	// Get file extension
-	ext := strings.ToLower(filepath.Ext(path))
-	filename := filepath.Base(path)
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
 
-	// Determine file type and process accordingly
 	var attachment *FileAttachment
-
-	if imageExtensions[ext] {
-		attachment, err = processImage(path, filename)
-	} else if pdfExtensions[ext] {
-		attachment, err = processPDF(path, filename)
-	} else if textExtensions[ext] || codeExtensions[ext] {
-		attachment, err = processText(path, filename)
+	if sel != nil && sel.FirstPage > 0 {
+		// Pages are selected while extracting, not to read the others
+		pager, ok := format.Extractor.(PageExtractor)
+		if !ok {
+			return nil, fmt.Errorf("%s: page selectors apply to PDF files", filepath.Base(path))
+		}
+		attachment, err = pager.ExtractPages(path, sel.FirstPage, sel.LastPage)
 	} else {
-		return nil, fmt.Errorf("unsupported file type: %s", ext)
+		attachment, err = format.Extractor.Extract(path)
 	}
-
-	if err != nil {
-		return nil, err
+	if err != nil || sel == nil {
+		return attachment, err
 	}
 
+	if sel.FirstPage == 0 {
+		if err := sel.apply(attachment); err != nil {
+			return nil, err
+		}
+	}
+	attachment.Path = arg // a different selection of the file is another attachment
 	return attachment, nil
 }
 
@@ -139,31 +138,31 @@ func ProcessFiles(paths []string) ([]*FileAttachment, error) {
 	return attachments, nil
 }
 
-// processImage reads an image file and encodes it as base64
-func processImage(path, filename string) (*FileAttachment, error) {
+// imageOptions returns how attached images are prepared, as configured
+func imageOptions() imageproc.Options {
+	if config.AppConfig == nil {
+		return imageproc.Options{MaxDimension: 2048, Quality: imageproc.DefaultQuality}
+	}
+	return imageproc.Options{
+		MaxDimension: config.AppConfig.Files.ImageMaxDimension,
+		Quality:      config.AppConfig.Files.ImageQuality,
+	}
+}
+
+// processImage reads an image file and encodes it as base64, scaled down,
+// converted and stripped of its metadata as configured (files.image_*)
+func processImage(path string) (*FileAttachment, error) {
+	filename := filepath.Base(path)
+
 	// Read the image file
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read image file: %w", err)
 	}
 
-	// Detect MIME type
-	ext := strings.ToLower(filepath.Ext(path))
-	mimeType := mime.TypeByExtension(ext)
-	if mimeType == "" {
-		// Fallback MIME types
-		switch ext {
-		case ".jpg", ".jpeg":
-			mimeType = "image/jpeg"
-		case ".png":
-			mimeType = "image/png"
-		case ".gif":
-			mimeType = "image/gif"
-		case ".webp":
-			mimeType = "image/webp"
-		default:
-			mimeType = "application/octet-stream"
-		}
+	data, mimeType, err := imageproc.Prepare(data, imageOptions())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
 	}
 
 	// Encode to base64
@@ -181,98 +180,69 @@ func processImage(path, filename string) (*FileAttachment, error) {
 	}, nil
 }
 
-// processPDF extracts text content from a PDF file
-func processPDF(path, filename string) (*FileAttachment, error) {
-	// Open the PDF file
-	f, r, err := pdf.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open PDF file: %w", err)
-	}
-	defer f.Close()
-
-	// Extract text from all pages
-	var textBuilder strings.Builder
-	totalPages := r.NumPage()
-
-	for pageNum := 1; pageNum <= totalPages; pageNum++ {
-		p := r.Page(pageNum)
-		if p.V.IsNull() {
-			continue
-		}
-
-		text, err := p.GetPlainText(nil)
+// officeExtractor reads the text of a zipped Office document (Word, Excel,
+// PowerPoint) as markdown, into an attachment of fileType
+func officeExtractor(fileType string, extract func(*zip.Reader) (string, error)) Extractor {
+	return documentExtractor(fileType, func(data []byte) (string, error) {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
 		if err != nil {
-			// Continue with other pages even if one fails
-			fmt.Fprintf(os.Stderr, "Warning: failed to extract text from page %d of %s: %v\n", pageNum, filename, err)
-			continue
+			return "", fmt.Errorf("not a valid Office file: %w", err)
 		}
-
-		textBuilder.WriteString(text)
-		textBuilder.WriteString("\n")
-	}
-
-	extractedText := strings.TrimSpace(textBuilder.String())
-	if extractedText == "" {
-		return nil, fmt.Errorf("no text content could be extracted from PDF")
-	}
-
-	return &FileAttachment{
-		Path:     path,
-		Type:     "pdf",
-		Content:  extractedText,
-		MimeType: "application/pdf",
-		Name:     filename,
-	}, nil
+		return extract(zr)
+	})
 }
 
-// processText reads a text or code file
-func processText(path, filename string) (*FileAttachment, error) {
-	// Read the file
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read text file: %w", err)
-	}
-
-	content := string(data)
+// documentExtractor reads the text of a document (CSV, notebook, ...) as
+// markdown, into an attachment of fileType
+func documentExtractor(fileType string, extract func([]byte) (string, error)) Extractor {
+	return ExtractorFunc(func(path string) (*FileAttachment, error) {
+		filename := filepath.Base(path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
 
-	// Determine if it's a code file or text file
-	ext := strings.ToLower(filepath.Ext(path))
-	fileType := "text"
-	if codeExtensions[ext] {
-		fileType = "code"
-	}
+		content, err := extract(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract text from %s: %w", filename, err)
+		}
+		if strings.TrimSpace(content) == "" {
+			return nil, fmt.Errorf("no text content could be extracted from %s", filename)
+		}
 
-	return &FileAttachment{
-		Path:     path,
-		Type:     fileType,
-		Content:  content,
-		MimeType: mime.TypeByExtension(ext),
-		Name:     filename,
-	}, nil
+		return &FileAttachment{
+			Path:     path,
+			Type:     fileType,
+			Content:  content,
+			MimeType: mime.TypeByExtension(strings.ToLower(filepath.Ext(path))),
+			Name:     filename,
+		}, nil
+	})
 }
 
-// GetSupportedExtensions returns all supported file extensions
-func GetSupportedExtensions() []string {
-	extensions := make([]string, 0)
-
-	for ext := range imageExtensions {
-		extensions = append(extensions, ext)
-	}
-	for ext := range pdfExtensions {
-		extensions = append(extensions, ext)
-	}
-	for ext := range textExtensions {
-		extensions = append(extensions, ext)
-	}
-	for ext := range codeExtensions {
-		extensions = append(extensions, ext)
-	}
-
-	return extensions
-}
+// textExtractor reads a text or code file into an attachment of fileType,
+// converted to UTF-8, code with its language
+func textExtractor(fileType string) Extractor {
+	return ExtractorFunc(func(path string) (*FileAttachment, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read text file: %w", err)
+		}
+		content, err := readText(filepath.Base(path), data)
+		if err != nil {
+			return nil, err
+		}
 
-// IsSupported checks if a file extension is supported
-func IsSupported(path string) bool {
-	ext := strings.ToLower(filepath.Ext(path))
-	return imageExtensions[ext] || pdfExtensions[ext] || textExtensions[ext] || codeExtensions[ext]
+		attachment := &FileAttachment{
+			Path:     path,
+			Type:     fileType,
+			Content:  content,
+			MimeType: mime.TypeByExtension(strings.ToLower(filepath.Ext(path))),
+			Name:     filepath.Base(path),
+		}
+		if fileType == "code" {
+			attachment.Language = DetectLanguage(path, content)
+		}
+		return attachment, nil
+	})
 }
@@ -0,0 +1,179 @@
+package fileprocessor
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Ignore files read in every scanned directory, in this order: a rule of
+// .termaiignore (same syntax as .gitignore) can override one of .gitignore
+var ignoreFiles = []string{".gitignore", ".termaiignore"}
+
+// ignoreRule is one pattern line of an ignore file
+type ignoreRule struct {
+	re      *regexp.Regexp // matches paths relative to the directory holding the ignore file
+	negate  bool           // "!pattern" re-includes what an earlier rule ignored
+	dirOnly bool           // "pattern/" only matches directories
+	source  string         // ignore file name, for the skip report
+}
+
+// ignoreMatcher holds the rules of the ignore files found while scanning,
+// by the directory (relative to the scan root, slash-separated) holding them
+type ignoreMatcher struct {
+	rules map[string][]ignoreRule
+}
+
+func newIgnoreMatcher() *ignoreMatcher {
+	return &ignoreMatcher{rules: map[string][]ignoreRule{}}
+}
+
+// load reads the ignore files of a directory, rel being its path from the scan root
+func (im *ignoreMatcher) load(dir, rel string) {
+	for _, name := range ignoreFiles {
+		file, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+				rule.source = name
+				im.rules[rel] = append(im.rules[rel], rule)
+			}
+		}
+		file.Close()
+	}
+}
+
+// match reports whether the path (relative to the scan root, slash-separated)
+// is ignored, and by which ignore file. Rules of deeper directories come
+// last, and the last matching rule wins, as with git.
+func (im *ignoreMatcher) match(rel string, isDir bool) (bool, string) {
+	ignored, source := false, ""
+
+	dirs := []string{"."}
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		dirs = append(dirs, dir)
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		base := dirs[i]
+		sub := rel
+		if base != "." {
+			sub = strings.TrimPrefix(rel, base+"/")
+		}
+
+		for _, rule := range im.rules[base] {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if rule.re.MatchString(sub) {
+				ignored, source = !rule.negate, rule.source
+			}
+		}
+	}
+	return ignored, source
+}
+
+// parseIgnoreRule parses a line of an ignore file, ok is false for blank
+// lines and comments
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:] // "\#file" or "\!file"
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// A pattern with a slash (other than a trailing one) is relative to the
+	// directory of the ignore file, otherwise it matches at any depth
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		line = "**/" + line
+	}
+
+	re, err := globRegexp(line)
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// matchGlob reports whether a slash-separated relative path matches a glob.
+// "*" and "?" stop at slashes, "**" spans directories ("**/*.go" matches Go
+// files at any depth, the top level included). A glob without a slash is
+// matched against the file name.
+func matchGlob(pattern, rel string) bool {
+	if !strings.Contains(pattern, "/") {
+		rel = path.Base(rel)
+	}
+	re, err := globRegexp(pattern)
+	return err == nil && re.MatchString(rel)
+}
+
+// globRegexp compiles a glob into an anchored regular expression
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '*' && strings.HasPrefix(pattern[i:], "**"):
+			atStart := i == 0 || pattern[i-1] == '/'
+			rest := pattern[i+2:]
+			if atStart && strings.HasPrefix(rest, "/") {
+				sb.WriteString("(?:.*/)?") // "**/": any number of directories, none included
+				i += 2
+			} else {
+				sb.WriteString(".*")
+				i++
+			}
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+
+	// A directory pattern also matches everything below it
+	sb.WriteString("(?:/.*)?$")
+	return regexp.Compile(sb.String())
+}
@@ -0,0 +1,151 @@
+package fileprocessor
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+/// Jupyter notebooks (.ipynb), rendered as their cells with outputs summarized
+
+// Limits of the outputs shown per code cell
+const (
+	maxOutputLines = 20
+	maxOutputBytes = 2000
+)
+
+// notebookText is a string or a list of lines, as found in notebooks
+type notebookText string
+
+func (t *notebookText) UnmarshalJSON(data []byte) error {
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err == nil {
+		*t = notebookText(strings.Join(lines, ""))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*t = notebookText(s)
+	return nil
+}
+
+type notebook struct {
+	Metadata struct {
+		Kernelspec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+	Cells []struct {
+		CellType string       `json:"cell_type"`
+		Source   notebookText `json:"source"`
+		Outputs  []struct {
+			OutputType string                  `json:"output_type"`
+			Text       notebookText            `json:"text"`
+			Data       map[string]notebookText `json:"data"`
+			Ename      string                  `json:"ename"`
+			Evalue     string                  `json:"evalue"`
+		} `json:"outputs"`
+	} `json:"cells"`
+}
+
+// extractNotebook renders the cells of a notebook: markdown as is, code in
+// fences, followed by its outputs (text cut, images and rich outputs named)
+func extractNotebook(data []byte) (string, error) {
+	var nb notebook
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return "", fmt.Errorf("invalid notebook: %w", err)
+	}
+
+	language := nb.Metadata.LanguageInfo.Name
+	if language == "" {
+		language = nb.Metadata.Kernelspec.Language
+	}
+
+	var out strings.Builder
+	code := 0
+	for _, cell := range nb.Cells {
+		source := strings.TrimSpace(string(cell.Source))
+		switch cell.CellType {
+		case "markdown":
+			if source != "" {
+				out.WriteString(source + "\n\n")
+			}
+			continue
+		case "code":
+			code++
+			fmt.Fprintf(&out, "In [%d]:\n```%s\n%s\n```\n", code, language, source)
+		default: // raw
+			fmt.Fprintf(&out, "```\n%s\n```\n", source)
+			continue
+		}
+
+		for _, output := range cell.Outputs {
+			switch output.OutputType {
+			case "stream":
+				out.WriteString(outputBlock(string(output.Text)))
+			case "error":
+				fmt.Fprintf(&out, "Error: %s: %s\n", output.Ename, output.Evalue)
+			case "execute_result", "display_data":
+				out.WriteString(richOutput(output.Data))
+			}
+		}
+		out.WriteString("\n")
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// richOutput summarizes the data of a result: its text if it has some, the
+// kinds of data it holds otherwise (images, HTML, ...)
+func richOutput(data map[string]notebookText) string {
+	if text, ok := data["text/plain"]; ok && !hasImage(data) {
+		return outputBlock(string(text))
+	}
+
+	kinds := make([]string, 0, len(data))
+	for kind := range data {
+		kinds = append(kinds, kind)
+	}
+	slices.Sort(kinds)
+	summary := "[output: " + strings.Join(kinds, ", ") + "]"
+	if text, ok := data["text/plain"]; ok {
+		summary += " " + strings.TrimSpace(string(text))
+	}
+	return summary + "\n"
+}
+
+func hasImage(data map[string]notebookText) bool {
+	for kind := range data {
+		if strings.HasPrefix(kind, "image/") {
+			return true
+		}
+	}
+	return false
+}
+
+// outputBlock fences an output, cut to maxOutputLines and maxOutputBytes
+func outputBlock(text string) string {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return ""
+	}
+
+	lines := strings.Split(text, "\n")
+	cut := false
+	if len(lines) > maxOutputLines {
+		lines, cut = lines[:maxOutputLines], true
+	}
+	text = strings.Join(lines, "\n")
+	if len(text) > maxOutputBytes {
+		text, cut = strings.ToValidUTF8(text[:maxOutputBytes], ""), true
+	}
+	if cut {
+		text += "\n…"
+	}
+	return "Out:\n```\n" + text + "\n```\n"
+}
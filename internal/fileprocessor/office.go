@@ -0,0 +1,283 @@
+package fileprocessor
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+/// Word documents (.docx) and PowerPoint presentations (.pptx): zipped Office
+/// Open XML, read with archive/zip and encoding/xml
+
+// maxOfficePartSize caps the size of an XML part read from an Office file,
+// against zip bombs
+const maxOfficePartSize = 64 * 1024 * 1024
+
+// officePart reads a part (file) of an Office zip
+func officePart(zr *zip.Reader, name string) ([]byte, error) {
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(io.LimitReader(rc, maxOfficePartSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxOfficePartSize {
+			return nil, fmt.Errorf("%s is larger than %s", name, FormatSize(maxOfficePartSize))
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("%s not found, not an Office file?", name)
+}
+
+// officeRelations maps the relationship ids of a part to the parts they
+// target (paths inside the zip), from its _rels/<part>.rels file
+func officeRelations(zr *zip.Reader, part string) (map[string]string, error) {
+	dir, file := path.Split(part)
+	data, err := officePart(zr, dir+"_rels/"+file+".rels")
+	if err != nil {
+		return nil, err
+	}
+
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := xml.Unmarshal(data, &rels); err != nil {
+		return nil, err
+	}
+
+	targets := make(map[string]string, len(rels.Relationships))
+	for _, rel := range rels.Relationships {
+		if strings.HasPrefix(rel.Target, "/") {
+			targets[rel.ID] = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			targets[rel.ID] = path.Join(dir, rel.Target)
+		}
+	}
+	return targets, nil
+}
+
+// attr returns the value of an attribute of an element, whatever its namespace
+func attr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// extractDocx renders the body of a Word document as markdown: headings,
+// list items, paragraphs and tables
+func extractDocx(zr *zip.Reader) (string, error) {
+	data, err := officePart(zr, "word/document.xml")
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		out       strings.Builder
+		paragraph strings.Builder
+		prefix    string     // heading or list marker of the current paragraph
+		table     [][]string // rows of the table being read
+		cell      []string   // paragraphs of the cell being read
+		depth     int        // nesting of tables, nested ones are flattened into their cell
+	)
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("invalid document.xml: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				paragraph.Reset()
+				prefix = ""
+			case "pStyle":
+				prefix = headingPrefix(attr(t, "val"))
+			case "numPr":
+				if prefix == "" {
+					prefix = "- "
+				}
+			case "tab":
+				paragraph.WriteString("\t")
+			case "br", "cr":
+				paragraph.WriteString(" ")
+			case "tbl":
+				depth++
+				if depth == 1 {
+					table = nil
+				}
+			case "tr":
+				if depth == 1 {
+					table = append(table, nil)
+				}
+			case "tc":
+				if depth == 1 {
+					cell = nil
+				}
+			case "t":
+				var text string
+				if err := decoder.DecodeElement(&text, &t); err != nil {
+					return "", fmt.Errorf("invalid document.xml: %w", err)
+				}
+				paragraph.WriteString(text)
+			}
+
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "p":
+				text := strings.TrimSpace(paragraph.String())
+				switch {
+				case text == "":
+				case depth > 0:
+					cell = append(cell, text)
+				default:
+					out.WriteString(prefix + text + "\n\n")
+				}
+			case "tc":
+				if depth == 1 && len(table) > 0 {
+					table[len(table)-1] = append(table[len(table)-1], strings.Join(cell, " "))
+				}
+			case "tbl":
+				depth--
+				if depth == 0 {
+					out.WriteString(markdownTable(table, 0) + "\n")
+				}
+			}
+		}
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// headingPrefix returns the markdown prefix of a paragraph style: "# " for
+// Title and Heading1, "## " for Heading2, ... nothing for other styles
+func headingPrefix(style string) string {
+	if style == "Title" {
+		return "# "
+	}
+	if level, err := strconv.Atoi(strings.TrimPrefix(style, "Heading")); err == nil && strings.HasPrefix(style, "Heading") {
+		return strings.Repeat("#", min(max(level, 1), 6)) + " "
+	}
+	return ""
+}
+
+// extractPptx renders the slides of a presentation in order, each with its
+// title and the text of its other shapes as a list
+func extractPptx(zr *zip.Reader) (string, error) {
+	data, err := officePart(zr, "ppt/presentation.xml")
+	if err != nil {
+		return "", err
+	}
+	var presentation struct {
+		Slides []struct {
+			RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sldIdLst>sldId"`
+	}
+	if err := xml.Unmarshal(data, &presentation); err != nil {
+		return "", fmt.Errorf("invalid presentation.xml: %w", err)
+	}
+	rels, err := officeRelations(zr, "ppt/presentation.xml")
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	for i, slide := range presentation.Slides {
+		data, err := officePart(zr, rels[slide.RelID])
+		if err != nil {
+			return "", err
+		}
+		title, lines, err := slideText(data)
+		if err != nil {
+			return "", fmt.Errorf("invalid slide %d: %w", i+1, err)
+		}
+
+		fmt.Fprintf(&out, "## Slide %d", i+1)
+		if title != "" {
+			out.WriteString(": " + title)
+		}
+		out.WriteString("\n\n")
+		for _, line := range lines {
+			out.WriteString("- " + line + "\n")
+		}
+		if len(lines) > 0 {
+			out.WriteString("\n")
+		}
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// slideText returns the title of a slide and the paragraphs of its other shapes
+func slideText(data []byte) (string, []string, error) {
+	var (
+		title     string
+		lines     []string
+		paragraph strings.Builder
+		isTitle   bool // the current shape is the title placeholder
+	)
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "sp":
+				isTitle = false
+			case "ph":
+				kind := attr(t, "type")
+				isTitle = kind == "title" || kind == "ctrTitle"
+			case "p":
+				paragraph.Reset()
+			case "br":
+				paragraph.WriteString(" ")
+			case "t":
+				var text string
+				if err := decoder.DecodeElement(&text, &t); err != nil {
+					return "", nil, err
+				}
+				paragraph.WriteString(text)
+			}
+
+		case xml.EndElement:
+			if t.Name.Local != "p" {
+				continue
+			}
+			text := strings.TrimSpace(paragraph.String())
+			switch {
+			case text == "":
+			case isTitle:
+				title = strings.TrimSpace(title + " " + text)
+			default:
+				lines = append(lines, text)
+			}
+		}
+	}
+	return title, lines, nil
+}
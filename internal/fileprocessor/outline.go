@@ -0,0 +1,332 @@
+package fileprocessor
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+//////////////////// Project outline \\\\\\\\\\\\\\\\\\\\
+
+// A project outline stands in for the content of a directory: its tree and
+// the declarations of each source file (package, types, function signatures)
+// with their line numbers, so the model knows where things are and reads the
+// files it needs through the read_file tool.
+
+const (
+	maxOutlineLine  = 160 // characters of a declaration kept in an outline
+	maxOutlineNames = 8   // names listed for a group of constants, variables or struct fields
+)
+
+// ProjectOutline is the outline of a directory built by BuildOutline
+type ProjectOutline struct {
+	Dir      string
+	Content  string        // tree and outlines, as sent to the model
+	Files    int           // files in the tree
+	Outlined int           // files whose outline is included
+	Skipped  []SkippedFile // paths left out of the tree, and outlines left out for the size budget
+}
+
+// BuildOutline renders the tree of a directory and the outline of its source
+// files, scanned with the same rules as ScanDirectory (every file type shows
+// in the tree). Outlines are included shallower files first until
+// opts.MaxTotalSize; they are taken from the cache when the file is unchanged.
+func BuildOutline(dirPath string, opts ScanOptions, cache *OutlineCache) (*ProjectOutline, error) {
+	candidates, skipped, err := collectFiles(dirPath, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no files found in directory")
+	}
+
+	if abs, err := filepath.Abs(dirPath); err == nil {
+		dirPath = abs
+	}
+	outline := &ProjectOutline{Dir: dirPath, Files: len(candidates), Skipped: skipped}
+
+	rels := make([]string, 0, len(candidates))
+	outlines := map[string]string{}
+	size := 0
+	for _, c := range candidates {
+		rels = append(rels, c.rel)
+
+		text := cache.get(c.path)
+		if text == "" {
+			continue
+		}
+		if opts.MaxTotalSize > 0 && int64(size+len(text)) > opts.MaxTotalSize {
+			outline.Skipped = append(outline.Skipped, SkippedFile{Path: c.rel, Reason: SkipBudget, Detail: "outline"})
+			continue
+		}
+		outlines[c.rel] = text
+		size += len(text)
+	}
+	outline.Outlined = len(outlines)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Outline of the project in %s: its file tree, then the declarations of its source files with their line numbers (L<n>). ", filepath.Base(dirPath))
+	sb.WriteString("Files are not included: read the ones you need with the read_file tool (paths relative to the project root, optionally a range of lines).\n\n")
+	sb.WriteString("## Tree\n\n")
+	sb.WriteString(renderTree(rels))
+
+	if len(outlines) > 0 {
+		sb.WriteString("\n## Outlines\n")
+		for _, rel := range sortedPaths(rels) {
+			if text, ok := outlines[rel]; ok {
+				sb.WriteString("\n### " + rel + "\n" + text)
+			}
+		}
+	}
+
+	outline.Content = sb.String()
+	return outline, nil
+}
+
+// Attachment returns the outline as a context file
+func (o *ProjectOutline) Attachment() *FileAttachment {
+	return &FileAttachment{
+		Path:     o.Dir,
+		Type:     "text",
+		Content:  o.Content,
+		MimeType: "text/markdown",
+		Name:     "project outline (" + filepath.Base(o.Dir) + ")",
+	}
+}
+
+// Report summarizes what was left out of the outline, see ScanResult.Report
+func (o *ProjectOutline) Report(examples int) string {
+	return (&ScanResult{Skipped: o.Skipped}).Report(examples)
+}
+
+// renderTree renders relative file paths as an indented tree, directories first
+func renderTree(rels []string) string {
+	var sb strings.Builder
+	printed := map[string]bool{}
+	for _, rel := range sortedPaths(rels) {
+		parts := strings.Split(rel, "/")
+		for i := range len(parts) - 1 {
+			dir := strings.Join(parts[:i+1], "/")
+			if !printed[dir] {
+				printed[dir] = true
+				sb.WriteString(strings.Repeat("  ", i) + parts[i] + "/\n")
+			}
+		}
+		sb.WriteString(strings.Repeat("  ", len(parts)-1) + parts[len(parts)-1] + "\n")
+	}
+	return sb.String()
+}
+
+// sortedPaths orders paths as a tree listing does: within a directory, its
+// files before its subdirectories, each by name
+func sortedPaths(rels []string) []string {
+	key := func(rel string) string {
+		dir, file := path.Split(rel)
+		// "\x00" sorts a directory's files before the deeper paths sharing its prefix
+		return dir + "\x00" + file
+	}
+	sorted := slices.Clone(rels)
+	slices.SortFunc(sorted, func(a, b string) int { return strings.Compare(key(a), key(b)) })
+	return sorted
+}
+
+// fileOutline extracts the declarations of a source file, "" when it has none
+// or isn't source code
+func fileOutline(filePath string) string {
+	raw, err := os.ReadFile(filePath)
+	if err != nil {
+		return ""
+	}
+	text, err := readText(filepath.Base(filePath), raw)
+	if err != nil {
+		return ""
+	}
+	data := []byte(text)
+
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if ext == ".go" {
+		if outline, ok := goOutline(filePath, data); ok {
+			return outline
+		}
+	}
+
+	patterns, ok := outlinePatterns[ext]
+	if !ok {
+		return ""
+	}
+
+	var sb strings.Builder
+	markdown, inFence := ext == ".md" || ext == ".markdown", false
+	for i, line := range strings.Split(string(data), "\n") {
+		// Code blocks of documents hold no headings ("# comment")
+		if trimmed := strings.TrimSpace(line); markdown && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")) {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+
+		for _, re := range patterns {
+			if re.MatchString(line) {
+				writeOutlineLine(&sb, i+1, strings.TrimRight(strings.TrimSpace(line), " {:"))
+				break
+			}
+		}
+	}
+	return sb.String()
+}
+
+// goOutline outlines a Go file from its syntax tree
+func goOutline(filePath string, src []byte) (string, bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filePath, src, parser.SkipObjectResolution)
+	if err != nil {
+		return "", false
+	}
+
+	var sb strings.Builder
+	writeOutlineLine(&sb, fset.Position(file.Package).Line, "package "+file.Name.Name)
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			signature := *d
+			signature.Doc, signature.Body = nil, nil
+			var buf bytes.Buffer
+			if err := printer.Fprint(&buf, fset, &signature); err == nil {
+				writeOutlineLine(&sb, fset.Position(d.Pos()).Line, strings.Join(strings.Fields(buf.String()), " "))
+			}
+
+		case *ast.GenDecl:
+			switch d.Tok {
+			case token.TYPE:
+				for _, spec := range d.Specs {
+					ts := spec.(*ast.TypeSpec)
+					writeOutlineLine(&sb, fset.Position(ts.Pos()).Line, "type "+ts.Name.Name+" "+goTypeSummary(fset, ts.Type))
+				}
+			case token.CONST, token.VAR:
+				var names []string
+				for _, spec := range d.Specs {
+					for _, name := range spec.(*ast.ValueSpec).Names {
+						if name.Name != "_" {
+							names = append(names, name.Name)
+						}
+					}
+				}
+				if len(names) > 0 {
+					writeOutlineLine(&sb, fset.Position(d.Pos()).Line, d.Tok.String()+" "+joinNames(names))
+				}
+			}
+		}
+	}
+	return sb.String(), true
+}
+
+// goTypeSummary describes a type declaration: the fields of a struct, the
+// methods of an interface, the underlying type otherwise
+func goTypeSummary(fset *token.FileSet, expr ast.Expr) string {
+	var names []string
+	switch t := expr.(type) {
+	case *ast.StructType:
+		for _, field := range t.Fields.List {
+			if len(field.Names) == 0 {
+				names = append(names, goExpr(fset, field.Type)) // embedded
+			}
+			for _, name := range field.Names {
+				names = append(names, name.Name)
+			}
+		}
+		return "struct{" + joinNames(names) + "}"
+	case *ast.InterfaceType:
+		for _, method := range t.Methods.List {
+			if len(method.Names) == 0 {
+				names = append(names, goExpr(fset, method.Type))
+				continue
+			}
+			for _, name := range method.Names {
+				names = append(names, name.Name+strings.TrimPrefix(goExpr(fset, method.Type), "func"))
+			}
+		}
+		return "interface{" + strings.Join(names, "; ") + "}"
+	}
+	return goExpr(fset, expr)
+}
+
+// goExpr prints an expression on one line
+func goExpr(fset *token.FileSet, expr ast.Expr) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, expr); err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+// joinNames lists names, eliding them past maxOutlineNames
+func joinNames(names []string) string {
+	if len(names) > maxOutlineNames {
+		return strings.Join(names[:maxOutlineNames], ", ") + fmt.Sprintf(", … (%d more)", len(names)-maxOutlineNames)
+	}
+	return strings.Join(names, ", ")
+}
+
+// writeOutlineLine adds a declaration to an outline, with its line number
+func writeOutlineLine(sb *strings.Builder, line int, text string) {
+	if len(text) > maxOutlineLine {
+		text = strings.ToValidUTF8(text[:maxOutlineLine], "") + "…"
+	}
+	fmt.Fprintf(sb, "L%d %s\n", line, text)
+}
+
+// Declarations of other languages are found line by line
+var (
+	pythonDecl = regexp.MustCompile(`^\s*(async\s+)?(def|class)\s+\w+`)
+	jsDecl     = regexp.MustCompile(`^\s*(export\s+)?(default\s+)?(declare\s+)?(abstract\s+)?(async\s+)?(function\*?|class|interface|type|enum|namespace)\s+\w+|^\s*(export\s+)?(const|let)\s+\w+\s*=\s*(async\s+)?(\([^)]*\)|\w+)\s*=>`)
+	rustDecl   = regexp.MustCompile(`^\s*(pub(\([^)]*\))?\s+)?(async\s+)?(unsafe\s+)?(fn|struct|enum|trait|impl|mod|type|macro_rules!)\b`)
+	javaDecl   = regexp.MustCompile(`^\s*(public|protected|private|static|abstract|final|sealed|override|internal|open|data|suspend|\s)*(class|interface|enum|record|object|fun)\s+\w+|^\s*(public|protected|private)\s+[\w<>\[\], ?]+\s+\w+\s*\(`)
+	cDecl      = regexp.MustCompile(`^(static\s+|inline\s+|extern\s+)*[A-Za-z_][\w\s\*:<>,]*[\s\*&]+[A-Za-z_][\w:~]*\s*\([^;]*$|^(typedef\s+)?(struct|enum|union|class|namespace)\s+\w+`)
+	rubyDecl   = regexp.MustCompile(`^\s*(def|class|module)\s+\S+`)
+	phpDecl    = regexp.MustCompile(`^\s*(abstract\s+|final\s+)?(public\s+|protected\s+|private\s+)?(static\s+)?(function|class|interface|trait)\s+\w+`)
+	shellDecl  = regexp.MustCompile(`^\s*(function\s+\w+|\w+\s*\(\)\s*\{?)`)
+	sqlDecl    = regexp.MustCompile(`(?i)^\s*create\s+(or\s+replace\s+)?(table|view|index|unique\s+index|function|procedure|trigger|type)\b`)
+	mdHeading  = regexp.MustCompile(`^#{1,3}\s+\S`)
+)
+
+// IsDeclaration reports whether a line of a file with the given extension
+// declares something (function, class, type, heading...) by the heuristics
+// of non-Go outlines
+func IsDeclaration(ext, line string) bool {
+	return slices.ContainsFunc(outlinePatterns[strings.ToLower(ext)], func(re *regexp.Regexp) bool { return re.MatchString(line) })
+}
+
+var outlinePatterns = map[string][]*regexp.Regexp{
+	".py":       {pythonDecl},
+	".js":       {jsDecl},
+	".jsx":      {jsDecl},
+	".ts":       {jsDecl},
+	".tsx":      {jsDecl},
+	".rs":       {rustDecl},
+	".java":     {javaDecl},
+	".kt":       {javaDecl},
+	".c":        {cDecl},
+	".h":        {cDecl},
+	".cc":       {cDecl},
+	".cpp":      {cDecl},
+	".hpp":      {cDecl},
+	".rb":       {rubyDecl},
+	".php":      {phpDecl},
+	".sh":       {shellDecl},
+	".bash":     {shellDecl},
+	".sql":      {sqlDecl},
+	".md":       {mdHeading},
+	".markdown": {mdHeading},
+}
@@ -0,0 +1,110 @@
+package fileprocessor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/KooQix/term-ai/internal/config"
+	"github.com/KooQix/term-ai/internal/utils"
+)
+
+const (
+	outlineCacheFile    = "outline_cache.json"
+	outlineCacheVersion = 1
+)
+
+// OutlineCache keeps the outlines of source files on disk, so a project
+// outline only parses the files changed since it was last built
+type OutlineCache struct {
+	mu    sync.Mutex
+	path  string // "" keeps the cache in memory only
+	dirty bool
+
+	Version int                     `json:"version"`
+	Entries map[string]outlineEntry `json:"entries"` // absolute file path -> outline
+}
+
+type outlineEntry struct {
+	ModTime int64  `json:"mod_time"` // unix nano
+	Size    int64  `json:"size"`
+	Outline string `json:"outline"`
+}
+
+// LoadOutlineCache reads the outline cache from the config directory,
+// starting a fresh one if it is missing, unreadable or from another version
+func LoadOutlineCache() *OutlineCache {
+	cache := &OutlineCache{Version: outlineCacheVersion, Entries: map[string]outlineEntry{}}
+
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return cache
+	}
+	cache.path = filepath.Join(configDir, outlineCacheFile)
+
+	data, err := os.ReadFile(cache.path)
+	if err != nil {
+		return cache
+	}
+
+	var stored OutlineCache
+	if err := json.Unmarshal(data, &stored); err != nil || stored.Version != outlineCacheVersion || stored.Entries == nil {
+		return cache
+	}
+	cache.Entries = stored.Entries
+	return cache
+}
+
+// get returns the outline of a file, from the cache when the file is unchanged
+func (c *OutlineCache) get(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return ""
+	}
+
+	c.mu.Lock()
+	entry, ok := c.Entries[abs]
+	c.mu.Unlock()
+	if ok && entry.ModTime == info.ModTime().UnixNano() && entry.Size == info.Size() {
+		return entry.Outline
+	}
+
+	outline := fileOutline(abs)
+
+	c.mu.Lock()
+	c.Entries[abs] = outlineEntry{ModTime: info.ModTime().UnixNano(), Size: info.Size(), Outline: outline}
+	c.dirty = true
+	c.mu.Unlock()
+	return outline
+}
+
+// Save writes the cache back if it changed, forgetting deleted files
+func (c *OutlineCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty || c.path == "" {
+		return nil
+	}
+	for path := range c.Entries {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			delete(c.Entries, path)
+		}
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to encode outline cache: %w", err)
+	}
+	if err := utils.WriteFileAtomic(c.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write outline cache: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,352 @@
+package fileprocessor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/KooQix/term-ai/internal/imageproc"
+	"github.com/KooQix/term-ai/internal/provider"
+	"github.com/ledongthuc/pdf"
+)
+
+/// PDF files: text laid out page by page, pages without text (scans) read as
+/// the image they hold
+
+// minPageText is the number of letters and digits under which a page holding
+// an image is taken for a scan (a page number or a stamp aside)
+const minPageText = 20
+
+// errNoImage tells that a page without text has no image either
+var errNoImage = errors.New("no image on the page")
+
+// pdfExtractor reads PDF files, all their pages or some of them
+type pdfExtractor struct{}
+
+func (pdfExtractor) Extract(path string) (*FileAttachment, error) {
+	return processPDF(path, 1, 0)
+}
+
+func (pdfExtractor) ExtractPages(path string, first, last int) (*FileAttachment, error) {
+	return processPDF(path, first, last)
+}
+
+// processPDF extracts the text of pages first to last (0 = the last page) of
+// a PDF file, each under a "## Page n" heading. Pages that are images only
+// are extracted as images, sent to the models that read them.
+func processPDF(path string, first, last int) (attachment *FileAttachment, err error) {
+	filename := filepath.Base(path)
+	// The reader panics on the malformed objects it meets, reading the file or its pages
+	defer func() {
+		if rec := recover(); rec != nil {
+			attachment, err = nil, fmt.Errorf("malformed PDF file: %v", rec)
+		}
+	}()
+
+	// Open the PDF file
+	f, r, err := pdf.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PDF file: %w", err)
+	}
+	defer f.Close()
+
+	totalPages := r.NumPage()
+	if first > totalPages {
+		return nil, fmt.Errorf("%s has %d pages, none from page %d", filename, totalPages, first)
+	}
+	if last == 0 || last > totalPages {
+		last = totalPages
+	}
+	file, err := newPDFFile(f, r.Trailer().Key("Encrypt").Kind() != pdf.Null)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PDF file: %w", err)
+	}
+
+	var out strings.Builder
+	var images []provider.PageImage
+	extracted := false
+	for pageNum := first; pageNum <= last; pageNum++ {
+		p := r.Page(pageNum)
+		if p.V.IsNull() {
+			continue
+		}
+
+		text, err := pageText(p)
+		if err != nil {
+			// Continue with other pages even if one fails
+			fmt.Fprintf(os.Stderr, "Warning: failed to extract text from page %d of %s: %v\n", pageNum, filename, err)
+			continue
+		}
+
+		fmt.Fprintf(&out, "## Page %d\n\n", pageNum)
+		if text != "" {
+			out.WriteString(text + "\n\n")
+			extracted = true
+		}
+		if textLength(text) >= minPageText {
+			continue
+		}
+
+		// Little or no text: a scanned or drawn page
+		url, err := pageImage(file, p)
+		switch {
+		case err == nil:
+			images = append(images, provider.PageImage{Page: pageNum, URL: url})
+			out.WriteString("[No text layer: this page is an image, sent as such to models reading images]\n\n")
+			extracted = true
+		case errors.Is(err, errNoImage):
+			if text == "" {
+				out.WriteString("[No text on this page]\n\n")
+			}
+		default:
+			fmt.Fprintf(&out, "[No text layer: this page is an image that can't be extracted (%v)]\n\n", err)
+		}
+	}
+
+	if !extracted {
+		return nil, fmt.Errorf("no text content could be extracted from PDF")
+	}
+
+	name := filename
+	switch {
+	case first == 1 && last == totalPages:
+	case first == last:
+		name = fmt.Sprintf("%s (page %d of %d)", filename, first, totalPages)
+	default:
+		name = fmt.Sprintf("%s (pages %d-%d of %d)", filename, first, last, totalPages)
+	}
+
+	return &FileAttachment{
+		Path:       path,
+		Type:       "pdf",
+		Content:    strings.TrimSpace(out.String()),
+		MimeType:   "application/pdf",
+		Name:       name,
+		PageImages: images,
+	}, nil
+}
+
+// pageText returns the text of a page, laid out in lines, columns and
+// tables from the position of its characters
+func pageText(p pdf.Page) (text string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			// Content the positions can't be read from: plain text, in the order it is drawn
+			text, err = p.GetPlainText(nil)
+			text = strings.TrimSpace(text)
+		}
+	}()
+	// The reader draws a line break after text positioned glyph by glyph,
+	// which comes out as whatever fonts map it to
+	breaks := map[string]bool{}
+	for _, name := range p.Fonts() {
+		if enc := p.Font(name).Encoder(); enc != nil {
+			breaks[enc.Decode("\n")] = true
+		}
+	}
+	return layoutText(p.Content().Text, pageWidth(p), breaks), nil
+}
+
+// Largest page width in points (200 inches, the limit of the PDF spec), and
+// the levels of the page tree looked up for a media box
+const (
+	maxPageWidth  = 14400
+	maxPageLevels = 32
+)
+
+// pageWidth returns the width of a page in points, from its media box
+// (possibly inherited from the page tree), US Letter if it has none. Widths
+// past the largest page are brought back to it.
+func pageWidth(p pdf.Page) float64 {
+	v := p.V
+	for range maxPageLevels {
+		if v.IsNull() {
+			break
+		}
+		if box := v.Key("MediaBox"); box.Len() == 4 {
+			if width := box.Index(2).Float64() - box.Index(0).Float64(); width > 0 {
+				return min(width, maxPageWidth)
+			}
+		}
+		v = v.Key("Parent")
+	}
+	return 612
+}
+
+// textLength returns the number of letters and digits of a text
+func textLength(text string) int {
+	n := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			n++
+		}
+	}
+	return n
+}
+
+// pageImage returns the largest image of a page as a data URL, prepared as
+// attached images are. Returns errNoImage if the page has none.
+func pageImage(file *pdfFile, p pdf.Page) (url string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			url, err = "", fmt.Errorf("malformed image: %v", r)
+		}
+	}()
+
+	img := largestImage(p.Resources(), 2)
+	if img.IsNull() {
+		return "", errNoImage
+	}
+
+	data, err := imageData(file, img)
+	if err != nil {
+		return "", err
+	}
+	data, mimeType, err := imageproc.Prepare(data, imageOptions())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// largestImage returns the image XObject of the most pixels among resources,
+// looking into forms down to depth levels
+func largestImage(resources pdf.Value, depth int) pdf.Value {
+	var largest pdf.Value
+	pixels := int64(0)
+	xobjects := resources.Key("XObject")
+	for _, key := range xobjects.Keys() {
+		xobj := xobjects.Key(key)
+		candidate := xobj
+		switch xobj.Key("Subtype").Name() {
+		case "Image":
+		case "Form":
+			if depth == 0 {
+				continue
+			}
+			candidate = largestImage(xobj.Key("Resources"), depth-1)
+		default:
+			continue
+		}
+		if n := candidate.Key("Width").Int64() * candidate.Key("Height").Int64(); n > pixels {
+			largest, pixels = candidate, n
+		}
+	}
+	return largest
+}
+
+// imageData returns an image XObject as an image file: JPEG images as they
+// are stored, raw RGB, gray or CMYK pixels encoded as PNG. Other encodings
+// (JBIG2 and CCITT fax scans, JPEG 2000) can't be read.
+func imageData(file *pdfFile, img pdf.Value) ([]byte, error) {
+	filter := img.Key("Filter")
+	if filter.Kind() == pdf.Array {
+		if filter.Len() != 1 {
+			return nil, fmt.Errorf("images encoded with %d filters aren't supported", filter.Len())
+		}
+		filter = filter.Index(0)
+	}
+
+	switch name := filter.Name(); name {
+	case "DCTDecode":
+		// The stream is a JPEG file, copied from the file as it is
+		return file.jpegData(img)
+
+	case "", "FlateDecode":
+		// No more than the pixels of the largest image decoded
+		raw, err := io.ReadAll(io.LimitReader(img.Reader(), imageproc.MaxPixels*4+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image: %w", err)
+		}
+		pixels, err := rasterImage(img, raw)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, pixels); err != nil {
+			return nil, fmt.Errorf("failed to encode image: %w", err)
+		}
+		return buf.Bytes(), nil
+
+	default:
+		return nil, fmt.Errorf("%s images aren't supported", strings.TrimSuffix(name, "Decode"))
+	}
+}
+
+// rasterImage builds an image from the decoded samples of an image XObject
+func rasterImage(img pdf.Value, raw []byte) (image.Image, error) {
+	width, height := int(img.Key("Width").Int64()), int(img.Key("Height").Int64())
+	bits := int(img.Key("BitsPerComponent").Int64())
+	components := colorComponents(img.Key("ColorSpace"))
+	if img.Key("ImageMask").Bool() {
+		bits, components = 1, 1
+	}
+	if width <= 0 || height <= 0 || components == 0 || (bits != 8 && (bits != 1 || components != 1)) {
+		return nil, fmt.Errorf("images of %d bits per sample in %v aren't supported", bits, img.Key("ColorSpace"))
+	}
+	if int64(width)*int64(height) > imageproc.MaxPixels {
+		return nil, fmt.Errorf("%dx%d image is too large to process", width, height)
+	}
+
+	stride := width * components
+	if bits == 1 {
+		stride = (width + 7) / 8
+	}
+	if len(raw) < stride*height {
+		return nil, fmt.Errorf("truncated image")
+	}
+
+	rect := image.Rect(0, 0, width, height)
+	switch {
+	case bits == 1:
+		gray := image.NewGray(rect)
+		for y := range height {
+			for x := range width {
+				if raw[y*stride+x/8]&(0x80>>(x%8)) != 0 {
+					gray.Pix[y*width+x] = 0xff
+				}
+			}
+		}
+		return gray, nil
+	case components == 1:
+		return &image.Gray{Pix: raw, Stride: stride, Rect: rect}, nil
+	case components == 4:
+		return &image.CMYK{Pix: raw, Stride: stride, Rect: rect}, nil
+	}
+	rgba := image.NewNRGBA(rect)
+	for i := range width * height {
+		copy(rgba.Pix[i*4:], raw[i*3:i*3+3])
+		rgba.Pix[i*4+3] = 0xff
+	}
+	return rgba, nil
+}
+
+// colorComponents returns the number of components of the color space of an
+// image, 0 for the ones not supported (indexed, separations, ...)
+func colorComponents(space pdf.Value) int {
+	name := space.Name()
+	if space.Kind() == pdf.Array {
+		name = space.Index(0).Name()
+	}
+	switch name {
+	case "DeviceGray", "CalGray":
+		return 1
+	case "DeviceRGB", "CalRGB":
+		return 3
+	case "DeviceCMYK":
+		return 4
+	case "ICCBased":
+		if n := int(space.Index(1).Key("N").Int64()); n == 1 || n == 3 || n == 4 {
+			return n
+		}
+	}
+	return 0
+}
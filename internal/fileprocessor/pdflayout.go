@@ -0,0 +1,338 @@
+package fileprocessor
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+/// PDF layout: the characters positioned on a page put back into lines,
+/// columns of text and tables
+
+// Distances, in font sizes
+const (
+	wordGap      = 0.2 // between the characters of two words
+	cellGap      = 1.5 // between the cells of a table row, or parts of a line set apart
+	paragraphGap = 1.8 // between the baselines of two lines of different paragraphs
+)
+
+// pdfWord is text of a line, from x0 to x1
+type pdfWord struct {
+	x0, x1 float64
+	text   string
+}
+
+// pdfLine is the words of a page on a baseline, left to right
+type pdfLine struct {
+	y, size float64
+	words   []pdfWord
+}
+
+// pdfRun is text drawn in one go: characters following each other on a baseline
+type pdfRun struct {
+	pdfWord
+	y, size float64
+}
+
+// layoutText returns the text of the characters of a page, in the order they
+// are drawn: lines top to bottom, the columns of a page set in two columns
+// one after the other, and aligned rows as markdown tables. Characters
+// spelling skip with no width are left out.
+func layoutText(glyphs []pdf.Text, width float64, skip map[string]bool) string {
+	lines := textLines(textRuns(glyphs, skip))
+	if len(lines) == 0 {
+		return ""
+	}
+
+	blocks := [][]pdfLine{lines}
+	if gutter := columnGutter(lines, width); gutter > 0 {
+		blocks = splitColumns(lines, gutter)
+	}
+	var parts []string
+	for _, block := range blocks {
+		if text := blockText(block); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// glyphSize returns the font size of a character, positive
+func glyphSize(g pdf.Text) float64 {
+	return max(math.Abs(g.FontSize), 1)
+}
+
+// textRuns joins characters, in the order they are drawn, into runs. The
+// reader doesn't know the widths of some fonts (CID fonts): their characters
+// are all at the start of their run, which is given the width its text would
+// about have.
+func textRuns(glyphs []pdf.Text, skip map[string]bool) []pdfRun {
+	var runs []pdfRun
+	var last pdf.Text
+	unknown := 0 // characters of the last run without width
+	estimate := func() {
+		if n := len(runs); n > 0 {
+			runs[n-1].x1 = max(runs[n-1].x1, runs[n-1].x0+float64(unknown)*runs[n-1].size*0.5)
+		}
+	}
+
+	for _, g := range glyphs {
+		if g.W == 0 && skip[g.S] {
+			continue
+		}
+		size := glyphSize(g)
+		follows := len(runs) > 0 && math.Abs(g.Y-last.Y) < size*0.1 &&
+			g.X > last.X-size && g.X <= last.X+last.W+size*wordGap
+		if !follows {
+			estimate()
+			runs = append(runs, pdfRun{pdfWord: pdfWord{x0: g.X, x1: g.X}, y: g.Y, size: size})
+			unknown = 0
+		}
+
+		run := &runs[len(runs)-1]
+		run.text += g.S
+		run.size = max(run.size, size)
+		run.x1 = max(run.x1, g.X+g.W)
+		if g.W <= 0 {
+			unknown++
+		}
+		last = g
+	}
+	estimate()
+
+	return slices.DeleteFunc(runs, func(r pdfRun) bool { return strings.TrimSpace(r.text) == "" })
+}
+
+// textLines groups runs into lines, by baseline
+func textLines(runs []pdfRun) []pdfLine {
+	slices.SortStableFunc(runs, func(a, b pdfRun) int { return cmp.Compare(b.y, a.y) })
+
+	var lines []pdfLine
+	var current []pdfRun
+	for _, run := range runs {
+		if len(current) > 0 && math.Abs(run.y-current[0].y) > max(current[0].size, run.size)*0.5 {
+			lines = append(lines, newLine(current))
+			current = nil
+		}
+		current = append(current, run)
+	}
+	if len(current) > 0 {
+		lines = append(lines, newLine(current))
+	}
+	return lines
+}
+
+// newLine makes a line of the runs on a baseline, in words where they are
+// set apart
+func newLine(runs []pdfRun) pdfLine {
+	slices.SortStableFunc(runs, func(a, b pdfRun) int { return cmp.Compare(a.x0, b.x0) })
+
+	line := pdfLine{y: runs[0].y}
+	for i, run := range runs {
+		line.size = max(line.size, run.size)
+		// Text drawn twice to look bold
+		if i > 0 && run.text == runs[i-1].text && math.Abs(run.x0-runs[i-1].x0) < run.size*0.1 {
+			continue
+		}
+
+		n := len(line.words)
+		switch {
+		case n == 0 || run.x0-line.words[n-1].x1 > run.size*wordGap:
+			line.words = append(line.words, run.pdfWord)
+		default:
+			line.words[n-1].text += run.text
+			line.words[n-1].x1 = max(line.words[n-1].x1, run.x1)
+		}
+	}
+	for i := range line.words {
+		line.words[i].text = strings.Join(strings.Fields(line.words[i].text), " ")
+	}
+	return line
+}
+
+// cells returns the parts of a line set apart by wide gaps: the cells of a
+// table row, the columns of a line
+func (l pdfLine) cells() []pdfWord {
+	var cells []pdfWord
+	for _, word := range l.words {
+		if n := len(cells); n > 0 && word.x0-cells[n-1].x1 <= l.size*cellGap {
+			cells[n-1].text += " " + word.text
+			cells[n-1].x1 = word.x1
+			continue
+		}
+		cells = append(cells, word)
+	}
+	return cells
+}
+
+// columnGutter returns the middle of the gutter of a page set in two
+// columns, 0 if it isn't: a band down the middle of the page few lines cross,
+// with lines of text on both sides
+func columnGutter(lines []pdfLine, width float64) float64 {
+	if len(lines) < 6 {
+		return 0
+	}
+
+	// Every 2 points, fewer on very wide pages
+	step := max(2, width/500)
+	start, end, best := 0.0, 0.0, 0
+	for x := width * 0.3; x <= width*0.7; x += step {
+		crossing, both := 0, 0
+		leftSpan, rightSpan := 0.0, 0.0
+		for _, line := range lines {
+			left, right, across := split(line, x)
+			switch {
+			case across:
+				crossing++
+			case len(left.words) > 0 && len(right.words) > 0:
+				both++
+				leftSpan += left.words[len(left.words)-1].x1 - left.words[0].x0
+				rightSpan += right.words[len(right.words)-1].x1 - right.words[0].x0
+			}
+		}
+
+		// Columns of prose, not the cells of a table
+		prose := both > 0 && leftSpan/float64(both) > width*0.2 && rightSpan/float64(both) > width*0.2
+		if crossing > len(lines)/4 || both < len(lines)*2/5 || !prose {
+			continue
+		}
+		switch {
+		case both > best:
+			start, end, best = x, x, both
+		case both == best && x-end <= step:
+			end = x
+		}
+	}
+	if best == 0 {
+		return 0
+	}
+	return (start + end) / 2
+}
+
+// split returns the parts of a line left and right of x, and whether the
+// line goes across x: a word across it, or words too close on each side
+func split(line pdfLine, x float64) (pdfLine, pdfLine, bool) {
+	left, right := pdfLine{y: line.y, size: line.size}, pdfLine{y: line.y, size: line.size}
+	for _, cell := range line.cells() {
+		switch {
+		case cell.x1 <= x:
+			left.words = append(left.words, cell)
+		case cell.x0 >= x:
+			right.words = append(right.words, cell)
+		default:
+			return pdfLine{}, pdfLine{}, true
+		}
+	}
+	return left, right, false
+}
+
+// splitColumns returns the lines of a page set in two columns in reading
+// order: the left column then the right one, broken by the lines across
+// the page (titles, figures, footers)
+func splitColumns(lines []pdfLine, gutter float64) [][]pdfLine {
+	var blocks [][]pdfLine
+	var left, right, across []pdfLine
+	flush := func(parts ...[]pdfLine) {
+		for _, part := range parts {
+			if len(part) > 0 {
+				blocks = append(blocks, part)
+			}
+		}
+	}
+
+	for _, line := range lines {
+		l, r, isAcross := split(line, gutter)
+		if isAcross {
+			flush(left, right)
+			left, right = nil, nil
+			across = append(across, line)
+			continue
+		}
+		flush(across)
+		across = nil
+		if len(l.words) > 0 {
+			left = append(left, l)
+		}
+		if len(r.words) > 0 {
+			right = append(right, r)
+		}
+	}
+	flush(across, left, right)
+	return blocks
+}
+
+// blockText returns the text of lines, in paragraphs where they are spaced
+// out, runs of aligned rows being tables
+func blockText(lines []pdfLine) string {
+	var sb strings.Builder
+	wasTable := false
+	for i := 0; i < len(lines); {
+		rows := tableRows(lines[i:])
+		if i > 0 {
+			switch {
+			case rows > 0 || wasTable || lines[i-1].y-lines[i].y > paragraphGap*max(lines[i-1].size, lines[i].size):
+				sb.WriteString("\n\n")
+			default:
+				sb.WriteString("\n")
+			}
+		}
+
+		if rows > 0 {
+			table := make([][]string, rows)
+			for j, line := range lines[i : i+rows] {
+				for _, cell := range line.cells() {
+					table[j] = append(table[j], cell.text)
+				}
+			}
+			sb.WriteString(strings.TrimSuffix(markdownTable(table, 0), "\n"))
+			i += rows
+			wasTable = true
+			continue
+		}
+
+		var cells []string
+		for _, cell := range lines[i].cells() {
+			cells = append(cells, cell.text)
+		}
+		sb.WriteString(strings.Join(cells, "   "))
+		i++
+		wasTable = false
+	}
+	return sb.String()
+}
+
+// tableRows returns how many of the first lines are the rows of a table: at
+// least 3 lines of as many cells, at least 2, each below the one before
+func tableRows(lines []pdfLine) int {
+	prev := lines[0].cells()
+	if len(prev) < 2 {
+		return 0
+	}
+
+	n := 1
+	for ; n < len(lines); n++ {
+		cells := lines[n].cells()
+		if len(cells) != len(prev) || !aligned(prev, cells, lines[n].size) {
+			break
+		}
+		prev = cells
+	}
+	if n < 3 {
+		return 0
+	}
+	return n
+}
+
+// aligned reports whether each cell of a row is below the same cell of the
+// row before, give or take tolerance
+func aligned(above, below []pdfWord, tolerance float64) bool {
+	for i := range above {
+		if below[i].x0 > above[i].x1+tolerance || above[i].x0 > below[i].x1+tolerance {
+			return false
+		}
+	}
+	return true
+}
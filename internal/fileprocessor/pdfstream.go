@@ -0,0 +1,343 @@
+package fileprocessor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+/// PDF streams located in the file, to copy JPEG images as they are stored:
+/// the reader only decodes streams, and has no JPEG decoder
+
+const (
+	streamScanChunk   = 1 << 20 // bytes of the file read at a time
+	streamScanOverlap = 8 << 10 // bytes read again from the previous chunk, for the objects across two
+)
+
+var (
+	// The header of an object, "12 0 obj", and the keyword starting the data of a stream
+	objectHeader  = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	streamKeyword = regexp.MustCompile(`stream\r?\n`)
+	// An object holding an integer only, the length of a stream written after it
+	integerObject = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\s*(\d+)\s*endobj`)
+)
+
+// pdfStream is a stream object of a PDF file: its dictionary (its keys and
+// their values as written) and where its data starts
+type pdfStream struct {
+	id    string // "12 0", object number and generation
+	dict  map[string]string
+	start int64
+}
+
+// pdfFile locates the streams of a PDF file, scanning it once when first needed
+type pdfFile struct {
+	f         *os.File
+	size      int64
+	encrypted bool // streams of encrypted files can't be copied as they are
+
+	scanned  bool
+	streams  []pdfStream
+	integers map[string]string // "12 0" -> the integer object 12 0 holds
+}
+
+func newPDFFile(f *os.File, encrypted bool) (*pdfFile, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &pdfFile{f: f, size: info.Size(), encrypted: encrypted}, nil
+}
+
+// jpegData returns the data of an image XObject stored as a JPEG file
+// (DCTDecode), as it is in the file
+func (pf *pdfFile) jpegData(img pdf.Value) ([]byte, error) {
+	if pf.encrypted {
+		return nil, fmt.Errorf("JPEG images of encrypted files aren't supported")
+	}
+	streams, err := pf.find(img)
+	if err != nil {
+		return nil, err
+	}
+
+	// Streams of the same dictionary (an image used twice) have to hold the same image
+	var data []byte
+	for _, stream := range streams {
+		length := img.Key("Length").Int64()
+		if length <= 0 || length > pf.size-stream.start {
+			return nil, fmt.Errorf("JPEG image of %d bytes doesn't fit in the file", length)
+		}
+		candidate := make([]byte, length)
+		if _, err := pf.f.ReadAt(candidate, stream.start); err != nil {
+			return nil, fmt.Errorf("failed to read JPEG image: %w", err)
+		}
+		if !bytes.HasPrefix(candidate, []byte{0xff, 0xd8}) {
+			return nil, fmt.Errorf("no JPEG image at offset %d of the file", stream.start)
+		}
+		if data != nil && !bytes.Equal(data, candidate) {
+			return nil, fmt.Errorf("JPEG image can't be told apart from the %d others of the same size in the file", len(streams)-1)
+		}
+		data = candidate
+	}
+	return data, nil
+}
+
+// find returns the streams of the file whose dictionary is the one of img:
+// the same values for its direct keys, an object updated later in the file
+// replacing the earlier one
+func (pf *pdfFile) find(img pdf.Value) ([]pdfStream, error) {
+	if !pf.scanned {
+		if err := pf.scan(); err != nil {
+			return nil, err
+		}
+	}
+
+	var found []pdfStream
+	for _, stream := range pf.streams {
+		if !pf.matches(stream, img) {
+			continue
+		}
+		if i := slices.IndexFunc(found, func(s pdfStream) bool { return s.id == stream.id }); i >= 0 {
+			found[i] = stream
+			continue
+		}
+		found = append(found, stream)
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("JPEG image can't be located in the file")
+	}
+	return found, nil
+}
+
+// matches reports whether the dictionary of a stream has the values of img
+func (pf *pdfFile) matches(stream pdfStream, img pdf.Value) bool {
+	if len(stream.dict) != len(img.Keys()) {
+		return false
+	}
+	for _, key := range img.Keys() {
+		written, ok := stream.dict[key]
+		if !ok {
+			return false
+		}
+		if id, ok := strings.CutSuffix(written, " R"); ok {
+			if written, ok = pf.integers[id]; !ok {
+				// An object the scan doesn't read (in an object stream): any value
+				continue
+			}
+		}
+
+		value := img.Key(key)
+		switch value.Kind() {
+		case pdf.Integer:
+			if written != strconv.FormatInt(value.Int64(), 10) {
+				return false
+			}
+		case pdf.Name:
+			if written != "/"+value.Name() {
+				return false
+			}
+		case pdf.Bool:
+			if written != strconv.FormatBool(value.Bool()) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// scan finds the streams of the file and the integer objects their lengths
+// may refer to, reading it by chunks
+func (pf *pdfFile) scan() error {
+	pf.scanned = true
+	pf.integers = map[string]string{}
+	seen := map[int64]bool{}
+
+	buf := make([]byte, streamScanChunk+streamScanOverlap)
+	for offset := int64(0); offset < pf.size; offset += streamScanChunk {
+		base := max(offset-streamScanOverlap, 0)
+		n, err := pf.f.ReadAt(buf[:min(int64(len(buf)), pf.size-base)], base)
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read the PDF file: %w", err)
+		}
+		chunk := buf[:n]
+
+		for _, m := range integerObject.FindAllSubmatch(chunk, -1) {
+			pf.integers[string(m[1])+" "+string(m[2])] = string(m[3])
+		}
+
+		headers := objectHeader.FindAllSubmatchIndex(chunk, -1)
+		for i, h := range headers {
+			if seen[base+int64(h[0])] {
+				continue
+			}
+			end := len(chunk)
+			if i+1 < len(headers) {
+				end = headers[i+1][0]
+			}
+			dict, rest, ok := parseDict(chunk[h[1]:end])
+			if !ok {
+				continue
+			}
+			keyword := streamKeyword.FindIndex(rest)
+			if keyword == nil || len(bytes.TrimSpace(rest[:keyword[0]])) > 0 {
+				continue
+			}
+
+			seen[base+int64(h[0])] = true
+			pf.streams = append(pf.streams, pdfStream{
+				id:    string(chunk[h[2]:h[3]]) + " " + string(chunk[h[4]:h[5]]),
+				dict:  dict,
+				start: base + int64(end-len(rest)+keyword[1]),
+			})
+		}
+	}
+	return nil
+}
+
+// parseDict reads the dictionary at the start of data: its keys and the
+// values written for them, nested dictionaries and arrays as a whole. Returns
+// what follows it, ok being false if data doesn't start with a whole one.
+func parseDict(data []byte) (map[string]string, []byte, bool) {
+	lex := &pdfLexer{data: data}
+	if lex.next() != "<<" {
+		return nil, nil, false
+	}
+
+	dict := map[string]string{}
+	for {
+		key := lex.next()
+		switch {
+		case key == ">>":
+			return dict, data[lex.pos:], true
+		case !strings.HasPrefix(key, "/"):
+			return nil, nil, false
+		}
+
+		start := lex.pos
+		value := lex.next()
+		switch value {
+		case "", ">>":
+			return nil, nil, false
+		case "<<", "[":
+			if !lex.skipNested() {
+				return nil, nil, false
+			}
+			value = strings.TrimSpace(string(data[start:lex.pos]))
+		default:
+			// An indirect reference, "12 0 R"
+			if mark := lex.pos; isInteger(value) {
+				if gen, r := lex.next(), lex.next(); isInteger(gen) && r == "R" {
+					value += " " + gen + " R"
+				} else {
+					lex.pos = mark
+				}
+			}
+		}
+		dict[key[1:]] = value
+	}
+}
+
+func isInteger(token string) bool {
+	_, err := strconv.ParseUint(token, 10, 64)
+	return err == nil
+}
+
+// pdfLexer splits PDF syntax into tokens: delimiters, names, strings,
+// numbers and keywords
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+// next returns the next token, "" at the end of the data
+func (l *pdfLexer) next() string {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return ""
+	}
+
+	start := l.pos
+	switch c := l.data[l.pos]; {
+	case bytes.HasPrefix(l.data[l.pos:], []byte("<<")), bytes.HasPrefix(l.data[l.pos:], []byte(">>")):
+		l.pos += 2
+	case c == '[' || c == ']' || c == '{' || c == '}':
+		l.pos++
+	case c == '(':
+		// Literal string, with balanced or escaped parentheses
+		depth := 0
+		for ; l.pos < len(l.data); l.pos++ {
+			switch l.data[l.pos] {
+			case '\\':
+				l.pos++
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			if depth == 0 {
+				l.pos++
+				break
+			}
+		}
+	case c == '<':
+		// Hex string
+		if end := bytes.IndexByte(l.data[l.pos:], '>'); end >= 0 {
+			l.pos += end + 1
+		} else {
+			l.pos = len(l.data)
+		}
+	default:
+		l.pos++
+		for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+	}
+	return string(l.data[start:l.pos])
+}
+
+// skipNested moves past the end of the dictionary or array just opened
+func (l *pdfLexer) skipNested() bool {
+	depth := 1
+	for depth > 0 {
+		switch l.next() {
+		case "":
+			return false
+		case "<<", "[":
+			depth++
+		case ">>", "]":
+			depth--
+		}
+	}
+	return true
+}
+
+// skipSpace moves past white-space and comments
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		switch c := l.data[l.pos]; {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
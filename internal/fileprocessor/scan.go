@@ -0,0 +1,422 @@
+package fileprocessor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ScanOptions controls which files of a directory ScanDirectory keeps
+type ScanOptions struct {
+	MaxDepth     int      // levels of subdirectories to descend into (0 = top level only, negative = unlimited)
+	Include      []string // globs of the files to keep, relative to the directory ("**/*.go"); empty keeps every supported file
+	Exclude      []string // globs of the files and directories to leave out
+	NoIgnore     bool     // don't read .gitignore and .termaiignore files
+	MaxFileSize  int64    // files larger than this are skipped (0 = no limit)
+	MaxTotalSize int64    // bytes of files kept in total, shallower files first (0 = no limit)
+}
+
+// DefaultScanOptions returns the options used when none are configured
+func DefaultScanOptions() ScanOptions {
+	return ScanOptions{
+		MaxDepth:     5,
+		MaxFileSize:  10 * 1024 * 1024,
+		MaxTotalSize: 512 * 1024,
+	}
+}
+
+// Reasons a scanned file is left out, as shown in the scan report
+const (
+	SkipIgnored     = "ignored"
+	SkipExcluded    = "excluded by pattern"
+	SkipNotIncluded = "not matching --include"
+	SkipHidden      = "hidden"
+	SkipVendored    = "vendored or dependency directory"
+	SkipTooDeep     = "deeper than the depth limit"
+	SkipUnsupported = "unsupported file type"
+	SkipBinary      = "binary content"
+	SkipGenerated   = "generated file"
+	SkipTooLarge    = "larger than the file size limit"
+	SkipBudget      = "over the total size budget"
+	SkipUnreadable  = "unreadable"
+)
+
+// SkippedFile is a file or directory ScanDirectory left out
+type SkippedFile struct {
+	Path   string // relative to the scanned directory, with a trailing slash for directories
+	Reason string // one of the Skip* reasons
+	Detail string // the ignore file, pattern or error behind the reason, if any
+}
+
+// ScanResult is what ScanDirectory found
+type ScanResult struct {
+	Files     []*FileAttachment // named after their path relative to the scanned directory
+	Skipped   []SkippedFile
+	TotalSize int64 // bytes of the files kept
+}
+
+// Directories holding dependencies rather than the project's own code
+var vendoredDirs = map[string]bool{
+	"vendor":           true,
+	"node_modules":     true,
+	"bower_components": true,
+	"third_party":      true,
+	"Pods":             true,
+	"venv":             true,
+	"__pycache__":      true,
+	"site-packages":    true,
+}
+
+// Files written by tools rather than by hand, by name
+var generatedNames = []string{
+	"*.pb.go", "*_gen.go", "*.gen.go", "*_generated.*", "*.generated.*", "zz_generated*",
+	"*.min.js", "*.min.css", "*.map",
+	"go.sum", "package-lock.json", "yarn.lock", "pnpm-lock.yaml", "Cargo.lock", "poetry.lock", "composer.lock", "Gemfile.lock",
+}
+
+// Marker of generated files in their first lines: the Go convention
+// ("// Code generated ... DO NOT EDIT.") and the "@generated" tag
+var generatedMarker = regexp.MustCompile(`(?m)^// Code generated .* DO NOT EDIT\.$|@generated\b`)
+
+const sniffSize = 8000 // bytes read to detect binary content and generation markers
+
+// ScanDirectory collects the supported files of a directory and of its
+// subdirectories (down to opts.MaxDepth), following .gitignore and
+// .termaiignore files. Hidden, vendored, binary and generated files are left
+// out, and files are kept shallower first until the total size budget is
+// spent. Everything left out is reported with the reason in the result.
+func ScanDirectory(dirPath string, opts ScanOptions) (*ScanResult, error) {
+	candidates, skipped, err := collectFiles(dirPath, opts, true)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ScanResult{Skipped: skipped}
+	skip := func(rel, reason, detail string) {
+		result.Skipped = append(result.Skipped, SkippedFile{Path: rel, Reason: reason, Detail: detail})
+	}
+
+	// Shallower files get the budget first
+	for _, c := range candidates {
+		if opts.MaxTotalSize > 0 && result.TotalSize+c.size > opts.MaxTotalSize {
+			skip(c.rel, SkipBudget, FormatSize(c.size))
+			continue
+		}
+
+		if reason, detail := SniffContent(c.path); reason != "" {
+			skip(c.rel, reason, detail)
+			continue
+		}
+
+		attachment, err := ProcessFile(c.path)
+		if err != nil {
+			skip(c.rel, SkipUnreadable, err.Error())
+			continue
+		}
+		attachment.Name = c.rel
+
+		result.Files = append(result.Files, attachment)
+		result.TotalSize += c.size
+	}
+
+	if len(result.Files) == 0 {
+		return result, fmt.Errorf("no supported files found in directory")
+	}
+
+	// Files are listed in path order, whatever order the budget took them in
+	slices.SortFunc(result.Files, func(a, b *FileAttachment) int { return strings.Compare(a.Name, b.Name) })
+	return result, nil
+}
+
+// ListFiles returns the supported files of a directory ScanDirectory would
+// consider, before the total size budget: paths relative to the directory,
+// slash-separated and shallower first, and the paths left out.
+func ListFiles(dirPath string, opts ScanOptions) ([]string, []SkippedFile, error) {
+	candidates, skipped, err := collectFiles(dirPath, opts, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rels := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		rels = append(rels, c.rel)
+	}
+	return rels, skipped, nil
+}
+
+// candidate is a file kept by collectFiles
+type candidate struct {
+	path, rel string
+	depth     int
+	size      int64
+}
+
+// collectFiles walks a directory, applying the options but the total size
+// budget, and returns the files kept (shallower first) and the paths left
+// out. With supportedOnly, files of unsupported types are left out too.
+func collectFiles(dirPath string, opts ScanOptions, supportedOnly bool) ([]candidate, []SkippedFile, error) {
+	// Check if directory exists
+	info, err := os.Stat(dirPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot access directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, nil, fmt.Errorf("%s is not a directory", dirPath)
+	}
+
+	var (
+		candidates []candidate
+		skipped    []SkippedFile
+	)
+	skip := func(rel, reason, detail string) {
+		skipped = append(skipped, SkippedFile{Path: rel, Reason: reason, Detail: detail})
+	}
+
+	ignore := newIgnoreMatcher()
+	err = filepath.WalkDir(dirPath, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			if p == dirPath {
+				return err
+			}
+			skip(relPath(dirPath, p), SkipUnreadable, err.Error())
+			return nil
+		}
+
+		rel := relPath(dirPath, p)
+		depth := strings.Count(rel, "/")
+		name := d.Name()
+
+		if d.IsDir() {
+			if p == dirPath {
+				if !opts.NoIgnore {
+					ignore.load(p, ".")
+				}
+				return nil
+			}
+
+			reason, detail := dirSkipReason(ignore, opts, name, rel)
+			if reason == "" && opts.MaxDepth >= 0 && depth >= opts.MaxDepth {
+				reason = SkipTooDeep
+			}
+			if reason != "" {
+				skip(rel+"/", reason, detail)
+				return filepath.SkipDir
+			}
+
+			if !opts.NoIgnore {
+				ignore.load(p, rel)
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if slices.Contains(ignoreFiles, name) {
+			return nil
+		}
+
+		reason, detail := fileSkipReason(ignore, opts, name, rel)
+		if reason == "" && len(opts.Include) > 0 && !slices.ContainsFunc(opts.Include, func(g string) bool { return matchGlob(g, rel) }) {
+			reason = SkipNotIncluded
+		}
+		if reason == "" && supportedOnly {
+			reason, detail = supportReason(p)
+		}
+		if reason == "" && isGeneratedName(name) {
+			reason = SkipGenerated
+		}
+		if reason != "" {
+			skip(rel, reason, detail)
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			skip(rel, SkipUnreadable, err.Error())
+			return nil
+		}
+		if opts.MaxFileSize > 0 && info.Size() > opts.MaxFileSize {
+			skip(rel, SkipTooLarge, FormatSize(info.Size()))
+			return nil
+		}
+
+		candidates = append(candidates, candidate{path: p, rel: rel, depth: depth, size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error scanning directory: %w", err)
+	}
+
+	// Files closer to the root usually say more about the project (README,
+	// manifests, entry points), so they come first
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		if a.depth != b.depth {
+			return a.depth - b.depth
+		}
+		return strings.Compare(a.rel, b.rel)
+	})
+	return candidates, skipped, nil
+}
+
+// dirSkipReason returns why a directory is left out (hidden, vendored,
+// ignored or excluded), the depth limit aside
+func dirSkipReason(ignore *ignoreMatcher, opts ScanOptions, name, rel string) (string, string) {
+	switch {
+	case strings.HasPrefix(name, "."):
+		return SkipHidden, ""
+	case vendoredDirs[name]:
+		return SkipVendored, ""
+	}
+	return filterPath(ignore, opts, rel, true)
+}
+
+// fileSkipReason returns why a file is left out by its path (hidden, ignored
+// or excluded), its type and size aside
+func fileSkipReason(ignore *ignoreMatcher, opts ScanOptions, name, rel string) (string, string) {
+	if strings.HasPrefix(name, ".") {
+		return SkipHidden, ""
+	}
+	return filterPath(ignore, opts, rel, false)
+}
+
+// PathSkipReason returns why a scan of root leaves out the file at rel
+// (slash-separated, relative to root): a hidden, vendored, ignored or
+// excluded file or directory on the way. Nothing if the path is kept; its
+// depth, type and size aren't checked.
+func PathSkipReason(root, rel string, opts ScanOptions) (string, string) {
+	ignore := newIgnoreMatcher()
+	if !opts.NoIgnore {
+		ignore.load(root, ".")
+	}
+
+	parts := strings.Split(rel, "/")
+	for i, name := range parts[:len(parts)-1] {
+		dir := strings.Join(parts[:i+1], "/")
+		if reason, detail := dirSkipReason(ignore, opts, name, dir); reason != "" {
+			return reason, detail
+		}
+		if !opts.NoIgnore {
+			ignore.load(filepath.Join(root, filepath.FromSlash(dir)), dir)
+		}
+	}
+	return fileSkipReason(ignore, opts, parts[len(parts)-1], rel)
+}
+
+// filterPath applies the ignore files and the exclude globs to a path
+func filterPath(ignore *ignoreMatcher, opts ScanOptions, rel string, isDir bool) (string, string) {
+	if ignored, source := ignore.match(rel, isDir); ignored {
+		return SkipIgnored, source
+	}
+	for _, glob := range opts.Exclude {
+		if matchGlob(glob, rel) {
+			return SkipExcluded, glob
+		}
+	}
+	return "", ""
+}
+
+// supportReason returns why a file can't be attached (SkipBinary with what
+// it is, or SkipUnsupported), nothing if it can
+func supportReason(p string) (string, string) {
+	_, err := FormatOf(p)
+	var binaryErr *BinaryError
+	switch {
+	case err == nil:
+		return "", ""
+	case errors.As(err, &binaryErr):
+		return SkipBinary, binaryErr.Kind
+	}
+	return SkipUnsupported, ""
+}
+
+// isGeneratedName reports whether a file name is one of a generated file or lock file
+func isGeneratedName(name string) bool {
+	return slices.ContainsFunc(generatedNames, func(glob string) bool {
+		ok, _ := path.Match(glob, name)
+		return ok
+	})
+}
+
+// SniffContent looks at the start of a text or code file for binary content
+// or a generation marker, returning the skip reason (SkipBinary or
+// SkipGenerated) if any, with what the binary content is
+func SniffContent(p string) (string, string) {
+	if isBinaryFormat(p) {
+		return "", ""
+	}
+
+	head, err := readHead(p)
+	if err != nil {
+		return "", ""
+	}
+	if _, binaryKind := textEncoding(head); binaryKind != "" {
+		return SkipBinary, binaryKind
+	}
+	if generatedMarker.Match(head) {
+		return SkipGenerated, ""
+	}
+	return "", ""
+}
+
+// relPath returns the slash-separated path of p relative to root
+func relPath(root, p string) string {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return filepath.ToSlash(p)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Report summarizes what was skipped and why, listing a few paths per reason
+func (r *ScanResult) Report(examples int) string {
+	if len(r.Skipped) == 0 {
+		return ""
+	}
+
+	var reasons []string
+	byReason := map[string][]SkippedFile{}
+	for _, s := range r.Skipped {
+		if _, ok := byReason[s.Reason]; !ok {
+			reasons = append(reasons, s.Reason)
+		}
+		byReason[s.Reason] = append(byReason[s.Reason], s)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Skipped %d path(s):", len(r.Skipped))
+	for _, reason := range reasons {
+		skipped := byReason[reason]
+		fmt.Fprintf(&sb, "\n  %s (%d):", reason, len(skipped))
+		for i, s := range skipped {
+			if i == examples {
+				fmt.Fprintf(&sb, " … and %d more", len(skipped)-examples)
+				break
+			}
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			sb.WriteString(" " + s.Path)
+			if s.Detail != "" {
+				sb.WriteString(" (" + s.Detail + ")")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// FormatSize shows a byte count in a readable unit
+func FormatSize(size int64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%d B", size)
+}
@@ -0,0 +1,215 @@
+package fileprocessor
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/// Selectors attach part of a file: main.go:40-120 (lines), chat.go#streamResponse
+/// (a Go function, method or type), report.pdf#pages=3-7 (pages of a PDF)
+
+// Selection is the part of a file to attach, given after its path
+type Selection struct {
+	StartLine int    // 1-based, inclusive
+	EndLine   int    // inclusive, 0 = to the end of the file
+	Symbol    string // Go function, type or method (Type.Method); lines are then found from it
+	FirstPage int    // 1-based, inclusive; 0 = no page selection
+	LastPage  int    // inclusive, 0 = to the last page
+}
+
+var (
+	lineSelector   = regexp.MustCompile(`^(.+):(\d+)(?:-(\d*))?$`)
+	pageSelector   = regexp.MustCompile(`^(.+)#pages=(\d+)(?:-(\d*))?$`)
+	symbolSelector = regexp.MustCompile(`^(.+)#([A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)?)$`)
+)
+
+// ParseSelector splits a file argument into its path and the selection that
+// follows it, nil if none: "main.go:40-120", "main.go:40-" (to the end),
+// "main.go:40" (one line), "chat.go#streamResponse", "chat.go#chatModel.View",
+// "report.pdf#pages=3-7", "report.pdf#pages=3-" (to the end), "report.pdf#pages=3".
+// An argument naming an existing file is a path, whatever it holds.
+func ParseSelector(arg string) (string, *Selection, error) {
+	if _, err := os.Stat(arg); err == nil {
+		return arg, nil, nil
+	}
+
+	if m := pageSelector.FindStringSubmatch(arg); m != nil {
+		sel := &Selection{}
+		sel.FirstPage, _ = strconv.Atoi(m[2])
+		switch {
+		case !strings.Contains(arg[len(m[1]):], "-"):
+			sel.LastPage = sel.FirstPage
+		case m[3] != "":
+			sel.LastPage, _ = strconv.Atoi(m[3])
+		}
+		if sel.FirstPage < 1 || (sel.LastPage != 0 && sel.LastPage < sel.FirstPage) {
+			return "", nil, fmt.Errorf("invalid page range in %s (expected file#pages=first-last)", arg)
+		}
+		return m[1], sel, nil
+	}
+	if m := symbolSelector.FindStringSubmatch(arg); m != nil {
+		return m[1], &Selection{Symbol: m[2]}, nil
+	}
+	if m := lineSelector.FindStringSubmatch(arg); m != nil {
+		sel := &Selection{}
+		sel.StartLine, _ = strconv.Atoi(m[2])
+		switch {
+		case !strings.Contains(arg[len(m[1]):], "-"):
+			sel.EndLine = sel.StartLine
+		case m[3] != "":
+			sel.EndLine, _ = strconv.Atoi(m[3])
+		}
+		if sel.StartLine < 1 || (sel.EndLine != 0 && sel.EndLine < sel.StartLine) {
+			return "", nil, fmt.Errorf("invalid line range in %s (expected file:start-end)", arg)
+		}
+		return m[1], sel, nil
+	}
+	return arg, nil, nil
+}
+
+// apply cuts an attachment down to the selection, recording the lines kept
+// in its name so that they can be cited
+func (sel *Selection) apply(attachment *FileAttachment) error {
+	if attachment.Type != "text" && attachment.Type != "code" {
+		return fmt.Errorf("%s: line and symbol selectors apply to text and code files, not %s files", attachment.Name, attachment.Type)
+	}
+
+	name := attachment.Name
+	if sel.Symbol != "" {
+		if attachment.Language != "go" {
+			return fmt.Errorf("%s: symbol selectors apply to Go files", attachment.Name)
+		}
+		start, end, err := goSymbolLines(attachment.Content, sel.Symbol)
+		if err != nil {
+			return fmt.Errorf("%s: %w", attachment.Name, err)
+		}
+		sel.StartLine, sel.EndLine = start, end
+		name += "#" + sel.Symbol
+	}
+
+	lines := strings.Split(strings.TrimSuffix(attachment.Content, "\n"), "\n")
+	end := sel.EndLine
+	if end == 0 || end > len(lines) {
+		end = len(lines)
+	}
+	if sel.StartLine > end {
+		return fmt.Errorf("%s has %d lines, none from line %d", attachment.Name, len(lines), sel.StartLine)
+	}
+
+	attachment.Content = strings.Join(lines[sel.StartLine-1:end], "\n") + "\n"
+	if sel.StartLine == end {
+		attachment.Name = fmt.Sprintf("%s (line %d of %d)", name, end, len(lines))
+	} else {
+		attachment.Name = fmt.Sprintf("%s (lines %d-%d of %d)", name, sel.StartLine, end, len(lines))
+	}
+	return nil
+}
+
+// goSymbolLines returns the lines of a top-level Go declaration, its doc
+// comment included: a function, a method (Type.Method or just Method) or a
+// type, const or var
+func goSymbolLines(content, symbol string) (int, int, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse: %w", err)
+	}
+
+	recv, name, isMethod := strings.Cut(symbol, ".")
+	if !isMethod {
+		name = recv
+	}
+
+	lines := func(node ast.Node, doc *ast.CommentGroup) (int, int, error) {
+		start := node.Pos()
+		if doc != nil {
+			start = doc.Pos()
+		}
+		return fset.Position(start).Line, fset.Position(node.End()).Line, nil
+	}
+
+	var candidates []string
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			declName := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				declName = receiverType(d.Recv.List[0].Type) + "." + declName
+			}
+			if declName == symbol || (!isMethod && d.Name.Name == name) {
+				return lines(d, d.Doc)
+			}
+			candidates = append(candidates, declName)
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				var names []*ast.Ident
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					names = []*ast.Ident{s.Name}
+				case *ast.ValueSpec:
+					names = s.Names
+				}
+				for _, ident := range names {
+					if !isMethod && ident.Name == name {
+						// A lone spec is shown with its declaration ("type X struct"), grouped ones alone
+						if len(d.Specs) == 1 {
+							return lines(d, d.Doc)
+						}
+						return lines(spec, specDoc(spec))
+					}
+					candidates = append(candidates, ident.Name)
+				}
+			}
+		}
+	}
+
+	if similar := similarNames(candidates, name); len(similar) > 0 {
+		return 0, 0, fmt.Errorf("no declaration named %s (did you mean %s?)", symbol, strings.Join(similar, ", "))
+	}
+	return 0, 0, fmt.Errorf("no declaration named %s", symbol)
+}
+
+// receiverType returns the name of the type of a method receiver, without
+// pointer nor type parameters
+func receiverType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverType(t.X)
+	case *ast.IndexExpr:
+		return receiverType(t.X)
+	case *ast.IndexListExpr:
+		return receiverType(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+func specDoc(spec ast.Spec) *ast.CommentGroup {
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		return s.Doc
+	case *ast.ValueSpec:
+		return s.Doc
+	}
+	return nil
+}
+
+// similarNames returns the declarations whose name contains the one looked
+// for, case aside, to suggest them
+func similarNames(candidates []string, name string) []string {
+	var similar []string
+	for _, candidate := range candidates {
+		if strings.Contains(strings.ToLower(candidate), strings.ToLower(name)) && len(similar) < 5 {
+			similar = append(similar, candidate)
+		}
+	}
+	return similar
+}
@@ -0,0 +1,266 @@
+package fileprocessor
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/// Spreadsheets (.csv, .tsv, .xlsx), rendered as markdown tables
+
+// maxTableRows caps the rows rendered per table (sheet), the first being the header
+const maxTableRows = 500
+
+// maxSheetColumns is the number of columns of a worksheet, A to XFD
+const maxSheetColumns = 16384
+
+// extractCSV renders a CSV (or TSV) file as a markdown table
+func extractCSV(data []byte, comma rune) (string, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1 // ragged rows are padded
+	reader.LazyQuotes = true
+
+	var rows [][]string
+	total := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("invalid CSV: %w", err)
+		}
+		total++
+		if len(rows) < maxTableRows {
+			rows = append(rows, record)
+		}
+	}
+	if len(rows) == 0 {
+		return "", fmt.Errorf("empty CSV file")
+	}
+	return markdownTable(rows, total-len(rows)), nil
+}
+
+// extractXlsx renders each sheet of a workbook as a markdown table under its name
+func extractXlsx(zr *zip.Reader) (string, error) {
+	data, err := officePart(zr, "xl/workbook.xml")
+	if err != nil {
+		return "", err
+	}
+	var workbook struct {
+		Sheets []struct {
+			Name  string `xml:"name,attr"`
+			RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal(data, &workbook); err != nil {
+		return "", fmt.Errorf("invalid workbook.xml: %w", err)
+	}
+	rels, err := officeRelations(zr, "xl/workbook.xml")
+	if err != nil {
+		return "", err
+	}
+	shared, err := sharedStrings(zr)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	for _, sheet := range workbook.Sheets {
+		data, err := officePart(zr, rels[sheet.RelID])
+		if err != nil {
+			return "", err
+		}
+		rows, total, err := sheetRows(data, shared)
+		if err != nil {
+			return "", fmt.Errorf("invalid sheet %s: %w", sheet.Name, err)
+		}
+
+		out.WriteString("## " + sheet.Name + "\n\n")
+		if len(rows) == 0 {
+			out.WriteString("(empty)\n\n")
+			continue
+		}
+		out.WriteString(markdownTable(rows, total-len(rows)) + "\n")
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// sharedStrings returns the strings table of a workbook, cells of type "s"
+// holding an index into it
+func sharedStrings(zr *zip.Reader) ([]string, error) {
+	data, err := officePart(zr, "xl/sharedStrings.xml")
+	if err != nil {
+		return nil, nil // workbooks without strings have none
+	}
+
+	var (
+		strs     []string
+		current  strings.Builder
+		phonetic bool // inside a phonetic reading, not part of the text
+	)
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid sharedStrings.xml: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				current.Reset()
+			case "rPh":
+				phonetic = true
+			case "t":
+				var text string
+				if err := decoder.DecodeElement(&text, &t); err != nil {
+					return nil, fmt.Errorf("invalid sharedStrings.xml: %w", err)
+				}
+				if !phonetic {
+					current.WriteString(text)
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				strs = append(strs, current.String())
+			case "rPh":
+				phonetic = false
+			}
+		}
+	}
+	return strs, nil
+}
+
+// sheetRows reads the cells of a worksheet into rows (empty cells and rows in
+// between kept, so that columns line up), up to maxTableRows. Also returns the
+// number of rows of the sheet.
+func sheetRows(data []byte, shared []string) ([][]string, int, error) {
+	var sheet struct {
+		Rows []struct {
+			R     int `xml:"r,attr"`
+			Cells []struct {
+				Ref    string `xml:"r,attr"`
+				Type   string `xml:"t,attr"`
+				Value  string `xml:"v"`
+				Inline struct {
+					Text string   `xml:"t"`
+					Runs []string `xml:"r>t"`
+				} `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.Unmarshal(data, &sheet); err != nil {
+		return nil, 0, err
+	}
+
+	var rows [][]string
+	total := 0
+	for _, row := range sheet.Rows {
+		if row.R < 0 {
+			continue
+		}
+		index := row.R - 1
+		if row.R == 0 {
+			index = total
+		}
+		total = max(total, index+1)
+		if index >= maxTableRows {
+			continue
+		}
+
+		var cells []string
+		for i, c := range row.Cells {
+			col := i
+			if c.Ref != "" {
+				col = columnIndex(c.Ref)
+			}
+			if col >= maxSheetColumns {
+				return nil, 0, fmt.Errorf("cell %s is past the last column (XFD)", c.Ref)
+			}
+
+			value := c.Value
+			switch c.Type {
+			case "s":
+				if n, err := strconv.Atoi(c.Value); err == nil && n >= 0 && n < len(shared) {
+					value = shared[n]
+				}
+			case "inlineStr":
+				value = c.Inline.Text + strings.Join(c.Inline.Runs, "")
+			case "b":
+				value = map[string]string{"0": "FALSE", "1": "TRUE"}[c.Value]
+			}
+
+			for len(cells) <= col {
+				cells = append(cells, "")
+			}
+			cells[col] = value
+		}
+
+		for len(rows) <= index {
+			rows = append(rows, nil)
+		}
+		rows[index] = cells
+	}
+	return rows, total, nil
+}
+
+// columnIndex returns the 0-based column of a cell reference ("C7" gives 2),
+// maxSheetColumns or more for references past the last column
+func columnIndex(ref string) int {
+	col := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' || col > maxSheetColumns {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+	}
+	return max(col-1, 0)
+}
+
+// markdownTable renders rows as a markdown table, the first one as header,
+// noting how many more rows were left out
+func markdownTable(rows [][]string, omitted int) string {
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	if width == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	writeRow := func(row []string) {
+		sb.WriteString("|")
+		for i := range width {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			cell = strings.NewReplacer("|", `\|`, "\r\n", " ", "\n", " ").Replace(strings.TrimSpace(cell))
+			sb.WriteString(" " + cell + " |")
+		}
+		sb.WriteString("\n")
+	}
+
+	writeRow(rows[0])
+	sb.WriteString("|" + strings.Repeat(" --- |", width) + "\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	if omitted > 0 {
+		fmt.Fprintf(&sb, "\n(%d more rows not shown)\n", omitted)
+	}
+	return sb.String()
+}
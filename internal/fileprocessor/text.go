@@ -0,0 +1,251 @@
+package fileprocessor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+/// Text detection: text vs binary by content, encodings converted to UTF-8,
+/// and the language of code files for their fences
+
+// BinaryError tells that a file holds binary content, and what kind if known
+type BinaryError struct {
+	Name string
+	Kind string // "ELF executable", "zip archive", "NUL bytes", ...
+}
+
+func (e *BinaryError) Error() string {
+	return fmt.Sprintf("%s is a binary file (%s), not text", e.Name, e.Kind)
+}
+
+// Signatures of common binary files, to name what a rejected file is
+var binarySignatures = []struct {
+	magic string
+	kind  string
+}{
+	{"\x7fELF", "ELF executable"},
+	{"MZ", "Windows executable"},
+	{"\xcf\xfa\xed\xfe", "Mach-O executable"},
+	{"\xce\xfa\xed\xfe", "Mach-O executable"},
+	{"\xca\xfe\xba\xbe", "Java class or Mach-O universal binary"},
+	{"\x00asm", "WebAssembly module"},
+	{"SQLite format 3\x00", "SQLite database"},
+	{"PK\x03\x04", "zip archive"},
+	{"\x1f\x8b", "gzip archive"},
+	{"BZh", "bzip2 archive"},
+	{"\xfd7zXZ\x00", "xz archive"},
+	{"7z\xbc\xaf\x27\x1c", "7z archive"},
+	{"Rar!\x1a\x07", "RAR archive"},
+	{"\x28\xb5\x2f\xfd", "zstd archive"},
+}
+
+// textEncoding returns the encoding of content that is text ("UTF-8",
+// "UTF-16LE", "UTF-16BE" or "Latin-1"), or why it is not text. Only the start
+// of the content needs to be given.
+func textEncoding(head []byte) (string, string) {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xff, 0xfe}):
+		return "UTF-16LE", ""
+	case bytes.HasPrefix(head, []byte{0xfe, 0xff}):
+		return "UTF-16BE", ""
+	}
+	for _, sig := range binarySignatures {
+		if bytes.HasPrefix(head, []byte(sig.magic)) && (sig.magic != "MZ" || bytes.IndexByte(head, 0) >= 0) {
+			return "", sig.kind
+		}
+	}
+
+	if bytes.IndexByte(head, 0) >= 0 {
+		// UTF-16 without byte order mark: ASCII text has every other byte NUL
+		if order := utf16Order(head); order != "" {
+			return order, ""
+		}
+		return "", "NUL bytes"
+	}
+
+	if utf8.Valid(trimPartialRune(head)) {
+		if controlRatio(string(head)) > 0.1 {
+			return "", "control characters"
+		}
+		return "UTF-8", ""
+	}
+	// Not UTF-8: taken for Latin-1 if it reads as text there
+	if controlRatio(latin1(head)) > 0.05 {
+		return "", "not valid UTF-8 nor Latin-1 text"
+	}
+	return "Latin-1", ""
+}
+
+// utf16Order guesses the byte order of UTF-16 text without byte order mark
+// from where its NUL bytes are, "" if it doesn't look like UTF-16
+func utf16Order(head []byte) string {
+	if len(head) < 4 {
+		return ""
+	}
+	even, odd := 0, 0
+	for i, b := range head[:len(head)&^1] {
+		if b == 0 {
+			if i%2 == 0 {
+				even++
+			} else {
+				odd++
+			}
+		}
+	}
+	pairs := len(head) / 2
+	switch {
+	case odd > pairs*3/4 && even == 0:
+		return "UTF-16LE"
+	case even > pairs*3/4 && odd == 0:
+		return "UTF-16BE"
+	}
+	return ""
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence at the end of a cut buffer
+func trimPartialRune(head []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(head); i++ {
+		if r := head[len(head)-i]; utf8.RuneStart(r) {
+			if !utf8.FullRune(head[len(head)-i:]) {
+				return head[:len(head)-i]
+			}
+			break
+		}
+	}
+	return head
+}
+
+// controlRatio returns the share of control characters (tabs, line and page
+// breaks aside) in a text
+func controlRatio(text string) float64 {
+	total, control := 0, 0
+	for _, r := range text {
+		total++
+		if (r < 0x20 && r != '\t' && r != '\n' && r != '\r' && r != '\f' && r != 0x1b) || r == 0x7f || (r >= 0x80 && r < 0xa0) {
+			control++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(control) / float64(total)
+}
+
+func latin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// decodeText converts text content in any encoding textEncoding detects to
+// UTF-8, without byte order mark
+func decodeText(data []byte, encoding string) string {
+	switch encoding {
+	case "UTF-16LE", "UTF-16BE":
+		var order binary.ByteOrder = binary.LittleEndian
+		if encoding == "UTF-16BE" {
+			order = binary.BigEndian
+		}
+		units := make([]uint16, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			units = append(units, order.Uint16(data[i:]))
+		}
+		return strings.TrimPrefix(string(utf16.Decode(units)), "\ufeff")
+	case "Latin-1":
+		return latin1(data)
+	}
+	return strings.TrimPrefix(strings.ToValidUTF8(string(data), "�"), "\ufeff")
+}
+
+// readText decodes the content of a text file to UTF-8, or returns a
+// BinaryError if it isn't text
+func readText(name string, data []byte) (string, error) {
+	encoding, binaryKind := textEncoding(data[:min(len(data), sniffSize)])
+	if binaryKind != "" {
+		return "", &BinaryError{Name: name, Kind: binaryKind}
+	}
+	return decodeText(data, encoding), nil
+}
+
+// Languages of code files for their fences, by extension
+var extensionLanguages = map[string]string{
+	".go": "go", ".py": "python", ".js": "javascript", ".jsx": "jsx", ".ts": "typescript", ".tsx": "tsx",
+	".java": "java", ".kt": "kotlin", ".swift": "swift", ".c": "c", ".h": "c", ".cpp": "cpp", ".cc": "cpp",
+	".hpp": "cpp", ".cs": "csharp", ".rs": "rust", ".rb": "ruby", ".php": "php", ".pl": "perl", ".lua": "lua",
+	".sh": "bash", ".bash": "bash", ".zsh": "zsh", ".fish": "fish", ".ps1": "powershell",
+	".yaml": "yaml", ".yml": "yaml", ".json": "json", ".toml": "toml", ".ini": "ini", ".xml": "xml",
+	".html": "html", ".css": "css", ".scss": "scss", ".sql": "sql", ".proto": "protobuf", ".tf": "hcl",
+	".hcl": "hcl", ".gradle": "groovy", ".groovy": "groovy", ".scala": "scala", ".r": "r", ".dart": "dart",
+	".ex": "elixir", ".exs": "elixir", ".erl": "erlang", ".hs": "haskell", ".ml": "ocaml", ".clj": "clojure",
+	".vue": "vue", ".svelte": "svelte", ".mk": "makefile", ".cmake": "cmake", ".nix": "nix", ".zig": "zig",
+}
+
+// Languages of files known by their name
+var filenameLanguages = map[string]string{
+	"makefile": "makefile", "gnumakefile": "makefile", "dockerfile": "dockerfile", "containerfile": "dockerfile",
+	"jenkinsfile": "groovy", "vagrantfile": "ruby", "gemfile": "ruby", "rakefile": "ruby", "podfile": "ruby",
+	"cmakelists.txt": "cmake", "go.mod": "go.mod", "procfile": "yaml", "justfile": "just",
+	".bashrc": "bash", ".bash_profile": "bash", ".zshrc": "zsh", ".profile": "sh",
+}
+
+// Languages of the interpreters of shebang lines
+var interpreterLanguages = map[string]string{
+	"sh": "sh", "bash": "bash", "zsh": "zsh", "ksh": "sh", "dash": "sh", "fish": "fish",
+	"python": "python", "ruby": "ruby", "perl": "perl", "node": "javascript", "deno": "typescript",
+	"bun": "typescript", "php": "php", "lua": "lua", "Rscript": "r", "pwsh": "powershell", "awk": "awk",
+}
+
+// DetectLanguage returns the language of a code file for its fence, from its
+// name (Makefile, Dockerfile, .env files), its extension, or the shebang line
+// of its content; "" if unknown
+func DetectLanguage(name, content string) string {
+	base := strings.ToLower(filepath.Base(name))
+	if lang, ok := filenameLanguages[base]; ok {
+		return lang
+	}
+	switch {
+	case strings.HasPrefix(base, "dockerfile.") || strings.HasSuffix(base, ".dockerfile"):
+		return "dockerfile"
+	case base == ".env" || strings.HasPrefix(base, ".env."):
+		return "dotenv"
+	}
+	if lang, ok := extensionLanguages[strings.ToLower(filepath.Ext(base))]; ok {
+		return lang
+	}
+	return shebangLanguage(content)
+}
+
+// shebangLanguage returns the language of the interpreter a script names on
+// its first line ("#!/usr/bin/env python3" gives python)
+func shebangLanguage(content string) string {
+	if !strings.HasPrefix(content, "#!") {
+		return ""
+	}
+	line, _, _ := strings.Cut(content[2:], "\n")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		// "#!/usr/bin/env -S deno run" names it after the options
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") {
+				interpreter = field
+				break
+			}
+		}
+	}
+	// python3, python3.12, ruby2.7, ...
+	interpreter = strings.TrimRight(interpreter, "0123456789.")
+	return interpreterLanguages[interpreter]
+}
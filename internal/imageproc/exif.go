@@ -0,0 +1,112 @@
+package imageproc
+
+import (
+	"encoding/binary"
+	"image"
+)
+
+// jpegSegments calls fn with the marker and payload of each segment of a
+// JPEG file before its image data, until fn returns false
+func jpegSegments(data []byte, fn func(marker byte, payload []byte) bool) {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return
+	}
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xff {
+			return
+		}
+		marker := data[pos+1]
+		if marker == 0xda || marker == 0xd9 { // start of scan, end of image
+			return
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return
+		}
+		if !fn(marker, data[pos+4:pos+2+length]) {
+			return
+		}
+		pos += 2 + length
+	}
+}
+
+// exifOrientation returns the EXIF orientation of a JPEG file (1 to 8), 1
+// (upright) if it has none
+func exifOrientation(data []byte) int {
+	orientation := 1
+	jpegSegments(data, func(marker byte, payload []byte) bool {
+		if marker != 0xe1 || len(payload) < 14 || string(payload[:6]) != "Exif\x00\x00" {
+			return true
+		}
+		tiff := payload[6:]
+
+		var order binary.ByteOrder
+		switch string(tiff[:2]) {
+		case "II":
+			order = binary.LittleEndian
+		case "MM":
+			order = binary.BigEndian
+		default:
+			return false
+		}
+
+		ifd := int(order.Uint32(tiff[4:]))
+		if ifd+2 > len(tiff) {
+			return false
+		}
+		entries := int(order.Uint16(tiff[ifd:]))
+		for i := range entries {
+			entry := ifd + 2 + i*12
+			if entry+12 > len(tiff) {
+				break
+			}
+			if order.Uint16(tiff[entry:]) == 0x0112 { // Orientation, a SHORT
+				if value := int(order.Uint16(tiff[entry+8:])); value >= 1 && value <= 8 {
+					orientation = value
+				}
+				break
+			}
+		}
+		return false
+	})
+	return orientation
+}
+
+// orient turns an image upright according to its EXIF orientation
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 { // rotated by a quarter turn
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := range h {
+		for x := range w {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // upside down
+				dx, dy = w-1-x, h-1-y
+			case 4: // upside down, mirrored
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated 90° counterclockwise, turned back clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90° clockwise, turned back counterclockwise
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,190 @@
+package imageproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+
+	// Decoders of the formats converted before sending
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// DefaultQuality is the JPEG quality images are encoded with unless configured
+const DefaultQuality = 85
+
+// MaxPixels caps the size of the images decoded: a small file can declare
+// dimensions that take gigabytes to decode
+const MaxPixels = 100_000_000
+
+// ErrHEIF is returned for HEIC/HEIF images, which no Go decoder reads
+var ErrHEIF = errors.New("HEIC/HEIF images can't be decoded, convert them to JPEG or PNG first (sips, heif-convert or an image editor)")
+
+// Formats accepted as is by vision APIs
+var sendable = map[string]bool{"jpeg": true, "png": true, "gif": true, "webp": true}
+
+// Options of Prepare
+type Options struct {
+	MaxDimension int   // longest side in pixels (0 = any)
+	MaxBytes     int64 // size of the encoded image (0 = any)
+	Quality      int   // JPEG quality, 1-100 (0 = DefaultQuality)
+}
+
+// Prepare readies an image to be sent: scaled down to fit opts, converted to
+// PNG or JPEG when in another format (BMP, TIFF) and stripped of its metadata
+// (EXIF with GPS position, XMP, ...), rotated upright as its EXIF orientation
+// said. An image needing none of this is returned as is. Returns the image and
+// its MIME type.
+func Prepare(data []byte, opts Options) ([]byte, string, error) {
+	if isHEIF(data) {
+		return nil, "", ErrHEIF
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("unsupported or corrupt image: %w", err)
+	}
+
+	fits := (opts.MaxDimension <= 0 || max(cfg.Width, cfg.Height) <= opts.MaxDimension) &&
+		(opts.MaxBytes <= 0 || int64(len(data)) <= opts.MaxBytes)
+	if fits && sendable[format] && !hasMetadata(data, format) {
+		return data, "image/" + format, nil
+	}
+
+	if int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return nil, "", fmt.Errorf("%dx%d image is too large to process (over %d megapixels)", cfg.Width, cfg.Height, MaxPixels/1_000_000)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode %s image: %w", format, err)
+	}
+	img = scale(img, opts.MaxDimension)
+	if format == "jpeg" {
+		img = orient(img, exifOrientation(data))
+	}
+
+	// Lossless sources (screenshots, diagrams) stay lossless when possible
+	lossless := format == "png" || format == "gif" || format == "bmp" || format == "tiff"
+	return encode(img, lossless, opts)
+}
+
+// encode writes an image as PNG (lossless, or when it has transparency) or
+// JPEG, trading quality then size to get under opts.MaxBytes
+func encode(img image.Image, lossless bool, opts Options) ([]byte, string, error) {
+	quality := opts.Quality
+	if quality <= 0 || quality > 100 {
+		quality = DefaultQuality
+	}
+	opaque := isOpaque(img)
+	usePNG := lossless || !opaque
+
+	for {
+		var buf bytes.Buffer
+		var err error
+		if usePNG {
+			err = png.Encode(&buf, img)
+		} else {
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode image: %w", err)
+		}
+		if opts.MaxBytes <= 0 || int64(buf.Len()) <= opts.MaxBytes {
+			if usePNG {
+				return buf.Bytes(), "image/png", nil
+			}
+			return buf.Bytes(), "image/jpeg", nil
+		}
+
+		size := img.Bounds().Size()
+		switch {
+		case usePNG && opaque:
+			usePNG = false
+		case !usePNG && quality > 55:
+			quality -= 15
+		case max(size.X, size.Y) > 256:
+			img = scale(img, max(size.X, size.Y)*3/4)
+		default:
+			return nil, "", fmt.Errorf("image can't be made smaller than %d bytes", opts.MaxBytes)
+		}
+	}
+}
+
+// scale shrinks an image so that its longest side is at most maxDimension
+func scale(img image.Image, maxDimension int) image.Image {
+	size := img.Bounds().Size()
+	longest := max(size.X, size.Y)
+	if maxDimension <= 0 || longest <= maxDimension {
+		return img
+	}
+
+	width := max(1, size.X*maxDimension/longest)
+	height := max(1, size.Y*maxDimension/longest)
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// isOpaque reports whether an image has no transparent pixel
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// isHEIF reports whether data is a HEIC/HEIF/AVIF-like ISO media file
+func isHEIF(data []byte) bool {
+	if len(data) < 12 || string(data[4:8]) != "ftyp" {
+		return false
+	}
+	switch string(data[8:12]) {
+	case "heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1":
+		return true
+	}
+	return false
+}
+
+// hasMetadata reports whether an image carries metadata worth stripping:
+// EXIF, XMP or IPTC segments of JPEG files, text and EXIF chunks of PNG and
+// WebP files
+func hasMetadata(data []byte, format string) bool {
+	switch format {
+	case "jpeg":
+		found := false
+		jpegSegments(data, func(marker byte, _ []byte) bool {
+			// APP1 holds EXIF and XMP, APP13 IPTC
+			found = marker == 0xe1 || marker == 0xed
+			return !found
+		})
+		return found
+	case "png":
+		for pos := 8; pos+8 <= len(data); {
+			length := int(uint32(data[pos])<<24 | uint32(data[pos+1])<<16 | uint32(data[pos+2])<<8 | uint32(data[pos+3]))
+			switch string(data[pos+4 : pos+8]) {
+			case "eXIf", "tEXt", "iTXt", "zTXt":
+				return true
+			case "IDAT", "IEND":
+				return false
+			}
+			pos += 12 + length
+		}
+	case "webp":
+		// RIFF container: "RIFF", size, "WEBP", then chunks (fourcc, little-endian size, data padded to even)
+		for pos := 12; pos+8 <= len(data); {
+			switch string(data[pos : pos+4]) {
+			case "EXIF", "XMP ":
+				return true
+			}
+			length := int(uint32(data[pos+4]) | uint32(data[pos+5])<<8 | uint32(data[pos+6])<<16 | uint32(data[pos+7])<<24)
+			pos += 8 + length + length%2
+		}
+	}
+	return false
+}
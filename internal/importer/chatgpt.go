@@ -0,0 +1,233 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	ctxmanager "github.com/KooQix/term-ai/internal/context"
+	"github.com/KooQix/term-ai/internal/provider"
+)
+
+// ChatGPT export (conversations.json): a list of conversations whose messages
+// form a tree in "mapping", current_node being the leaf of the active branch
+type chatGPTConversation struct {
+	ID          string                 `json:"id"`
+	ConvID      string                 `json:"conversation_id"`
+	Title       string                 `json:"title"`
+	CreateTime  float64                `json:"create_time"`
+	UpdateTime  float64                `json:"update_time"`
+	CurrentNode string                 `json:"current_node"`
+	Mapping     map[string]chatGPTNode `json:"mapping"`
+}
+
+type chatGPTNode struct {
+	ID       string          `json:"id"`
+	Parent   string          `json:"parent"`
+	Children []string        `json:"children"`
+	Message  *chatGPTMessage `json:"message"`
+}
+
+type chatGPTMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+		Text        string            `json:"text"`     // code
+		Language    string            `json:"language"` // code
+	} `json:"content"`
+	Metadata struct {
+		ModelSlug string `json:"model_slug"`
+		Hidden    bool   `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+type chatGPTAsset struct {
+	ContentType  string `json:"content_type"`
+	AssetPointer string `json:"asset_pointer"`
+}
+
+func readChatGPT(data []byte, archive *zip.ReadCloser, allBranches bool) ([]*ctxmanager.Conversation, error) {
+	var exported []chatGPTConversation
+	if err := json.Unmarshal(data, &exported); err != nil {
+		return nil, fmt.Errorf("failed to parse ChatGPT export: %w", err)
+	}
+
+	assets := newAssetResolver(archive)
+
+	var convs []*ctxmanager.Conversation
+	for _, c := range exported {
+		id := c.ID
+		if id == "" {
+			id = c.ConvID
+		}
+
+		t := newTree()
+		// The mapping is a JSON object, walk it from the roots to keep a stable order
+		var visit func(id string)
+		visit = func(id string) {
+			n, ok := c.Mapping[id]
+			if !ok {
+				return
+			}
+			t.add(&node{id: id, parent: n.Parent, messages: chatGPTMessages(n.Message, assets)})
+			for _, child := range n.Children {
+				visit(child)
+			}
+		}
+		var roots []string
+		for nodeID, n := range c.Mapping {
+			if _, ok := c.Mapping[n.Parent]; !ok {
+				roots = append(roots, nodeID)
+			}
+		}
+		slices.Sort(roots)
+		for _, root := range roots {
+			visit(root)
+		}
+
+		meta := ctxmanager.Metadata{
+			Title:     strings.TrimSpace(c.Title),
+			CreatedAt: unixTime(c.CreateTime),
+			UpdatedAt: unixTime(c.UpdateTime),
+			Source:    "chatgpt:" + id,
+		}
+		convs = append(convs, branchConversations(meta, t.branches(c.CurrentNode, allBranches))...)
+	}
+
+	return convs, nil
+}
+
+// chatGPTMessages converts a message node. Hidden, empty and tool messages
+// are dropped (tool outputs have no matching call in the termai format), code
+// the model ran is kept as a fenced block of the assistant reply.
+func chatGPTMessages(msg *chatGPTMessage, assets *assetResolver) []provider.Message {
+	if msg == nil || msg.Metadata.Hidden {
+		return nil
+	}
+
+	var role provider.ContextRole
+	switch msg.Author.Role {
+	case "user":
+		role = provider.RoleUser
+	case "assistant":
+		role = provider.RoleAssistant
+	case "system":
+		role = provider.RoleSystem
+	default:
+		return nil
+	}
+
+	var (
+		text   []string
+		images []string
+	)
+	switch msg.Content.ContentType {
+	case "text", "multimodal_text":
+		for _, part := range msg.Content.Parts {
+			var s string
+			if err := json.Unmarshal(part, &s); err == nil {
+				if s = strings.TrimSpace(s); s != "" {
+					text = append(text, s)
+				}
+				continue
+			}
+			var asset chatGPTAsset
+			if err := json.Unmarshal(part, &asset); err == nil && asset.ContentType == "image_asset_pointer" {
+				if img, ok := assets.image(asset.AssetPointer); ok {
+					images = append(images, img)
+				} else {
+					text = append(text, "[image not included in the export]")
+				}
+			}
+		}
+	case "code":
+		if strings.TrimSpace(msg.Content.Text) != "" {
+			lang := msg.Content.Language
+			if lang == "unknown" {
+				lang = ""
+			}
+			text = append(text, "```"+lang+"\n"+strings.TrimSpace(msg.Content.Text)+"\n```")
+		}
+	default:
+		// Reasoning recaps, browsing state, ...
+		return nil
+	}
+
+	if len(text) == 0 && len(images) == 0 {
+		return nil
+	}
+
+	out := provider.Message{
+		Role:    role,
+		Content: strings.Join(text, "\n\n"),
+		Images:  images,
+	}
+	if created := unixTime(msg.CreateTime); !created.IsZero() || msg.Metadata.ModelSlug != "" {
+		out.Meta = &provider.MessageMeta{CreatedAt: created}
+		if role == provider.RoleAssistant {
+			out.Meta.Model = msg.Metadata.ModelSlug
+		}
+	}
+	return []provider.Message{out}
+}
+
+// unixTime converts the fractional Unix timestamps of ChatGPT exports
+func unixTime(seconds float64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9))
+}
+
+// assetResolver finds the images referenced by "file-service://file-<id>"
+// pointers among the files of an export archive (named "file-<id>-<name>")
+type assetResolver struct {
+	files map[string]*zip.File
+}
+
+func newAssetResolver(archive *zip.ReadCloser) *assetResolver {
+	r := &assetResolver{files: map[string]*zip.File{}}
+	if archive == nil {
+		return r
+	}
+	for _, file := range archive.File {
+		name := filepath.Base(file.Name)
+		if !strings.HasPrefix(name, "file-") || !strings.HasPrefix(mime.TypeByExtension(filepath.Ext(name)), "image/") {
+			continue
+		}
+		r.files[name] = file
+	}
+	return r
+}
+
+// image returns the pointed image as a data URL
+func (r *assetResolver) image(pointer string) (string, bool) {
+	_, id, ok := strings.Cut(pointer, "://")
+	if !ok || id == "" {
+		return "", false
+	}
+
+	for name, file := range r.files {
+		if name != id && !strings.HasPrefix(name, id+"-") && !strings.HasPrefix(name, id+".") {
+			continue
+		}
+		data, err := readZipFile(file)
+		if err != nil {
+			return "", false
+		}
+		return "data:" + mime.TypeByExtension(filepath.Ext(name)) + ";base64," + base64.StdEncoding.EncodeToString(data), true
+	}
+	return "", false
+}
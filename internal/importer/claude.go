@@ -0,0 +1,113 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	ctxmanager "github.com/KooQix/term-ai/internal/context"
+	"github.com/KooQix/term-ai/internal/provider"
+)
+
+// Claude.ai export (conversations.json): a list of conversations with their
+// messages in order. Newer exports link each message to its parent, which is
+// how edited prompts and retried responses form branches.
+type claudeConversation struct {
+	UUID        string          `json:"uuid"`
+	Name        string          `json:"name"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	CurrentLeaf string          `json:"current_leaf_message_uuid"`
+	Messages    []claudeMessage `json:"chat_messages"`
+}
+
+type claudeMessage struct {
+	UUID      string    `json:"uuid"`
+	Parent    string    `json:"parent_message_uuid"`
+	Sender    string    `json:"sender"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+	Content   []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Attachments []struct {
+		FileName         string `json:"file_name"`
+		ExtractedContent string `json:"extracted_content"`
+	} `json:"attachments"`
+}
+
+func readClaude(data []byte, allBranches bool) ([]*ctxmanager.Conversation, error) {
+	var exported []claudeConversation
+	if err := json.Unmarshal(data, &exported); err != nil {
+		return nil, fmt.Errorf("failed to parse Claude export: %w", err)
+	}
+
+	var convs []*ctxmanager.Conversation
+	for _, c := range exported {
+		t := newTree()
+		previous := ""
+		for _, msg := range c.Messages {
+			// Older exports only have the active path, in order
+			parent := msg.Parent
+			if parent == "" {
+				parent = previous
+			}
+			t.add(&node{id: msg.UUID, parent: parent, messages: claudeMessages(msg)})
+			previous = msg.UUID
+		}
+
+		meta := ctxmanager.Metadata{
+			Title:     strings.TrimSpace(c.Name),
+			CreatedAt: c.CreatedAt,
+			UpdatedAt: c.UpdatedAt,
+			Source:    "claude:" + c.UUID,
+		}
+		convs = append(convs, branchConversations(meta, t.branches(c.CurrentLeaf, allBranches))...)
+	}
+
+	return convs, nil
+}
+
+// claudeMessages converts a message. Extracted attachment text is appended to
+// the prompt; thinking and tool use are dropped, as termai doesn't keep them.
+func claudeMessages(msg claudeMessage) []provider.Message {
+	var role provider.ContextRole
+	switch msg.Sender {
+	case "human":
+		role = provider.RoleUser
+	case "assistant":
+		role = provider.RoleAssistant
+	default:
+		return nil
+	}
+
+	var parts []string
+	for _, block := range msg.Content {
+		if block.Type == "text" {
+			parts = append(parts, block.Text)
+		}
+	}
+	if len(msg.Content) == 0 {
+		parts = append(parts, msg.Text)
+	}
+
+	for _, attachment := range msg.Attachments {
+		if strings.TrimSpace(attachment.ExtractedContent) == "" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("File: %s\n```\n%s\n```", attachment.FileName, strings.TrimSpace(attachment.ExtractedContent)))
+	}
+
+	content := strings.TrimSpace(strings.Join(parts, "\n\n"))
+	if content == "" {
+		return nil
+	}
+
+	out := provider.Message{Role: role, Content: content}
+	if !msg.CreatedAt.IsZero() {
+		out.Meta = &provider.MessageMeta{CreatedAt: msg.CreatedAt}
+	}
+	return []provider.Message{out}
+}
@@ -0,0 +1,282 @@
+package importer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	ctxmanager "github.com/KooQix/term-ai/internal/context"
+	"github.com/KooQix/term-ai/internal/provider"
+)
+
+// Source is the service a data export comes from
+type Source string
+
+const (
+	SourceAuto        Source = "auto"
+	SourceChatGPT     Source = "chatgpt"
+	SourceClaude      Source = "claude"
+	SourceOpenAIJSONL Source = "openai-jsonl"
+)
+
+// ParseSource validates a source name
+func ParseSource(name string) (Source, error) {
+	switch s := Source(strings.ToLower(name)); s {
+	case SourceAuto, SourceChatGPT, SourceClaude, SourceOpenAIJSONL:
+		return s, nil
+	case "":
+		return SourceAuto, nil
+	}
+	return "", fmt.Errorf("unknown import source '%s' (use chatgpt, claude or openai-jsonl)", name)
+}
+
+// Options controls how an export is converted
+type Options struct {
+	Source      Source
+	AllBranches bool // one conversation per branch instead of the active path only
+}
+
+// Read converts a data export into termai conversations. The file can be the
+// conversations.json of a ChatGPT or Claude export, the export zip itself, or
+// an OpenAI chat JSONL file (one {"messages": [...]} object per line).
+// Conversations without any message are dropped.
+func Read(path string, opts Options) ([]*ctxmanager.Conversation, Source, error) {
+	data, archive, err := readExport(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if archive != nil {
+		defer archive.Close()
+	}
+
+	source := opts.Source
+	if source == SourceAuto || source == "" {
+		if source, err = detect(data); err != nil {
+			return nil, "", err
+		}
+	}
+
+	var convs []*ctxmanager.Conversation
+	switch source {
+	case SourceChatGPT:
+		convs, err = readChatGPT(data, archive, opts.AllBranches)
+	case SourceClaude:
+		convs, err = readClaude(data, opts.AllBranches)
+	case SourceOpenAIJSONL:
+		convs, err = readJSONL(data)
+	default:
+		err = fmt.Errorf("unknown import source '%s'", source)
+	}
+	if err != nil {
+		return nil, source, err
+	}
+
+	kept := convs[:0]
+	for _, conv := range convs {
+		if len(conv.Messages) > 0 {
+			kept = append(kept, conv)
+		}
+	}
+	return kept, source, nil
+}
+
+// readExport returns the conversations data of an export. For a zip archive,
+// that is its conversations.json, and the archive is returned so attachments
+// can be looked up.
+func readExport(path string) ([]byte, *zip.ReadCloser, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read export: %w", err)
+	}
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return data, nil, nil
+	}
+
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open export archive: %w", err)
+	}
+	for _, file := range archive.File {
+		if filepath.Base(file.Name) != "conversations.json" {
+			continue
+		}
+		data, err := readZipFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		return data, archive, nil
+	}
+	return nil, nil, fmt.Errorf("no conversations.json found in '%s'", path)
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s' from archive: %w", file.Name, err)
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// detect guesses the source of an export from the shape of its first conversation
+func detect(data []byte) (Source, error) {
+	trimmed := bytes.TrimSpace(data)
+
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		var probe []map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &probe); err == nil && len(probe) > 0 {
+			if _, ok := probe[0]["mapping"]; ok {
+				return SourceChatGPT, nil
+			}
+			if _, ok := probe[0]["chat_messages"]; ok {
+				return SourceClaude, nil
+			}
+		}
+	}
+
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		line, _, _ := bufio.NewReader(bytes.NewReader(trimmed)).ReadLine()
+		var probe map[string]json.RawMessage
+		if err := json.Unmarshal(line, &probe); err == nil {
+			if _, ok := probe["messages"]; ok {
+				return SourceOpenAIJSONL, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("unrecognized export format, use --from chatgpt, claude or openai-jsonl")
+}
+
+//////////////////// Message trees \\\\\\\\\\\\\\\\\\\\
+
+// ChatGPT and Claude store conversations as trees: editing a message or
+// regenerating a response starts a new branch. tree collects the nodes in
+// export order so the branches come out in a stable order.
+type tree struct {
+	nodes    map[string]*node
+	order    []string
+	children map[string][]string
+}
+
+type node struct {
+	id       string
+	parent   string
+	messages []provider.Message // none for structural nodes (roots, hidden messages)
+}
+
+func newTree() *tree {
+	return &tree{nodes: map[string]*node{}, children: map[string][]string{}}
+}
+
+func (t *tree) add(n *node) {
+	if _, ok := t.nodes[n.id]; ok {
+		return
+	}
+	t.nodes[n.id] = n
+	t.order = append(t.order, n.id)
+	t.children[n.parent] = append(t.children[n.parent], n.id)
+}
+
+// path returns the messages from the root down to leaf
+func (t *tree) path(leaf string) []provider.Message {
+	var chain []*node
+	seen := map[string]bool{}
+	for id := leaf; id != "" && !seen[id]; {
+		n, ok := t.nodes[id]
+		if !ok {
+			break
+		}
+		seen[id] = true
+		chain = append(chain, n)
+		id = n.parent
+	}
+
+	var messages []provider.Message
+	for i := len(chain) - 1; i >= 0; i-- {
+		messages = append(messages, chain[i].messages...)
+	}
+	return mergeConsecutive(messages)
+}
+
+// leaves returns the nodes without children, in export order
+func (t *tree) leaves() []string {
+	var leaves []string
+	for _, id := range t.order {
+		if len(t.children[id]) == 0 {
+			leaves = append(leaves, id)
+		}
+	}
+	return leaves
+}
+
+// branches returns the message lists to import: the path to active only, or
+// every branch with the active one first
+func (t *tree) branches(active string, all bool) [][]provider.Message {
+	if active == "" || t.nodes[active] == nil {
+		if leaves := t.leaves(); len(leaves) > 0 {
+			active = leaves[len(leaves)-1]
+		}
+	}
+
+	branches := [][]provider.Message{t.path(active)}
+	if !all {
+		return branches
+	}
+	for _, leaf := range t.leaves() {
+		if leaf != active {
+			if messages := t.path(leaf); len(messages) > 0 {
+				branches = append(branches, messages)
+			}
+		}
+	}
+	return branches
+}
+
+// mergeConsecutive joins consecutive messages of the same role (e.g. an
+// assistant reply split around a tool call), so roles alternate as providers expect
+func mergeConsecutive(messages []provider.Message) []provider.Message {
+	var merged []provider.Message
+	for _, msg := range messages {
+		if last := len(merged) - 1; last >= 0 && merged[last].Role == msg.Role && msg.Role != provider.RoleSystem {
+			merged[last].Content = strings.TrimSpace(merged[last].Content + "\n\n" + msg.Content)
+			merged[last].Images = append(merged[last].Images, msg.Images...)
+			continue
+		}
+		// Nodes are shared between branches, don't let merging alias their images
+		msg.Images = slices.Clone(msg.Images)
+		merged = append(merged, msg)
+	}
+	return merged
+}
+
+// branchConversations builds one conversation per branch, numbering the
+// titles of the alternative ones
+func branchConversations(base ctxmanager.Metadata, branches [][]provider.Message) []*ctxmanager.Conversation {
+	var convs []*ctxmanager.Conversation
+	for i, messages := range branches {
+		meta := base
+		if i > 0 {
+			meta.Title = fmt.Sprintf("%s (branch %d)", base.Title, i+1)
+			meta.Source = fmt.Sprintf("%s#%d", base.Source, i+1)
+		}
+		meta.Model = lastModel(messages, base.Model)
+		convs = append(convs, &ctxmanager.Conversation{Metadata: meta, Messages: messages})
+	}
+	return convs
+}
+
+func lastModel(messages []provider.Message, fallback string) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if meta := messages[i].Meta; meta != nil && meta.Model != "" {
+			return meta.Model
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,119 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	ctxmanager "github.com/KooQix/term-ai/internal/context"
+	"github.com/KooQix/term-ai/internal/provider"
+)
+
+// jsonlTitleLen is the length of the title derived from the first prompt
+const jsonlTitleLen = 60
+
+// OpenAI chat JSONL (fine-tuning and batch datasets): one conversation per
+// line, as {"messages": [{"role": ..., "content": ...}]}. Content is either a
+// string or a list of parts.
+type jsonlConversation struct {
+	Messages []struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	} `json:"messages"`
+}
+
+type jsonlPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	ImageURL struct {
+		URL string `json:"url"`
+	} `json:"image_url"`
+}
+
+func readJSONL(data []byte) ([]*ctxmanager.Conversation, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	var convs []*ctxmanager.Conversation
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var c jsonlConversation
+		if err := json.Unmarshal(line, &c); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		// Lines have no ID: they are recognized by their content, whatever file they come from
+		sum := sha256.Sum256(line)
+		conv := &ctxmanager.Conversation{
+			Metadata: ctxmanager.Metadata{Source: "openai-jsonl:" + hex.EncodeToString(sum[:12])},
+		}
+		for _, msg := range c.Messages {
+			role := provider.ContextRole(msg.Role)
+			if role == "developer" {
+				role = provider.RoleSystem
+			}
+			if role != provider.RoleUser && role != provider.RoleAssistant && role != provider.RoleSystem {
+				continue
+			}
+
+			content, images := jsonlContent(msg.Content)
+			if content == "" && len(images) == 0 {
+				continue
+			}
+			conv.Messages = append(conv.Messages, provider.Message{Role: role, Content: content, Images: images})
+
+			if role == provider.RoleUser && conv.Metadata.Title == "" {
+				conv.Metadata.Title = firstLine(content, jsonlTitleLen)
+			}
+		}
+
+		convs = append(convs, conv)
+	}
+
+	return convs, scanner.Err()
+}
+
+func jsonlContent(raw json.RawMessage) (string, []string) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return strings.TrimSpace(text), nil
+	}
+
+	var parts []jsonlPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", nil
+	}
+
+	var (
+		texts  []string
+		images []string
+	)
+	for _, part := range parts {
+		switch part.Type {
+		case "text":
+			texts = append(texts, part.Text)
+		case "image_url":
+			if part.ImageURL.URL != "" {
+				images = append(images, part.ImageURL.URL)
+			}
+		}
+	}
+	return strings.TrimSpace(strings.Join(texts, "\n\n")), images
+}
+
+// firstLine returns the first line of text, cut to at most n runes
+func firstLine(text string, n int) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	if runes := []rune(line); len(runes) > n {
+		line = strings.TrimSpace(string(runes[:n])) + "…"
+	}
+	return line
+}
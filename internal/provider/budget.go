@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/KooQix/term-ai/internal/config"
+)
+
+// maxSummarizeInput caps how much of an oversized tool result is handed to the
+// summarizing profile, so a runaway result can't blow that model's window too.
+const maxSummarizeInput = 256 * 1024
+
+// resultBudget caps the size of tool results handed back to the model.
+// One budget covers a single turn: every tool call made while answering the
+// same user message draws from the same per-turn allowance.
+type resultBudget struct {
+	cfg  config.ToolsConfig
+	used int
+}
+
+func newResultBudget() *resultBudget {
+	b := &resultBudget{}
+	if config.AppConfig != nil {
+		b.cfg = config.AppConfig.ToolConfigs
+	}
+	return b
+}
+
+// limitFor returns the number of bytes the next result of the given tool may
+// use, or -1 when unlimited
+func (b *resultBudget) limitFor(name string) int {
+	limit := b.cfg.MaxResultBytes
+	if l, ok := b.cfg.ResultLimits[name]; ok {
+		limit = l
+	}
+
+	if b.cfg.MaxTurnBytes > 0 {
+		remaining := max(b.cfg.MaxTurnBytes-b.used, 0)
+		if limit <= 0 || remaining < limit {
+			limit = remaining
+		}
+	} else if limit <= 0 {
+		return -1
+	}
+
+	return limit
+}
+
+// apply fits a tool result into the budget. It returns the result to hand back
+// to the model and a short note describing how it was reduced ("" if untouched)
+func (b *resultBudget) apply(ctx context.Context, name, result string) (string, string) {
+	limit := b.limitFor(name)
+	if limit < 0 || len(result) <= limit {
+		b.used += len(result)
+		return result, ""
+	}
+
+	if limit == 0 {
+		return fmt.Sprintf("[termai: %d byte result of %s omitted, the per-turn tool result budget is exhausted]", len(result), name),
+			fmt.Sprintf("omitted (%d bytes, turn budget exhausted)", len(result))
+	}
+
+	// Prefer a summary from the secondary profile when one is configured
+	if b.cfg.SummarizeProfile != "" {
+		summary, err := summarizeResult(ctx, b.cfg.SummarizeProfile, name, result, limit)
+		if err == nil && summary != "" && len(summary) <= limit {
+			b.used += len(summary)
+			return summary, fmt.Sprintf("summarized by '%s' (%d → %d bytes)", b.cfg.SummarizeProfile, len(result), len(summary))
+		}
+	}
+
+	reduced := truncateResult(result, limit)
+	b.used += len(reduced)
+	return reduced, fmt.Sprintf("truncated (%d → %d bytes)", len(result), len(reduced))
+}
+
+// truncateResult cuts result so that it fits in limit bytes including the
+// trailing marker, without splitting a UTF-8 sequence
+func truncateResult(result string, limit int) string {
+	const markerFormat = "\n[termai: result truncated, %d of %d bytes omitted]"
+
+	// Size the marker for the worst case so the final string never exceeds limit
+	keep := limit - len(fmt.Sprintf(markerFormat, len(result), len(result)))
+	if keep <= 0 {
+		return strings.TrimPrefix(fmt.Sprintf(markerFormat, len(result), len(result)), "\n")
+	}
+
+	for keep > 0 && !utf8.RuneStart(result[keep]) {
+		keep--
+	}
+
+	return result[:keep] + fmt.Sprintf(markerFormat, len(result)-keep, len(result))
+}
+
+// summarizeResult asks the given profile to condense a tool result to fit in
+// limit bytes
+func summarizeResult(ctx context.Context, profileName, toolName, result string, limit int) (string, error) {
+	if config.AppConfig == nil {
+		return "", fmt.Errorf("config not loaded")
+	}
+
+	profile, err := config.AppConfig.GetProfile(profileName)
+	if err != nil {
+		return "", err
+	}
+
+	if len(result) > maxSummarizeInput {
+		result = truncateResult(result, maxSummarizeInput)
+	}
+
+	prompt := fmt.Sprintf("The following is the output of the `%s` tool. Summarize it in at most %d characters. "+
+		"Keep every fact, identifier, number and URL the caller may need; drop boilerplate. Reply with the summary only.",
+		toolName, limit)
+
+	summary, err := NewFromProfile(profile).Complete(ctx, []Message{
+		{Role: RoleSystem, Content: prompt},
+		{Role: RoleUser, Content: result},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	summary = strings.TrimSpace(summary)
+	return "[termai: result summarized]\n" + summary, nil
+}
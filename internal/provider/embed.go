@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// Embedder is implemented by providers able to turn texts into vectors
+// (semantic search, retrieval). Use it through a type assertion on a
+// Provider, or get one for a profile with NewEmbedder.
+type Embedder interface {
+	// Embed returns one vector per text, in order. Texts are sent in
+	// batches, each text being cut to MaxEmbedInputBytes.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Limits of the embedding requests
+const (
+	MaxEmbedBatch      = 64         // texts per request
+	MaxEmbedBatchBytes = 512 * 1024 // bytes of text per request
+	MaxEmbedInputBytes = 16 * 1024  // bytes kept of each text (about 4k tokens)
+)
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// embeddingResponse is the response of OpenAI's /embeddings
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// ollamaEmbedResponse is the response of Ollama's /api/embed
+type ollamaEmbedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// Embed implements Embedder with the embedding model of the profile, through
+// the native /api/embed endpoint for Ollama and /embeddings otherwise
+func (p *OpenAICompatible) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if p.EmbeddingModel == "" {
+		return nil, fmt.Errorf("no embedding model configured (set embedding_model in the profile)")
+	}
+
+	vectors := make([][]float32, 0, len(texts))
+	for _, batch := range embedBatches(texts) {
+		var (
+			batchVectors [][]float32
+			err          error
+		)
+		if p.Kind == "ollama" {
+			batchVectors, err = p.embedOllama(ctx, batch)
+		} else {
+			batchVectors, err = p.embedOpenAI(ctx, batch)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(batchVectors) != len(batch) {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", len(batch), len(batchVectors))
+		}
+		vectors = append(vectors, batchVectors...)
+	}
+	return vectors, nil
+}
+
+func (p *OpenAICompatible) embedOpenAI(ctx context.Context, batch []string) ([][]float32, error) {
+	var resp embeddingResponse
+	url := strings.TrimSuffix(p.Endpoint, "/") + "/embeddings"
+	if err := p.postJSON(ctx, url, embeddingRequest{Model: p.EmbeddingModel, Input: batch}, &resp); err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(batch))
+	for _, item := range resp.Data {
+		if item.Index < 0 || item.Index >= len(vectors) {
+			return nil, fmt.Errorf("embedding index %d out of range", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	return vectors, nil
+}
+
+func (p *OpenAICompatible) embedOllama(ctx context.Context, batch []string) ([][]float32, error) {
+	var resp ollamaEmbedResponse
+	// The profile endpoint is the OpenAI-compatible one (http://host:11434/v1)
+	base := strings.TrimSuffix(strings.TrimSuffix(p.Endpoint, "/"), "/v1")
+	if err := p.postJSON(ctx, base+"/api/embed", embeddingRequest{Model: p.EmbeddingModel, Input: batch}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Embeddings, nil
+}
+
+// postJSON sends a JSON request and decodes the JSON response
+func (p *OpenAICompatible) postJSON(ctx context.Context, url string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.APIKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API %d: %s", resp.StatusCode, body)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode embeddings: %w", err)
+	}
+	return nil
+}
+
+// embedBatches cuts texts to MaxEmbedInputBytes and groups them in batches
+// of at most MaxEmbedBatch texts and MaxEmbedBatchBytes bytes
+func embedBatches(texts []string) [][]string {
+	var (
+		batches [][]string
+		batch   []string
+		size    int
+	)
+	for _, text := range texts {
+		if len(text) > MaxEmbedInputBytes {
+			text = strings.ToValidUTF8(text[:MaxEmbedInputBytes], "")
+		}
+		if text == "" || !utf8.ValidString(text) {
+			text = strings.ToValidUTF8(text, "") + " " // empty inputs are rejected by some APIs
+		}
+
+		if len(batch) > 0 && (len(batch) == MaxEmbedBatch || size+len(text) > MaxEmbedBatchBytes) {
+			batches = append(batches, batch)
+			batch, size = nil, 0
+		}
+		batch = append(batch, text)
+		size += len(text)
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
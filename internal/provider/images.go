@@ -0,0 +1,175 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/KooQix/term-ai/internal/config"
+	"github.com/KooQix/term-ai/internal/imageproc"
+)
+
+// ImageLimits are the largest images a provider accepts
+type ImageLimits struct {
+	MaxDimension int   // longest side in pixels
+	MaxBytes     int64 // size of the image, before base64
+}
+
+// Limits of the images of each provider. Claude caps the base64 data at 5MB
+// and gains nothing from images over 1568 pixels.
+var providerImageLimits = map[string]ImageLimits{
+	"openai": {MaxDimension: 2048, MaxBytes: 20 << 20},
+	"claude": {MaxDimension: 1568, MaxBytes: 5 << 20 * 3 / 4},
+}
+
+var defaultImageLimits = ImageLimits{MaxDimension: 2048, MaxBytes: 20 << 20}
+
+// ImageLimitsOf returns the image limits of a provider
+func ImageLimitsOf(kind string) ImageLimits {
+	if limits, ok := providerImageLimits[strings.ToLower(kind)]; ok {
+		return limits
+	}
+	return defaultImageLimits
+}
+
+// withoutPageImages returns the messages without the images of the pages of
+// their attachments, for models that don't read images, telling in their
+// place that these pages can't be read. Messages are copied only when they
+// have some.
+func withoutPageImages(messages []Message) []Message {
+	stripped, copied := messages, false
+	for i, msg := range messages {
+		if !slices.ContainsFunc(msg.Attachments, func(a Attachment) bool { return len(a.PageImages) > 0 }) {
+			continue
+		}
+		if !copied {
+			stripped, copied = slices.Clone(messages), true
+		}
+
+		attachments := slices.Clone(msg.Attachments)
+		for j, a := range attachments {
+			if len(a.PageImages) == 0 {
+				continue
+			}
+			pages := make([]string, len(a.PageImages))
+			for k, page := range a.PageImages {
+				pages[k] = strconv.Itoa(page.Page)
+			}
+			which := "Page " + pages[0] + " has"
+			if len(pages) > 1 {
+				which = "Pages " + strings.Join(pages, ", ") + " have"
+			}
+			attachments[j].Content += fmt.Sprintf("\n\n[%s no text and this model can't read images: left out]", which)
+			attachments[j].PageImages = nil
+		}
+		stripped[i].Attachments = attachments
+	}
+	return stripped
+}
+
+// Images already fitted, by hash of their data URL and limits: the history
+// sends the same images again on every turn
+var (
+	fittedImagesMu sync.Mutex
+	fittedImages   = map[[sha256.Size]byte]string{}
+)
+
+// fitImages returns the messages with their images brought within the limits
+// of the provider, scaled down or re-encoded. Messages are copied only when
+// one of their images changes. Images given by URL are left to the provider.
+func (p *OpenAICompatible) fitImages(messages []Message) ([]Message, error) {
+	limits := ImageLimitsOf(p.Kind)
+	fitted, copied := messages, false
+	for i, msg := range messages {
+		changed := false
+
+		images := slices.Clone(msg.Images)
+		for j, url := range images {
+			fit, err := fitImage(url, limits)
+			if err != nil {
+				return nil, fmt.Errorf("image %d of message %d: %w", j+1, i+1, err)
+			}
+			changed = changed || fit != url
+			images[j] = fit
+		}
+
+		attachments := slices.Clone(msg.Attachments)
+		for j, a := range attachments {
+			if a.IsImage() {
+				fit, err := fitImage(a.Content, limits)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", a.Name, err)
+				}
+				if fit != a.Content {
+					changed = true
+					attachments[j].Content = fit
+					attachments[j].MimeType, _, _ = strings.Cut(strings.TrimPrefix(fit, "data:"), ";")
+				}
+			}
+
+			pages := slices.Clone(a.PageImages)
+			for k, page := range pages {
+				fit, err := fitImage(page.URL, limits)
+				if err != nil {
+					return nil, fmt.Errorf("%s, page %d: %w", a.Name, page.Page, err)
+				}
+				changed = changed || fit != page.URL
+				pages[k].URL = fit
+			}
+			attachments[j].PageImages = pages
+		}
+
+		if changed {
+			if !copied {
+				fitted, copied = slices.Clone(messages), true
+			}
+			fitted[i].Images = images
+			fitted[i].Attachments = attachments
+		}
+	}
+	return fitted, nil
+}
+
+// fitImage brings the image of a base64 data URL within limits, returning
+// the URL as is when it already fits
+func fitImage(url string, limits ImageLimits) (string, error) {
+	header, encoded, ok := strings.Cut(url, ",")
+	if !ok || !strings.HasPrefix(header, "data:") || !strings.HasSuffix(header, ";base64") {
+		return url, nil
+	}
+
+	key := sha256.Sum256(fmt.Appendf(nil, "%d:%d:%s", limits.MaxDimension, limits.MaxBytes, url))
+	fittedImagesMu.Lock()
+	fit, ok := fittedImages[key]
+	fittedImagesMu.Unlock()
+	if ok {
+		return fit, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid image data: %w", err)
+	}
+	opts := imageproc.Options{MaxDimension: limits.MaxDimension, MaxBytes: limits.MaxBytes}
+	if config.AppConfig != nil {
+		opts.Quality = config.AppConfig.Files.ImageQuality
+	}
+	prepared, mimeType, err := imageproc.Prepare(data, opts)
+	if err != nil {
+		return "", err
+	}
+
+	fit = url
+	if !bytes.Equal(prepared, data) || header != "data:"+mimeType+";base64" {
+		fit = fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(prepared))
+	}
+	fittedImagesMu.Lock()
+	fittedImages[key] = fit
+	fittedImagesMu.Unlock()
+	return fit, nil
+}
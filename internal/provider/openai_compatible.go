@@ -22,6 +22,10 @@ type OpenAICompatible struct {
 	Temperature float64
 	MaxTokens   int
 	TopP        float64
+
+	Kind           string // provider of the profile (openai, ollama, ...)
+	EmbeddingModel string // model used by Embed, "" when the profile has none
+	Vision         bool   // whether the model reads images, else pages sent as images are left out
 }
 
 type chatRequest struct {
@@ -71,22 +75,49 @@ func NewOpenAICompatible(endpoint, apiKey, model string, temperature float64, ma
 	}
 }
 
+// NewFromProfile creates a provider configured from a profile
+func NewFromProfile(profile *config.Profile) *OpenAICompatible {
+	p := NewOpenAICompatible(
+		profile.Endpoint,
+		profile.APIKey,
+		profile.Model,
+		profile.Temperature,
+		profile.MaxTokens,
+		profile.TopP,
+	)
+	p.Kind = profile.Provider
+	p.EmbeddingModel = profile.EmbeddingModel
+	p.Vision = profile.SupportsVision()
+	return p
+}
+
+// NewEmbedder returns the embedder of a profile, an error if it has no embedding model
+func NewEmbedder(profile *config.Profile) (Embedder, error) {
+	if profile.EmbeddingModel == "" {
+		return nil, fmt.Errorf("profile '%s' has no embedding_model", profile.Name)
+	}
+	return NewFromProfile(profile), nil
+}
+
 // formatMessages converts Message structs to the appropriate format for the API
 func formatMessages(messages []Message) []interface{} {
 	formatted := make([]interface{}, len(messages))
 
 	for i, msg := range messages {
+		// Attached files are rendered into the content sent
+		text, images := msg.RequestContent()
+
 		// If the message has images, use the content array format
-		if len(msg.Images) > 0 {
+		if len(images) > 0 {
 			content := []contentPart{
 				{
 					Type: "text",
-					Text: msg.Content,
+					Text: text,
 				},
 			}
 
 			// Add images
-			for _, imgURL := range msg.Images {
+			for _, imgURL := range images {
 				content = append(content, contentPart{
 					Type: "image_url",
 					ImageURL: &imageURL{
@@ -100,7 +131,10 @@ func formatMessages(messages []Message) []interface{} {
 				Content: content,
 			}
 		} else {
-			// No images, use simple message format
+			// No images, use simple message format (without local metadata)
+			msg.Content = text
+			msg.Attachments = nil
+			msg.Meta = nil
 			formatted[i] = msg
 		}
 	}
@@ -144,6 +178,13 @@ func (p *OpenAICompatible) chatMessage(messages []Message, stream bool) chatRequ
 
 // send dispatches the chat request and returns the raw response
 func (p *OpenAICompatible) send(ctx context.Context, messages []Message, stream bool) (*http.Response, error) {
+	if !p.Vision {
+		messages = withoutPageImages(messages)
+	}
+	messages, err := p.fitImages(messages)
+	if err != nil {
+		return nil, err
+	}
 	chatReq := p.chatMessage(messages, stream)
 	return p.request(ctx, chatReq)
 }
@@ -259,6 +300,8 @@ func (p *OpenAICompatible) Stream(ctx context.Context, messages []Message) (<-ch
 	go func() {
 		defer close(out)
 
+		budget := newResultBudget()
+
 		for iter := 0; iter < config.AppConfig.ToolConfigs.MaxIter; iter++ {
 			finished, toolCalls, err := p.streamOnce(ctx, messages, out)
 			if err != nil {
@@ -295,10 +338,13 @@ func (p *OpenAICompatible) Stream(ctx context.Context, messages []Message) (<-ch
 					result = fmt.Sprintf(`{"error": %q}`, err.Error())
 				}
 
+				result, reduced := budget.apply(ctx, tc.Function.Name, result)
+
 				out <- StreamChunk{ToolCall: &tools.ToolCallEvent{
-					Name:   tc.Function.Name,
-					Args:   tc.Function.Arguments,
-					Result: result,
+					Name:    tc.Function.Name,
+					Args:    tc.Function.Arguments,
+					Result:  result,
+					Reduced: reduced,
 				}}
 
 				messages = append(messages, Message{
@@ -335,6 +381,8 @@ func (p *OpenAICompatible) Complete(ctx context.Context, messages []Message) (st
 
 // CompleteWithTools runs the full tool-execution loop.
 func (p *OpenAICompatible) CompleteWithTools(ctx context.Context, messages []Message) (string, []Message, error) {
+	budget := newResultBudget()
+
 	for i := 0; i < config.AppConfig.ToolConfigs.MaxIter; i++ {
 		res, err := p.send(ctx, messages, false)
 		if err != nil {
@@ -362,6 +410,7 @@ func (p *OpenAICompatible) CompleteWithTools(ctx context.Context, messages []Mes
 			if err != nil {
 				result = fmt.Sprintf(`{"error": %q}`, err.Error())
 			}
+			result, _ = budget.apply(ctx, tc.Function.Name, result)
 
 			messages = append(messages, Message{
 				Role:       RoleTool,
@@ -2,6 +2,10 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/KooQix/term-ai/internal/tools"
 )
@@ -23,6 +27,99 @@ type Message struct {
 	ToolCalls  []tools.ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string           `json:"tool_call_id,omitempty"` // for role="tool"
 	Name       string           `json:"name,omitempty"`         // optional, tool name
+
+	Attachments []Attachment `json:"attachments,omitempty"` // files sent with the message, apart from the text typed
+
+	Meta *MessageMeta `json:"meta,omitempty"` // local bookkeeping, stripped before sending to the API
+}
+
+// Attachment is a file sent with a message. It is stored apart from the
+// message text and only rendered into the request (see RequestContent).
+type Attachment struct {
+	Name     string `json:"name"`
+	Path     string `json:"path,omitempty"`
+	Type     string `json:"type"`                // "image", "text", "code", "pdf", "document", "spreadsheet", "slides" or "notebook"
+	MimeType string `json:"mime_type,omitempty"` // images only
+	Content  string `json:"content"`             // text, or a data URL for images
+	Language string `json:"language,omitempty"`  // language of code, fenced with it in requests
+
+	PageImages []PageImage `json:"page_images,omitempty"` // pages without text (scans), sent as images to models reading them
+}
+
+// PageImage is the image of a page of a document that has no text to extract
+type PageImage struct {
+	Page int    `json:"page"`
+	URL  string `json:"url"` // data URL
+}
+
+// IsImage reports whether the attachment is an image
+func (a Attachment) IsImage() bool {
+	return a.Type == "image"
+}
+
+// RequestContent returns the text and images of a message as sent to the
+// model: the text followed by the content of the attached files, and the
+// images along with the attached ones
+func (m Message) RequestContent() (string, []string) {
+	if len(m.Attachments) == 0 {
+		return m.Content, m.Images
+	}
+
+	var sb strings.Builder
+	sb.WriteString(m.Content)
+	for _, a := range m.Attachments {
+		if !a.IsImage() {
+			fmt.Fprintf(&sb, "\n\n--- Content from %s ---\n%s\n--- End of %s ---", a.Name, a.fencedContent(), a.Name)
+		}
+	}
+	return sb.String(), m.AllImages()
+}
+
+// fencedContent returns the content of a code attachment in a fence naming
+// its language, longer than any backtick run of the code; other content as is
+func (a Attachment) fencedContent() string {
+	if a.Language == "" {
+		return a.Content
+	}
+
+	longest, run := 0, 0
+	for _, r := range a.Content {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	return fence + a.Language + "\n" + strings.TrimRight(a.Content, "\n") + "\n" + fence
+}
+
+// AllImages returns the images of a message, attached ones and images of
+// pages included
+func (m Message) AllImages() []string {
+	images := slices.Clone(m.Images)
+	for _, a := range m.Attachments {
+		if a.IsImage() {
+			images = append(images, a.Content)
+		}
+		for _, page := range a.PageImages {
+			images = append(images, page.URL)
+		}
+	}
+	return images
+}
+
+// MessageMeta holds what termai records about a message besides its content.
+// It is persisted with saved conversations but never sent to the provider.
+type MessageMeta struct {
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	Profile   string    `json:"profile,omitempty"` // profile that produced an assistant message
+	Model     string    `json:"model,omitempty"`   // model that produced an assistant message
+	Pinned    bool      `json:"pinned,omitempty"`  // never dropped when trimming the history to the context window
+
+	Compacted  int    `json:"compacted,omitempty"`  // number of earlier messages a summary message replaces
+	Transcript string `json:"transcript,omitempty"` // file holding the conversation as it was before being compacted
 }
 
 // StreamChunk represents a chunk of streamed response
@@ -0,0 +1,115 @@
+package rag
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters, the usual defaults
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Words too common to tell chunks apart
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true, "can": true,
+	"do": true, "does": true, "for": true, "from": true, "how": true, "i": true, "if": true, "in": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "the": true, "this": true, "to": true, "what": true, "when": true,
+	"where": true, "which": true, "why": true, "with": true, "you": true,
+}
+
+// terms splits text into lowercase search terms. Identifiers also yield
+// their parts ("ScanDirectory" gives "scandirectory", "scan" and "directory",
+// "max_depth" gives "max_depth", "max" and "depth"), so questions in plain
+// words match code.
+func terms(text string) []string {
+	var out []string
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	for _, word := range words {
+		parts := identifierParts(word)
+		if lower := strings.ToLower(word); len(parts) != 1 && !stopWords[lower] {
+			out = append(out, lower)
+		}
+		for _, part := range parts {
+			if part = strings.ToLower(part); !stopWords[part] {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+// identifierParts splits an identifier at underscores and case changes
+func identifierParts(word string) []string {
+	var parts []string
+	for _, piece := range strings.Split(word, "_") {
+		runes := []rune(piece)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			lowerToUpper := unicode.IsLower(runes[i-1]) && unicode.IsUpper(runes[i])
+			// "HTTPServer": the last capital of an acronym starts the next word
+			acronymEnd := i+1 < len(runes) && unicode.IsUpper(runes[i-1]) && unicode.IsUpper(runes[i]) && unicode.IsLower(runes[i+1])
+			if lowerToUpper || acronymEnd {
+				parts = append(parts, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < len(runes) {
+			parts = append(parts, string(runes[start:]))
+		}
+	}
+	return parts
+}
+
+// scorer ranks the chunks of an index with BM25
+type scorer struct {
+	freqs  []map[string]int // term frequencies of each chunk
+	docs   map[string]int   // number of chunks containing each term
+	avgLen float64
+}
+
+func newScorer(docs [][]string) *scorer {
+	s := &scorer{freqs: make([]map[string]int, len(docs)), docs: map[string]int{}}
+	total := 0
+	for i, doc := range docs {
+		freq := map[string]int{}
+		for _, term := range doc {
+			freq[term]++
+		}
+		for term := range freq {
+			s.docs[term]++
+		}
+		s.freqs[i] = freq
+		total += len(doc)
+	}
+	if len(docs) > 0 {
+		s.avgLen = float64(total) / float64(len(docs))
+	}
+	return s
+}
+
+// score returns the BM25 score of the chunk at i for the query terms
+func (s *scorer) score(i int, query []string) float64 {
+	freq := s.freqs[i]
+	length := 0
+	for _, n := range freq {
+		length += n
+	}
+
+	n := float64(len(s.freqs))
+	score := 0.0
+	for _, term := range query {
+		tf := float64(freq[term])
+		if tf == 0 {
+			continue
+		}
+		df := float64(s.docs[term])
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(length)/s.avgLen))
+	}
+	return score
+}
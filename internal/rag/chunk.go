@@ -0,0 +1,175 @@
+package rag
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"strings"
+
+	"github.com/KooQix/term-ai/internal/fileprocessor"
+)
+
+const (
+	targetChunkLines = 50 // small neighbouring sections are merged up to this many lines
+	maxChunkLines    = 80 // longer sections are cut into pieces of targetChunkLines
+)
+
+// Chunk is a range of lines of an indexed file
+type Chunk struct {
+	StartLine int    `json:"start_line"` // 1-based, inclusive
+	EndLine   int    `json:"end_line"`
+	Text      string `json:"text"`
+
+	Vector []float32 `json:"vector,omitempty"` // embedding, unit length
+}
+
+// section is a range of lines that should not be cut when possible (a
+// function with its doc comment, a markdown section, a paragraph)
+type section struct {
+	start, end int // 0-based, end exclusive
+}
+
+// chunkFile cuts the content of a file into chunks along its syntax: Go
+// declarations, declarations found by the outline heuristics for other
+// languages, headings for documents, paragraphs otherwise
+func chunkFile(rel, content string) []Chunk {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	if len(lines) == 0 || (len(lines) == 1 && strings.TrimSpace(lines[0]) == "") {
+		return nil
+	}
+
+	ext := strings.ToLower(path.Ext(rel))
+	var starts []int
+	if ext == ".go" {
+		starts = goBoundaries(content)
+	}
+	if starts == nil {
+		starts = lineBoundaries(ext, lines)
+	}
+
+	return buildChunks(lines, toSections(starts, len(lines)))
+}
+
+// goBoundaries returns the lines (0-based) starting each top-level
+// declaration of a Go file, doc comment included, nil if it doesn't parse
+func goBoundaries(content string) []int {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+
+	starts := []int{0}
+	for _, decl := range file.Decls {
+		pos := decl.Pos()
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Doc != nil {
+				pos = d.Doc.Pos()
+			}
+		case *ast.GenDecl:
+			if d.Doc != nil {
+				pos = d.Doc.Pos()
+			}
+		}
+		starts = append(starts, fset.Position(pos).Line-1)
+	}
+	return starts
+}
+
+// lineBoundaries returns the lines (0-based) starting a section: declarations
+// (with the comments right above them) for the languages the outlines know,
+// blank-line separated paragraphs otherwise
+func lineBoundaries(ext string, lines []string) []int {
+	starts := []int{0}
+	declarations := false
+	for i, line := range lines {
+		if i > 0 && fileprocessor.IsDeclaration(ext, line) {
+			declarations = true
+			start := i
+			for start > 0 && isComment(lines[start-1]) {
+				start--
+			}
+			if start > starts[len(starts)-1] {
+				starts = append(starts, start)
+			}
+		}
+	}
+	if declarations {
+		return starts
+	}
+
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i-1]) == "" && strings.TrimSpace(lines[i]) != "" {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
+
+// isComment reports whether a line looks like a comment in a common language
+func isComment(line string) bool {
+	line = strings.TrimSpace(line)
+	for _, prefix := range []string{"//", "#", "/*", "*", "--", `"""`, "@"} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// toSections turns section starts into contiguous sections covering every line
+func toSections(starts []int, total int) []section {
+	var sections []section
+	for i, start := range starts {
+		end := total
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		if end > start {
+			sections = append(sections, section{start, end})
+		}
+	}
+	return sections
+}
+
+// buildChunks merges small neighbouring sections and cuts long ones
+func buildChunks(lines []string, sections []section) []Chunk {
+	var chunks []Chunk
+	add := func(start, end int) {
+		text := strings.Join(lines[start:end], "\n")
+		if strings.TrimSpace(text) == "" {
+			return
+		}
+		chunks = append(chunks, Chunk{StartLine: start + 1, EndLine: end, Text: text})
+	}
+
+	pending := section{-1, -1}
+	flush := func() {
+		if pending.start >= 0 {
+			add(pending.start, pending.end)
+			pending = section{-1, -1}
+		}
+	}
+
+	for _, s := range sections {
+		if s.end-s.start > maxChunkLines {
+			flush()
+			for start := s.start; start < s.end; start += targetChunkLines {
+				add(start, min(start+targetChunkLines, s.end))
+			}
+			continue
+		}
+
+		if pending.start >= 0 && s.end-pending.start > targetChunkLines {
+			flush()
+		}
+		if pending.start < 0 {
+			pending.start = s.start
+		}
+		pending.end = s.end
+	}
+	flush()
+	return chunks
+}
@@ -0,0 +1,137 @@
+package rag
+
+import (
+	"context"
+	"math"
+	"slices"
+
+	"github.com/KooQix/term-ai/internal/provider"
+)
+
+// rrfK damps the weight of the top ranks in the reciprocal rank fusion of the
+// BM25 and embedding rankings (the usual value)
+const rrfK = 60
+
+// Unembedded returns the number of chunks Embed has to embed with model
+func (idx *Index) Unembedded(model string) int {
+	n := 0
+	for _, file := range idx.Files {
+		for _, chunk := range file.Chunks {
+			if chunk.Vector == nil || idx.EmbeddingModel != model {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Embed computes the vectors of the chunks that have none with the embedding
+// model of a profile, so that Search can rank by meaning too. Vectors of
+// another model are dropped first. Returns the number of chunks embedded.
+func (idx *Index) Embed(ctx context.Context, embedder provider.Embedder, model string) (int, error) {
+	if idx.EmbeddingModel != model {
+		for _, file := range idx.Files {
+			for i := range file.Chunks {
+				file.Chunks[i].Vector = nil
+			}
+		}
+		idx.EmbeddingModel = model
+		idx.dirty = true
+	}
+
+	var (
+		texts   []string
+		pending []*Chunk
+	)
+	for rel, file := range idx.Files {
+		for i := range file.Chunks {
+			if file.Chunks[i].Vector == nil {
+				texts = append(texts, rel+"\n"+file.Chunks[i].Text)
+				pending = append(pending, &file.Chunks[i])
+			}
+		}
+	}
+	if len(texts) == 0 {
+		return 0, nil
+	}
+
+	vectors, err := embedder.Embed(ctx, texts)
+	if err != nil {
+		return 0, err
+	}
+	for i, chunk := range pending {
+		chunk.Vector = normalize(vectors[i])
+	}
+	idx.dirty = true
+	return len(pending), nil
+}
+
+// fuse merges the BM25 scores and the cosine similarities of the chunks to a
+// query vector by reciprocal rank fusion: a chunk ranking well in either
+// ranking ranks well
+func (idx *Index) fuse(bm25 []float64, vector []float32) []float64 {
+	vector = normalize(vector)
+	similarities := make([]float64, len(idx.refs))
+	for i, ref := range idx.refs {
+		chunk := idx.Files[ref.path].Chunks[ref.chunk]
+		if len(chunk.Vector) == len(vector) {
+			similarities[i] = dot(chunk.Vector, vector)
+		} else {
+			similarities[i] = math.Inf(-1)
+		}
+	}
+
+	fused := make([]float64, len(idx.refs))
+	// Chunks sharing no term with the query get nothing from BM25, chunks
+	// without a vector nothing from the embeddings
+	addRanks(fused, bm25, func(score float64) bool { return score > 0 })
+	addRanks(fused, similarities, func(score float64) bool { return !math.IsInf(score, -1) })
+	return fused
+}
+
+// addRanks adds 1/(rrfK+rank) to the fused score of each chunk counted in a ranking
+func addRanks(fused, scores []float64, counted func(float64) bool) {
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		switch {
+		case scores[a] > scores[b]:
+			return -1
+		case scores[a] < scores[b]:
+			return 1
+		}
+		return 0
+	})
+	for rank, i := range order {
+		if counted(scores[i]) {
+			fused[i] += 1 / float64(rrfK+rank+1)
+		}
+	}
+}
+
+// normalize scales a vector to unit length, so that dot products are cosines
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := float32(math.Sqrt(sum))
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = x / norm
+	}
+	return out
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
@@ -0,0 +1,292 @@
+package rag
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/KooQix/term-ai/internal/config"
+	"github.com/KooQix/term-ai/internal/fileprocessor"
+	"github.com/KooQix/term-ai/internal/utils"
+)
+
+const indexVersion = 1
+
+// Index is the retrieval index of a directory: its files cut into chunks,
+// ranked per question with BM25. It is kept under the config directory, one
+// file per indexed directory, and updated incrementally: only the files whose
+// modification time changed are read again, and rechunked if their content
+// (hash) changed.
+type Index struct {
+	Version int                     `json:"version"`
+	Root    string                  `json:"root"`  // absolute path of the indexed directory
+	Files   map[string]*indexedFile `json:"files"` // path relative to Root -> chunks
+
+	EmbeddingModel string `json:"embedding_model,omitempty"` // model of the chunk vectors, see Embed
+
+	path   string  // where the index is stored
+	dirty  bool    // changed since loaded or saved
+	scorer *scorer // built on the first search after a change
+	refs   []chunkRef
+}
+
+type indexedFile struct {
+	ModTime int64   `json:"mod_time"` // unix nano
+	Size    int64   `json:"size"`
+	Hash    string  `json:"hash"` // sha256 of the content
+	Chunks  []Chunk `json:"chunks"`
+}
+
+// chunkRef locates a chunk of the scorer in the index
+type chunkRef struct {
+	path  string
+	chunk int
+}
+
+// UpdateStats tells what an update changed
+type UpdateStats struct {
+	Files   int // files in the index
+	Chunks  int // chunks in the index
+	Indexed int // files (re)chunked
+	Removed int // files gone from the directory
+	Skipped []fileprocessor.SkippedFile
+}
+
+// Result is a chunk matching a query
+type Result struct {
+	Path  string // relative to the indexed directory
+	Chunk Chunk
+	Score float64
+}
+
+// Citation returns where the chunk comes from, as path:start-end
+func (r Result) Citation() string {
+	if r.Chunk.StartLine == r.Chunk.EndLine {
+		return fmt.Sprintf("%s:%d", r.Path, r.Chunk.StartLine)
+	}
+	return fmt.Sprintf("%s:%d-%d", r.Path, r.Chunk.StartLine, r.Chunk.EndLine)
+}
+
+// Open loads the index of a directory, starting a fresh one if there is none
+// yet (or it is unreadable or from another version). Call Update to bring it
+// up to date with the directory.
+func Open(dir string) (*Index, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, err := config.GetIndexesPath()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(root))
+	idx := &Index{
+		Version: indexVersion,
+		Root:    root,
+		Files:   map[string]*indexedFile{},
+		path:    filepath.Join(indexes, utils.Slugify(filepath.Base(root))+"-"+hex.EncodeToString(sum[:6])+".json"),
+	}
+
+	data, err := os.ReadFile(idx.path)
+	if err != nil {
+		return idx, nil
+	}
+	var stored Index
+	if err := json.Unmarshal(data, &stored); err != nil || stored.Version != indexVersion || stored.Root != root || stored.Files == nil {
+		return idx, nil
+	}
+	idx.Files = stored.Files
+	idx.EmbeddingModel = stored.EmbeddingModel
+	return idx, nil
+}
+
+// Update brings the index up to date with the directory, scanned with opts
+// (the total size budget does not apply: every file is indexed)
+func (idx *Index) Update(opts fileprocessor.ScanOptions) (*UpdateStats, error) {
+	opts.MaxTotalSize = 0
+	rels, skipped, err := fileprocessor.ListFiles(idx.Root, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &UpdateStats{Skipped: skipped}
+	current := make(map[string]bool, len(rels))
+	for _, rel := range rels {
+		current[rel] = true
+		file := filepath.Join(idx.Root, filepath.FromSlash(rel))
+
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		indexed, ok := idx.Files[rel]
+		if ok && indexed.ModTime == info.ModTime().UnixNano() && indexed.Size == info.Size() {
+			continue
+		}
+
+		content, reason := readContent(file)
+		if reason != "" {
+			stats.Skipped = append(stats.Skipped, fileprocessor.SkippedFile{Path: rel, Reason: reason})
+			if ok {
+				delete(idx.Files, rel)
+				idx.changed()
+			}
+			continue
+		}
+
+		sum := sha256.Sum256([]byte(content))
+		hash := hex.EncodeToString(sum[:])
+		if ok && indexed.Hash == hash {
+			// Touched but unchanged, the chunks still hold
+			indexed.ModTime, indexed.Size = info.ModTime().UnixNano(), info.Size()
+			idx.dirty = true
+			continue
+		}
+
+		idx.Files[rel] = &indexedFile{
+			ModTime: info.ModTime().UnixNano(),
+			Size:    info.Size(),
+			Hash:    hash,
+			Chunks:  chunkFile(rel, content),
+		}
+		stats.Indexed++
+		idx.changed()
+	}
+
+	for rel := range idx.Files {
+		if !current[rel] {
+			delete(idx.Files, rel)
+			stats.Removed++
+			idx.changed()
+		}
+	}
+
+	stats.Files = len(idx.Files)
+	for _, file := range idx.Files {
+		stats.Chunks += len(file.Chunks)
+	}
+	return stats, nil
+}
+
+// changed marks the index to be saved and its scorer to be rebuilt
+func (idx *Index) changed() {
+	idx.dirty = true
+	idx.scorer = nil
+}
+
+// readContent returns the text of a file, or the reason it can't be indexed
+func readContent(file string) (string, string) {
+	if reason, _ := fileprocessor.SniffContent(file); reason != "" {
+		return "", reason
+	}
+
+	attachment, err := fileprocessor.ProcessFile(file)
+	if err != nil {
+		return "", fileprocessor.SkipUnreadable
+	}
+	if attachment.Type == "image" {
+		return "", fileprocessor.SkipUnsupported
+	}
+	return attachment.Content, ""
+}
+
+// Save writes the index if it changed
+func (idx *Index) Save() error {
+	if !idx.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+	if err := utils.WriteFileAtomic(idx.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	idx.dirty = false
+	return nil
+}
+
+// Search returns the k chunks ranking best for the query, best first. With
+// the vector of the query (from the model of Embed), rankings by BM25 and by
+// meaning are fused; otherwise chunks not sharing any term with the query are
+// left out.
+func (idx *Index) Search(query string, vector []float32, k int) []Result {
+	queryTerms := terms(query)
+	if len(queryTerms) == 0 && vector == nil {
+		return nil
+	}
+	idx.buildScorer()
+
+	scores := make([]float64, len(idx.refs))
+	for i := range idx.refs {
+		scores[i] = idx.scorer.score(i, queryTerms)
+	}
+	if vector != nil {
+		scores = idx.fuse(scores, vector)
+	}
+
+	var results []Result
+	for i, ref := range idx.refs {
+		if scores[i] <= 0 {
+			continue
+		}
+		results = append(results, Result{Path: ref.path, Chunk: idx.Files[ref.path].Chunks[ref.chunk], Score: scores[i]})
+	}
+
+	slices.SortStableFunc(results, func(a, b Result) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return strings.Compare(a.Citation(), b.Citation())
+	})
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+// buildScorer indexes the terms of every chunk, the path of its file included
+func (idx *Index) buildScorer() {
+	if idx.scorer != nil {
+		return
+	}
+
+	paths := make([]string, 0, len(idx.Files))
+	for rel := range idx.Files {
+		paths = append(paths, rel)
+	}
+	slices.Sort(paths)
+
+	idx.refs = nil
+	var docs [][]string
+	for _, rel := range paths {
+		pathTerms := terms(strings.TrimSuffix(rel, path.Ext(rel)))
+		for i, chunk := range idx.Files[rel].Chunks {
+			idx.refs = append(idx.refs, chunkRef{path: rel, chunk: i})
+			docs = append(docs, append(terms(chunk.Text), pathTerms...))
+		}
+	}
+	idx.scorer = newScorer(docs)
+}
+
+// Format renders retrieved chunks for the model, with their citations
+func Format(results []Result, root string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Excerpts of the files of %s retrieved for the question, most relevant first. ", filepath.Base(root))
+	sb.WriteString("Cite the ones you rely on as path:line. Other parts of the files are not included.\n")
+	for _, r := range results {
+		sb.WriteString("\n--- " + r.Citation() + " ---\n" + r.Chunk.Text + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,214 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/KooQix/term-ai/internal/config"
+	ctxmanager "github.com/KooQix/term-ai/internal/context"
+	"github.com/KooQix/term-ai/internal/utils"
+)
+
+const (
+	indexFileName = "search_index.json"
+	indexVersion  = 1
+)
+
+// index is an on-disk inverted index of the words of saved conversations.
+// It only narrows down which files to scan: hits are always confirmed (and
+// their snippets built) by reading the candidate conversations. The words of
+// encrypted conversations are kept out of it: they are always scanned.
+type index struct {
+	Version   int                 `json:"version"`
+	Files     map[string]int64    `json:"files"`               // conversation path -> modification time (unix nano) when indexed
+	Postings  map[string][]string `json:"postings"`            // word -> paths of the conversations containing it
+	Encrypted map[string]bool     `json:"encrypted,omitempty"` // paths of the encrypted conversations
+}
+
+func indexPath() (string, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, indexFileName), nil
+}
+
+// loadIndex reads the index from disk, starting a fresh one if it is missing,
+// unreadable or from another version
+func loadIndex() (*index, error) {
+	idx := &index{
+		Version:   indexVersion,
+		Files:     map[string]int64{},
+		Postings:  map[string][]string{},
+		Encrypted: map[string]bool{},
+	}
+
+	path, err := indexPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return idx, nil
+	}
+
+	var stored index
+	if err := json.Unmarshal(data, &stored); err != nil || stored.Version != indexVersion {
+		return idx, nil
+	}
+	if stored.Files != nil && stored.Postings != nil {
+		idx = &stored
+	}
+	if idx.Encrypted == nil {
+		idx.Encrypted = map[string]bool{}
+	}
+	return idx, nil
+}
+
+// DropIndex deletes the on-disk index, once the conversations it holds the
+// words of are encrypted. Reports whether there was one.
+func DropIndex() (bool, error) {
+	path, err := indexPath()
+	if err != nil {
+		return false, err
+	}
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to remove the search index: %w", err)
+	}
+	return true, nil
+}
+
+func (idx *index) save() error {
+	path, err := indexPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("failed to encode search index: %w", err)
+	}
+	return utils.WriteFileAtomic(path, data, 0o600)
+}
+
+// update re-indexes new and modified conversations and forgets deleted ones
+func (idx *index) update(files []chatFile) {
+	current := make(map[string]bool, len(files))
+	for _, file := range files {
+		current[file.path] = true
+
+		info, err := os.Stat(file.path)
+		if err != nil {
+			continue
+		}
+		if mtime, ok := idx.Files[file.path]; ok && mtime == info.ModTime().UnixNano() {
+			continue
+		}
+
+		idx.remove(file.path)
+
+		if ctxmanager.IsEncryptedFile(file.path) {
+			idx.Encrypted[file.path] = true
+			idx.Files[file.path] = info.ModTime().UnixNano()
+			continue
+		}
+
+		conv, err := ctxmanager.ReadConversation(file.path)
+		if err != nil {
+			continue
+		}
+		for word := range conversationWords(conv) {
+			idx.Postings[word] = append(idx.Postings[word], file.path)
+		}
+		idx.Files[file.path] = info.ModTime().UnixNano()
+	}
+
+	for path := range idx.Files {
+		if !current[path] {
+			idx.remove(path)
+		}
+	}
+}
+
+// remove drops a conversation from the index
+func (idx *index) remove(path string) {
+	if _, ok := idx.Files[path]; !ok {
+		return
+	}
+	delete(idx.Files, path)
+	delete(idx.Encrypted, path)
+
+	for word, paths := range idx.Postings {
+		paths = slices.DeleteFunc(paths, func(p string) bool { return p == path })
+		if len(paths) == 0 {
+			delete(idx.Postings, word)
+		} else {
+			idx.Postings[word] = paths
+		}
+	}
+}
+
+// candidates keeps the files that contain every word of the query terms and
+// phrases. Terms match as substrings, like in the full scan, so every indexed
+// word containing a query word counts.
+func (idx *index) candidates(files []chatFile, q Query) []chatFile {
+	var needles []string
+	for _, text := range append(slices.Clone(q.Terms), q.Phrases...) {
+		needles = append(needles, words(text)...)
+	}
+
+	var allowed map[string]bool
+	for _, needle := range needles {
+		matching := map[string]bool{}
+		for word, paths := range idx.Postings {
+			if strings.Contains(word, needle) {
+				for _, path := range paths {
+					matching[path] = true
+				}
+			}
+		}
+
+		if allowed == nil {
+			allowed = matching
+			continue
+		}
+		for path := range allowed {
+			if !matching[path] {
+				delete(allowed, path)
+			}
+		}
+	}
+
+	if allowed == nil {
+		return files
+	}
+	return slices.DeleteFunc(slices.Clone(files), func(f chatFile) bool { return !allowed[f.path] && !idx.Encrypted[f.path] })
+}
+
+// conversationWords returns the set of words of a conversation (metadata
+// excluded, tags are filtered separately)
+func conversationWords(conv *ctxmanager.Conversation) map[string]bool {
+	set := map[string]bool{}
+	for _, msg := range conv.Messages {
+		for _, word := range words(msg.Content) {
+			set[word] = true
+		}
+	}
+	return set
+}
+
+// words splits text into lowercase words made of letters and digits
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,380 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/KooQix/term-ai/internal/config"
+	ctxmanager "github.com/KooQix/term-ai/internal/context"
+	"github.com/KooQix/term-ai/internal/provider"
+)
+
+const snippetRadius = 60 // characters of context shown on each side of the first match
+
+// Query describes what to look for in saved conversations
+type Query struct {
+	Terms   []string             // every term must appear in the message (case-insensitive)
+	Phrases []string             // every exact phrase must appear in the message (case-insensitive)
+	Role    provider.ContextRole // only messages of this role ("" = any)
+	Tags    []string             // the conversation must carry every tag
+	Since   time.Time            // only messages from this time on (zero = no bound)
+	Until   time.Time            // only messages before this time (zero = no bound)
+	Limit   int                  // maximum number of hits (0 = unlimited)
+}
+
+// Hit is a message matching a query
+type Hit struct {
+	ChatID  string // usable with --load-chat and /load
+	Path    string
+	Title   string
+	Role    provider.ContextRole
+	Index   int // position of the message in the conversation
+	Time    time.Time
+	Snippet string
+}
+
+// ParseQuery splits free text into terms and "quoted phrases". Filters can be
+// given inline as role:<role>, tag:<tag>, since:<YYYY-MM-DD> and until:<YYYY-MM-DD>.
+func ParseQuery(text string) (Query, error) {
+	var q Query
+
+	for {
+		start := strings.IndexByte(text, '"')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(text[start+1:], '"')
+		if end < 0 {
+			break
+		}
+		end += start + 1
+
+		if phrase := strings.TrimSpace(text[start+1 : end]); phrase != "" {
+			q.Phrases = append(q.Phrases, strings.ToLower(phrase))
+		}
+		text = text[:start] + " " + text[end+1:]
+	}
+
+	for _, field := range strings.Fields(text) {
+		key, value, ok := strings.Cut(field, ":")
+		if ok && value != "" {
+			switch strings.ToLower(key) {
+			case "role":
+				q.Role = provider.ContextRole(strings.ToLower(value))
+				continue
+			case "tag":
+				q.Tags = append(q.Tags, value)
+				continue
+			case "since", "until":
+				date, err := ParseDate(value)
+				if err != nil {
+					return q, err
+				}
+				if strings.ToLower(key) == "since" {
+					q.Since = date
+				} else {
+					q.Until = date.AddDate(0, 0, 1) // until is inclusive of the whole day
+				}
+				continue
+			}
+		}
+
+		if term := strings.ToLower(strings.Trim(field, `"`)); term != "" {
+			q.Terms = append(q.Terms, term)
+		}
+	}
+
+	return q, nil
+}
+
+// ParseDate parses a YYYY-MM-DD date in local time
+func ParseDate(value string) (time.Time, error) {
+	date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date '%s', expected YYYY-MM-DD", value)
+	}
+	return date, nil
+}
+
+// IsEmpty reports whether the query has nothing to match
+func (q Query) IsEmpty() bool {
+	return len(q.Terms) == 0 && len(q.Phrases) == 0
+}
+
+// Search looks for messages matching q in every saved conversation. With
+// useIndex, the on-disk index is refreshed and used to skip conversations
+// that can't match. The number of encrypted conversations that could not be
+// searched, for lack of a key, is returned along the hits.
+func Search(q Query, useIndex bool) ([]Hit, int, error) {
+	if q.IsEmpty() {
+		return nil, 0, fmt.Errorf("empty search query")
+	}
+
+	files, err := chatFiles()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if useIndex {
+		idx, err := loadIndex()
+		if err != nil {
+			return nil, 0, err
+		}
+		idx.update(files)
+		if err := idx.save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save search index: %v\n", err)
+		}
+		files = idx.candidates(files, q)
+	}
+
+	var hits []Hit
+	locked := 0
+	for _, file := range files {
+		conv, err := ctxmanager.ReadConversation(file.path)
+		if err != nil {
+			if errors.Is(err, ctxmanager.ErrNoKey) {
+				locked++
+			}
+			continue
+		}
+
+		hits = append(hits, searchConversation(conv, file.id, q)...)
+	}
+
+	// Newest first, keeping the hits of a conversation together and in order
+	slices.SortStableFunc(hits, func(a, b Hit) int { return b.Time.Compare(a.Time) })
+	rank := map[string]int{}
+	for i, hit := range hits {
+		if _, ok := rank[hit.Path]; !ok {
+			rank[hit.Path] = i
+		}
+	}
+	slices.SortStableFunc(hits, func(a, b Hit) int {
+		if c := rank[a.Path] - rank[b.Path]; c != 0 {
+			return c
+		}
+		return a.Index - b.Index
+	})
+	if q.Limit > 0 && len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+
+	return hits, locked, nil
+}
+
+// searchConversation returns the messages of a conversation matching q
+func searchConversation(conv *ctxmanager.Conversation, chatID string, q Query) []Hit {
+	for _, tag := range q.Tags {
+		if !slices.ContainsFunc(conv.Metadata.Tags, func(t string) bool { return strings.EqualFold(t, tag) }) {
+			return nil
+		}
+	}
+
+	var hits []Hit
+	for i, msg := range conv.Messages {
+		if msg.Role == provider.RoleSystem && q.Role != provider.RoleSystem {
+			continue
+		}
+		if q.Role != "" && msg.Role != q.Role {
+			continue
+		}
+
+		// Messages without their own timestamp take the conversation's
+		when := conv.Metadata.UpdatedAt
+		if msg.Meta != nil && !msg.Meta.CreatedAt.IsZero() {
+			when = msg.Meta.CreatedAt
+		}
+		if !q.Since.IsZero() && when.Before(q.Since) {
+			continue
+		}
+		if !q.Until.IsZero() && !when.Before(q.Until) {
+			continue
+		}
+
+		lower := strings.ToLower(msg.Content)
+		if !matches(lower, q) {
+			continue
+		}
+
+		hits = append(hits, Hit{
+			ChatID:  chatID,
+			Path:    conv.Path,
+			Title:   conv.Metadata.Title,
+			Role:    msg.Role,
+			Index:   i,
+			Time:    when,
+			Snippet: snippet(msg.Content, lower, q),
+		})
+	}
+
+	return hits
+}
+
+func matches(lower string, q Query) bool {
+	for _, term := range q.Terms {
+		if !strings.Contains(lower, term) {
+			return false
+		}
+	}
+	for _, phrase := range q.Phrases {
+		if !strings.Contains(lower, phrase) {
+			return false
+		}
+	}
+	return true
+}
+
+// snippet extracts a single-line excerpt of content around the first match
+func snippet(content, lower string, q Query) string {
+	first := len(lower)
+	for _, needle := range append(slices.Clone(q.Phrases), q.Terms...) {
+		if i := strings.Index(lower, needle); i >= 0 && i < first {
+			first = i
+		}
+	}
+	if first == len(lower) {
+		first = 0
+	}
+
+	// Lowercasing may change byte lengths for some scripts, fall back to the start
+	if len(lower) != len(content) {
+		first = 0
+	}
+
+	start := max(first-snippetRadius, 0)
+	end := min(first+snippetRadius*2, len(content))
+	for start > 0 && !utf8.RuneStart(content[start]) {
+		start--
+	}
+	for end < len(content) && !utf8.RuneStart(content[end]) {
+		end++
+	}
+
+	excerpt := strings.Join(strings.Fields(content[start:end]), " ")
+	if start > 0 {
+		excerpt = "…" + excerpt
+	}
+	if end < len(content) {
+		excerpt += "…"
+	}
+	return excerpt
+}
+
+// Highlight applies style to every occurrence of the query terms and phrases in text
+func Highlight(text string, q Query, style func(string) string) string {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		return text
+	}
+
+	// Mark the byte ranges to highlight, then render them in one pass
+	marked := make([]bool, len(text))
+	for _, needle := range append(slices.Clone(q.Phrases), q.Terms...) {
+		for offset := 0; ; {
+			i := strings.Index(lower[offset:], needle)
+			if i < 0 {
+				break
+			}
+			for j := offset + i; j < offset+i+len(needle); j++ {
+				marked[j] = true
+			}
+			offset += i + len(needle)
+		}
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(text); {
+		j := i
+		for j < len(text) && marked[j] == marked[i] {
+			j++
+		}
+		if marked[i] {
+			sb.WriteString(style(text[i:j]))
+		} else {
+			sb.WriteString(text[i:j])
+		}
+		i = j
+	}
+	return sb.String()
+}
+
+// chatFile is a conversation file along with its chat ID
+type chatFile struct {
+	path string
+	id   string
+}
+
+// chatFiles lists every conversation file of the registered chat directories.
+// IDs are relative to the default directory, and absolute for the other ones.
+func chatFiles() ([]chatFile, error) {
+	dirs, err := config.GetChatDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []chatFile
+	for i, dir := range dirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				// A registered directory may have been removed since
+				if path == dir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() || !config.IsChatFile(path) {
+				return nil
+			}
+
+			id := config.TrimChatExt(path)
+			if i == 0 {
+				if rel, err := filepath.Rel(dir, id); err == nil {
+					id = rel
+				}
+			}
+			files = append(files, chatFile{path: path, id: id})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan '%s': %w", dir, err)
+		}
+	}
+
+	return files, nil
+}
+
+// Format renders hits grouped by conversation, with matches passed through highlight
+func Format(hits []Hit, q Query, highlight func(string) string) string {
+	if len(hits) == 0 {
+		return "No matches found."
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d match(es):\n", len(hits))
+
+	lastChat := ""
+	for _, hit := range hits {
+		if hit.Path != lastChat {
+			lastChat = hit.Path
+			sb.WriteString("\n📄 " + hit.ChatID)
+			if hit.Title != "" {
+				sb.WriteString("  " + hit.Title)
+			}
+			sb.WriteString("\n")
+		}
+
+		when := ""
+		if !hit.Time.IsZero() {
+			when = hit.Time.Local().Format("2006-01-02 15:04") + " "
+		}
+		fmt.Fprintf(&sb, "  #%d %s%s: %s\n", hit.Index, when, hit.Role, Highlight(hit.Snippet, q, highlight))
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -0,0 +1,142 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const ReadFileType ToolType = "read_file"
+
+// maxReadFileBytes caps the size of a file read in full (ranges are not capped)
+const maxReadFileBytes = 256 * 1024
+
+/// Tool reading the files of a project sent as an outline (chat --dir-mode outline)
+
+type readFile struct {
+	root    string                  // absolute, symlinks resolved
+	refused func(rel string) string // why a path (slash-separated, relative to root) can't be read, "" if it can
+}
+
+// RegisterReadFile makes the read_file tool available, reading files under
+// root but the ones refused tells why they are kept from the model
+func RegisterReadFile(root string, refused func(rel string) string) error {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	if abs, err = filepath.EvalSymlinks(abs); err != nil {
+		return err
+	}
+
+	registerTool(&readFile{root: abs, refused: refused})
+	return nil
+}
+
+func (r *readFile) Name() string {
+	return string(ReadFileType)
+}
+
+func (r *readFile) Execute(name, argsJSON string) (string, error) {
+	var args struct {
+		Path      string `json:"path"`
+		StartLine int    `json:"start_line"`
+		EndLine   int    `json:"end_line"`
+	}
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", err
+	}
+
+	path, err := r.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", args.Path, err)
+	}
+	if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return "", fmt.Errorf("%s is a binary file", args.Path)
+	}
+
+	lines := strings.Split(string(data), "\n")
+	start, end := max(args.StartLine, 1), len(lines)
+	if args.EndLine > 0 {
+		end = min(args.EndLine, len(lines))
+	}
+	if start > end {
+		return "", fmt.Errorf("%s has %d lines, no line in %d-%d", args.Path, len(lines), start, end)
+	}
+	if args.StartLine <= 0 && args.EndLine <= 0 && len(data) > maxReadFileBytes {
+		return "", fmt.Errorf("%s is %d bytes, read it by ranges of lines (start_line, end_line)", args.Path, len(data))
+	}
+
+	// Numbered lines, so the model can refer to them and ask for a range
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s (lines %d-%d of %d)\n", filepath.ToSlash(args.Path), start, end, len(lines))
+	for i := start; i <= end; i++ {
+		fmt.Fprintf(&sb, "%5d  %s\n", i, lines[i-1])
+	}
+	return sb.String(), nil
+}
+
+// resolve turns a path relative to the root into an absolute one, refusing
+// anything outside the root, and the files left out of the project (hidden,
+// ignored, ...) whether named or linked to
+func (r *readFile) resolve(rel string) (string, error) {
+	if rel == "" {
+		return "", fmt.Errorf("missing path")
+	}
+
+	path := filepath.Join(r.root, filepath.FromSlash(rel))
+	named, _ := filepath.Rel(r.root, path)
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	inside, err := filepath.Rel(r.root, path)
+	if err != nil || inside == ".." || strings.HasPrefix(inside, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the project", rel)
+	}
+
+	if r.refused != nil {
+		for _, p := range []string{named, inside} {
+			if reason := r.refused(filepath.ToSlash(p)); reason != "" {
+				return "", fmt.Errorf("%s is left out of the project (%s) and can't be read", rel, reason)
+			}
+		}
+	}
+	return path, nil
+}
+
+func (r *readFile) Tool() Tool {
+	return Tool{
+		Type: "function",
+		Function: Function{
+			Name:        ReadFileType,
+			Description: "Read a file of the project whose outline you were given, in full or a range of lines. Lines come numbered.",
+
+			Parameters: FunctionParameters{
+				Type: "object",
+				Properties: map[string]FunctionParameter{
+					"path": {
+						Type:        "string",
+						Description: "File path relative to the project root, as shown in the tree",
+					},
+					"start_line": {
+						Type:        "integer",
+						Description: "First line to read (default 1)",
+					},
+					"end_line": {
+						Type:        "integer",
+						Description: "Last line to read (default: end of file)",
+					},
+				},
+				Required: []string{"path"},
+			},
+		},
+	}
+}
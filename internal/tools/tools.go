@@ -41,9 +41,10 @@ type FunctionParameter struct {
 }
 
 type ToolCallEvent struct {
-	Name   string
-	Args   string
-	Result string // filled after execution
+	Name    string
+	Args    string
+	Result  string // filled after execution
+	Reduced string // how the result was shrunk before being handed back to the model, empty if untouched
 }
 
 type ITool interface {
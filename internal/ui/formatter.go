@@ -3,11 +3,13 @@ package ui
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"strings"
 
 	"github.com/KooQix/term-ai/internal/config"
 	"github.com/alecthomas/chroma/v2"
 	"github.com/alecthomas/chroma/v2/formatters"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
 	"github.com/alecthomas/chroma/v2/lexers"
 	"github.com/alecthomas/chroma/v2/styles"
 	"github.com/charmbracelet/glamour"
@@ -45,6 +47,10 @@ var (
 	SystemStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#FFA500")).
 			Bold(true)
+
+	MatchStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#000000")).
+			Background(lipgloss.Color("#FFD700"))
 )
 
 // ContentFormat represents the detected format of content
@@ -224,6 +230,24 @@ func FormatCodeBlock(code, language string) (string, error) {
 
 // highlightCode applies syntax highlighting to code using chroma
 func highlightCode(code, language string) (string, error) {
+	// Get the formatter for terminal with 256 colors
+	formatter := formatters.Get("terminal256")
+	if formatter == nil {
+		formatter = formatters.Fallback
+	}
+
+	return highlightWith(code, language, formatter)
+}
+
+// HighlightHTML applies syntax highlighting to code as standalone HTML (inline
+// styles, no stylesheet needed), using the configured theme
+func HighlightHTML(code, language string) (string, error) {
+	return highlightWith(code, language, chromahtml.New(chromahtml.WithClasses(false), chromahtml.TabWidth(4)))
+}
+
+// highlightWith tokenizes code for the given language (guessed if empty or
+// unknown) and renders it with formatter in the configured theme
+func highlightWith(code, language string, formatter chroma.Formatter) (string, error) {
 	// Get the lexer for the language
 	var lexer chroma.Lexer
 	if language != "" {
@@ -244,12 +268,6 @@ func highlightCode(code, language string) (string, error) {
 		style = styles.Fallback
 	}
 
-	// Get the formatter for terminal with 256 colors
-	formatter := formatters.Get("terminal256")
-	if formatter == nil {
-		formatter = formatters.Fallback
-	}
-
 	// Tokenize
 	iterator, err := lexer.Tokenise(nil, code)
 	if err != nil {
@@ -310,3 +328,28 @@ func FormatSeparator() string {
 func FormatToolCall(name, args string) string {
 	return ToolStyle.Render("🔧 Tool Call: ") + ToolStyle.Render(name) + "\n" + InfoStyle.Render("Arguments: ") + args
 }
+
+// FormatToolResultNote flags a tool result that was reduced before being handed back to the model
+func FormatToolResultNote(note string) string {
+	return ToolStyle.Render("✂ Result ") + InfoStyle.Render(note)
+}
+
+// FormatCompacted renders the summary of compacted messages as a collapsed
+// block: a header and the first line of the summary
+func FormatCompacted(count int, summary, transcript string) string {
+	firstLine, _, _ := strings.Cut(strings.TrimSpace(summary), "\n")
+	if runes := []rune(firstLine); len(runes) > 76 {
+		firstLine = string(runes[:75]) + "…"
+	}
+
+	block := SystemStyle.Render(fmt.Sprintf("▸ %d earlier messages compacted into a summary", count)) + "\n" + InfoStyle.Render(firstLine)
+	if transcript != "" {
+		block += "\n" + InfoStyle.Render("Full transcript: "+transcript)
+	}
+
+	style := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#444444")).
+		Padding(0, 1)
+	return style.Render(block)
+}
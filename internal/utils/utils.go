@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"unicode"
 
 	"github.com/atotto/clipboard"
 )
@@ -31,6 +33,44 @@ func GetAbsolutePath(dirPath string) (string, error) {
 	return absPath, nil
 }
 
+// WriteFileAtomic writes data to a temporary file next to path and renames it
+// over path, so a crash mid-write never leaves a truncated file behind
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	// Clean up the temporary file on any failure below
+	ok := false
+	defer func() {
+		if !ok {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return fmt.Errorf("failed to set file permissions: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	ok = true
+	return nil
+}
+
 func CopyToClipboard(text string) error {
 	// Use the clipboard package to write text to the clipboard
 	err := clipboard.WriteAll(text)
@@ -39,3 +79,25 @@ func CopyToClipboard(text string) error {
 	}
 	return nil
 }
+
+// Slugify turns free text into a lowercase, dash-separated name safe to use as a file name
+func Slugify(text string) string {
+	const maxLen = 60
+
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+			dash = false
+		} else if !dash && sb.Len() > 0 {
+			sb.WriteByte('-')
+			dash = true
+		}
+		if sb.Len() >= maxLen {
+			break
+		}
+	}
+
+	return strings.Trim(sb.String(), "-")
+}